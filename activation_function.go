@@ -12,6 +12,10 @@ type ActivationFunction func(float64) float64
 type EncodableActivation struct {
 	Name               string
 	ActivationFunction ActivationFunction
+
+	// derivative of ActivationFunction with respect to its input, used by
+	// the gradient based trainers.  May be nil for custom activations.
+	Derivative ActivationFunction
 }
 
 func (activation *EncodableActivation) MarshalJSON() ([]byte, error) {
@@ -40,18 +44,25 @@ func (activation *EncodableActivation) UnmarshalJSON(bytes []byte) error {
 	switch activation.Name {
 	case "sigmoid":
 		activation.ActivationFunction = Sigmoid
+		activation.Derivative = SigmoidDerivative
 	case "tanh":
 		activation.ActivationFunction = math.Tanh
+		activation.Derivative = TanhDerivative
 	case "identity":
 		activation.ActivationFunction = Identity
+		activation.Derivative = IdentityDerivative
 	case "relu":
 		activation.ActivationFunction = ReLU
+		activation.Derivative = ReLUDerivative
 	case "logistic":
 		activation.ActivationFunction = Logistic
+		activation.Derivative = LogisticDerivative
 	case "abs":
 		activation.ActivationFunction = math.Abs
+		activation.Derivative = AbsDerivative
 	case "gaussian":
 		activation.ActivationFunction = Gaussian
+		activation.Derivative = GaussianDerivative
 	default:
 		log.Panicf("Unknown activation function: %v", activation.Name)
 	}
@@ -67,10 +78,16 @@ func Sigmoid(x float64) float64 {
 	return 1.0 / (1.0 + math.Pow(math.E, -1.0*x))
 }
 
+func SigmoidDerivative(x float64) float64 {
+	y := Sigmoid(x)
+	return y * (1.0 - y)
+}
+
 func EncodableSigmoid() *EncodableActivation {
 	return &EncodableActivation{
 		Name:               "sigmoid",
 		ActivationFunction: Sigmoid,
+		Derivative:         SigmoidDerivative,
 	}
 }
 
@@ -78,17 +95,28 @@ func Identity(x float64) float64 {
 	return x
 }
 
+func IdentityDerivative(x float64) float64 {
+	return 1.0
+}
+
 func EncodableIdentity() *EncodableActivation {
 	return &EncodableActivation{
 		Name:               "identity",
 		ActivationFunction: Identity,
+		Derivative:         IdentityDerivative,
 	}
 }
 
+func TanhDerivative(x float64) float64 {
+	y := math.Tanh(x)
+	return 1.0 - y*y
+}
+
 func EncodableTanh() *EncodableActivation {
 	return &EncodableActivation{
 		Name:               "tanh",
 		ActivationFunction: math.Tanh,
+		Derivative:         TanhDerivative,
 	}
 }
 
@@ -96,10 +124,19 @@ func ReLU(x float64) float64 {
 	return math.Max(x, 0)
 }
 
+// the derivative is undefined at 0, use 0 like most implementations do
+func ReLUDerivative(x float64) float64 {
+	if x > 0 {
+		return 1.0
+	}
+	return 0.0
+}
+
 func EncodableReLU() *EncodableActivation {
 	return &EncodableActivation {
 		Name:            "relu",
 		ActivationFunction: ReLU,
+		Derivative:         ReLUDerivative,
 	}
 }
 
@@ -107,10 +144,28 @@ func Logistic(x float64) float64 {
 	return float64(1.0) / (1.0 + math.Exp(-x))
 }
 
+func LogisticDerivative(x float64) float64 {
+	y := Logistic(x)
+	return y * (1.0 - y)
+}
+
 func EncodableLogistic() *EncodableActivation {
 	return &EncodableActivation {
 		Name:        "logistic",
 		ActivationFunction: Logistic,
+		Derivative:         LogisticDerivative,
+	}
+}
+
+// the derivative is undefined at 0, use 0 there
+func AbsDerivative(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1.0
+	case x < 0:
+		return -1.0
+	default:
+		return 0.0
 	}
 }
 
@@ -118,6 +173,7 @@ func EncodableAbs() *EncodableActivation {
 	return &EncodableActivation {
 		Name:       "abs",
 		ActivationFunction: math.Abs,
+		Derivative:         AbsDerivative,
 	}
 }
 
@@ -125,10 +181,15 @@ func Gaussian(x float64) float64 {
 	return math.Exp(-16*x*x)
 }
 
+func GaussianDerivative(x float64) float64 {
+	return -32 * x * Gaussian(x)
+}
+
 func EncodableGaussian() *EncodableActivation {
 	return &EncodableActivation {
 		Name:      "gaussian",
 		ActivationFunction: Gaussian,
+		Derivative:         GaussianDerivative,
 	}
 }
 
@@ -43,3 +43,20 @@ func TestActivationFunctionUnmarshal(t *testing.T) {
 	assert.True(t, encodableActivation.ActivationFunction != nil)
 
 }
+
+func TestActivationDerivatives(t *testing.T) {
+
+	h := 1e-6
+	points := []float64{-2.5, -0.7, -0.1, 0.1, 0.3, 1.9}
+
+	for _, activation := range AllEncodableActivations() {
+		assert.True(t, activation.Derivative != nil)
+		for _, x := range points {
+			f := activation.ActivationFunction
+			numeric := (f(x+h) - f(x-h)) / (2 * h)
+			analytic := activation.Derivative(x)
+			assert.True(t, EqualsWithMaxDelta(numeric, analytic, 1e-6))
+		}
+	}
+
+}
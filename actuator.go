@@ -1,10 +1,11 @@
 package neurgo
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/couchbaselabs/logg"
-	"log"
 	"sync"
 )
 
@@ -18,6 +19,8 @@ type Actuator struct {
 	VectorLength     int
 	ActuatorFunction ActuatorFunction
 	wg               *sync.WaitGroup
+	closing          chan chan bool
+	stopped          chan struct{}
 	Cortex           *Cortex
 }
 
@@ -34,7 +37,7 @@ func (actuator *Actuator) Init() {
 		// if there is no ActuatorFunction, create a default
 		// function which does nothing
 		actuatorFunc := func(outputs []float64) {
-			log.Panicf("defualt actuator function called - do nothing")
+			logg.LogTo("ACTUATOR_SYNC", "default actuator function called - do nothing")
 		}
 		actuator.ActuatorFunction = actuatorFunc
 	}
@@ -42,15 +45,22 @@ func (actuator *Actuator) Init() {
 	if actuator.wg == nil {
 		actuator.wg = &sync.WaitGroup{}
 		actuator.wg.Add(1)
+		actuator.closing = actuator.Closing
+		actuator.stopped = make(chan struct{})
 	}
 
 }
 
-func (actuator *Actuator) Run() {
+func (actuator *Actuator) Run(ctx context.Context) {
 
 	defer actuator.wg.Done()
+	defer close(actuator.stopped)
+	defer actuator.Cortex.recoverNode(actuator.NodeId)
 
-	actuator.checkRunnable()
+	if err := actuator.checkRunnable(); err != nil {
+		actuator.Cortex.fail(newNodeError(actuator.NodeId, err))
+		return
+	}
 
 	weightedInputs := createEmptyWeightedInputs(actuator.Inbound)
 
@@ -59,6 +69,8 @@ func (actuator *Actuator) Run() {
 	for {
 
 		select {
+		case <-ctx.Done():
+			closed = true
 		case responseChan := <-actuator.Closing:
 			closed = true
 			responseChan <- true
@@ -68,22 +80,24 @@ func (actuator *Actuator) Run() {
 			recordInput(weightedInputs, dataMessage)
 		}
 
-		if closed {
-			actuator.Closing = nil
-			actuator.DataChan = nil
-			break
-		}
-
-		if receiveBarrierSatisfied(weightedInputs) {
+		if !closed && receiveBarrierSatisfied(weightedInputs) {
 
-			scalarOutput := actuator.computeScalarOutput(weightedInputs)
+			scalarOutput, err := actuator.computeScalarOutput(weightedInputs)
+			if err != nil {
+				actuator.Cortex.fail(newNodeError(actuator.NodeId, err))
+				return
+			}
 			actuator.ActuatorFunction(scalarOutput)
 
 			if actuator.Cortex != nil && actuator.Cortex.SyncChan != nil {
 				logmsg := fmt.Sprintf("%v -> %v", actuator.NodeId.UUID, actuator.Cortex.NodeId.UUID)
 				logg.LogTo("ACTUATOR_SYNC", logmsg)
 
-				actuator.Cortex.SyncChan <- actuator.NodeId
+				select {
+				case actuator.Cortex.SyncChan <- actuator.NodeId:
+				case <-ctx.Done():
+					closed = true
+				}
 			} else {
 				logg.LogTo("ACTUATOR_SYNC", "Could not sync actuator: %v", actuator)
 			}
@@ -91,21 +105,27 @@ func (actuator *Actuator) Run() {
 
 		}
 
+		if closed {
+			actuator.Closing = nil
+			actuator.DataChan = nil
+			break
+		}
+
 	}
 
 }
 
-func (actuator *Actuator) Shutdown() {
+// Shutdown stops the goroutine started by Run and waits for it to
+// return.  Returns ErrNodeStopped if it has already stopped, for
+// instance because the cortex was stopped or its context cancelled.
+func (actuator *Actuator) Shutdown() error {
 
-	closingResponse := make(chan bool)
-	actuator.Closing <- closingResponse
-	response := <-closingResponse
-	if response != true {
-		log.Panicf("Got unexpected response on closing channel")
+	if err := shutdownNode(actuator.NodeId, actuator.closing, actuator.stopped); err != nil {
+		return err
 	}
 
-	actuator.wg.Wait()
-	actuator.wg = nil
+	actuator.wait()
+	return nil
 }
 
 func (actuator *Actuator) ConnectInbound(connectable InboundConnectable) *InboundConnection {
@@ -133,56 +153,65 @@ func (actuator *Actuator) String() string {
 	return JsonString(actuator)
 }
 
-func (actuator *Actuator) computeScalarOutput(weightedInputs []*weightedInput) []float64 {
+func (actuator *Actuator) computeScalarOutput(weightedInputs []*weightedInput) ([]float64, error) {
 
 	outputs := make([]float64, 0)
 	for _, weightedInput := range weightedInputs {
 		inputs := weightedInput.inputs
-		actuator.validateInputs(inputs)
+		if err := actuator.validateInputs(weightedInput); err != nil {
+			return nil, err
+		}
 		inputValue := inputs[0]
 		outputs = append(outputs, inputValue)
 	}
 
-	return outputs
+	return outputs, nil
 
 }
 
-func (actuator *Actuator) validateInputs(inputs []float64) {
-	if len(inputs) != 1 {
-		t := "%T got invalid input vector: %v"
-		message := fmt.Sprintf(t, actuator, inputs)
-		panic(message)
+func (actuator *Actuator) validateInputs(weightedInput *weightedInput) error {
+	if len(weightedInput.inputs) != 1 {
+		return fmt.Errorf("%w: got %d values from %v, expected 1",
+			ErrVectorLength,
+			len(weightedInput.inputs),
+			weightedInput.senderNodeUUID)
 	}
+	return nil
 }
 
-func (actuator *Actuator) checkRunnable() {
+// wait blocks until the goroutine started by Run has returned
+func (actuator *Actuator) wait() {
+	if actuator.wg != nil {
+		actuator.wg.Wait()
+		actuator.wg = nil
+	}
+}
+
+func (actuator *Actuator) checkRunnable() error {
 	if actuator.NodeId == nil {
-		msg := fmt.Sprintf("not expecting actuator.NodeId to be nil")
-		panic(msg)
+		return errors.New("not expecting actuator.NodeId to be nil")
 	}
 
 	if actuator.Closing == nil {
-		msg := fmt.Sprintf("not expecting actuator.Closing to be nil")
-		panic(msg)
+		return errors.New("not expecting actuator.Closing to be nil")
 	}
 
 	if actuator.DataChan == nil {
-		msg := fmt.Sprintf("not expecting actuator.DataChan to be nil")
-		panic(msg)
+		return errors.New("not expecting actuator.DataChan to be nil")
 	}
 
 	if actuator.ActuatorFunction == nil {
-		msg := fmt.Sprintf("not expecting actuator.ActuatorFunction to be nil")
-		panic(msg)
+		return errors.New("not expecting actuator.ActuatorFunction to be nil")
 	}
 
 	if len(actuator.Inbound) != actuator.VectorLength {
-		msg := fmt.Sprintf("# of inbound (%d) != VectorLength (%d)",
+		return fmt.Errorf("# of inbound (%d) != VectorLength (%d)",
 			len(actuator.Inbound),
 			actuator.VectorLength)
-		panic(msg)
 	}
 
+	return nil
+
 }
 
 func (actuator *Actuator) inbound() []*InboundConnection {
@@ -1,7 +1,9 @@
 package neurgo
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/couchbaselabs/go.assert"
 	"log"
@@ -46,7 +48,7 @@ func TestActuatorRun(t *testing.T) {
 	}
 
 	actuator.Init()
-	go actuator.Run()
+	go actuator.Run(context.Background())
 	// send it a message
 	fakeInput := []float64{1}
 	dataMessage := &DataMessage{
@@ -64,3 +66,23 @@ func TestActuatorRun(t *testing.T) {
 	assert.True(t, vectorEqualsWithMaxDelta(collectedActuatorVal, fakeInput, 0.1))
 
 }
+
+func TestActuatorInvalidInputs(t *testing.T) {
+
+	actuator := &Actuator{
+		NodeId:       NewActuatorId("actuator", 0.5),
+		VectorLength: 1,
+	}
+
+	weightedInputs := []*weightedInput{
+		&weightedInput{senderNodeUUID: "neuron", inputs: []float64{1, 2}},
+	}
+	_, err := actuator.computeScalarOutput(weightedInputs)
+	assert.True(t, errors.Is(err, ErrVectorLength))
+
+	weightedInputs[0].inputs = []float64{1}
+	outputs, err := actuator.computeScalarOutput(weightedInputs)
+	assert.True(t, err == nil)
+	assert.Equals(t, len(outputs), 1)
+
+}
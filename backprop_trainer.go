@@ -0,0 +1,225 @@
+package neurgo
+
+import (
+	"github.com/couchbaselabs/logg"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// BackpropTrainer trains the weights and biases of a feedforward cortex
+// (one without any recurrent connections, see IsConnectionRecurrent)
+// by stochastic gradient descent on the sum of squares error, using
+// the Derivative of each neuron's activation function.
+type BackpropTrainer struct {
+
+	// step size of each gradient descent update, if there is no Optimizer
+	LearningRate float64
+
+	// how the weights and biases are updated from their gradients, or
+	// nil for plain gradient descent with LearningRate.  The optimizer's
+	// state carries over from one call to Fit to the next, so training
+	// can be resumed.  Each restart starts from a copy of the optimizer
+	// without any state, and Fit leaves the Optimizer with the state of
+	// the attempt it returns.
+	Optimizer Optimizer
+
+	// penalties and constraints which keep the weights small, or nil
+	// for none
+	Regularization *Regularization
+
+	// maximum number of passes over the training samples
+	MaxEpochs int
+
+	// stop training as soon as the cortex reaches this fitness, or
+	// 0 to always run for MaxEpochs
+	TargetFitness float64
+
+	// number of times Fit starts over, from weights and biases drawn
+	// the same way as RandomWeight, if the cortex hasn't reached
+	// TargetFitness after MaxEpochs.  The fittest attempt is returned.
+	// Gradient descent on a small network such as xnor gets stuck in a
+	// local minimum from about half of its random starting points, so
+	// a few restarts make training far more reliable.
+	Restarts int
+
+	// source of the weights and biases of the restarts, or nil to seed
+	// one from the clock
+	Rand *rand.Rand
+
+	// which sensors and actuators the samples correspond to, or nil
+	// to map them by index.  See SampleMapping.
+	Mapping *SampleMapping
+
+	// the gradient descent of the last call to TrainEpoch, reused by
+	// the next one as long as it trains the same cortex on the same
+	// examples
+	descent *gradientDescent
+}
+
+func NewBackpropTrainer() *BackpropTrainer {
+	return &BackpropTrainer{
+		LearningRate:  0.5,
+		MaxEpochs:     10000,
+		TargetFitness: 100,
+	}
+}
+
+// Train returns a trained copy of cortex, or nil if the cortex could
+// not be trained.  Use Fit to find out why.
+func (trainer *BackpropTrainer) Train(cortex *Cortex, examples []*TrainingSample) *Cortex {
+	trained, err := trainer.Fit(cortex, examples)
+	if err != nil {
+		logg.LogWarn("BackpropTrainer unable to train cortex: %v", err)
+		return nil
+	}
+	return trained
+}
+
+// Fit is the same as Train, but returns an error rather than nil
+func (trainer *BackpropTrainer) Fit(cortex *Cortex, examples []*TrainingSample) (*Cortex, error) {
+
+	rng := trainer.Rand
+	if rng == nil && trainer.Restarts > 0 {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	var best *Cortex
+	bestFitness := math.Inf(-1)
+	bestOptimizer := trainer.Optimizer
+
+	for attempt := 0; attempt <= trainer.Restarts; attempt++ {
+
+		candidate := cortex.Copy()
+		optimizer := trainer.Optimizer
+		if attempt > 0 {
+			randomizeParameters(candidate, rng)
+			var err error
+			if optimizer, err = withoutState(optimizer); err != nil {
+				return nil, err
+			}
+		}
+
+		fitness, err := trainer.fit(candidate, examples, optimizer)
+		if err != nil {
+			return nil, err
+		}
+		if best == nil || fitness > bestFitness {
+			best = candidate
+			bestFitness = fitness
+			bestOptimizer = optimizer
+		}
+		if trainer.TargetFitness > 0 && fitness >= trainer.TargetFitness {
+			break
+		}
+		logg.LogTo("DEBUG", "BackpropTrainer attempt %d only reached fitness %v", attempt+1, fitness)
+
+	}
+
+	trainer.Optimizer = bestOptimizer
+	return best, nil
+
+}
+
+// train cortex in place with optimizer for up to MaxEpochs, and return
+// its fitness
+func (trainer *BackpropTrainer) fit(cortex *Cortex, examples []*TrainingSample, optimizer Optimizer) (float64, error) {
+
+	descent, err := trainer.prepare(cortex, examples, optimizer)
+	if err != nil {
+		return 0, err
+	}
+
+	for epoch := 0; epoch < trainer.MaxEpochs; epoch++ {
+
+		if _, err := trainer.epoch(descent, examples); err != nil {
+			return 0, err
+		}
+
+		if trainer.TargetFitness > 0 {
+			fitness, err := cortex.FitnessMapped(examples, trainer.Mapping)
+			if err != nil {
+				return 0, err
+			}
+			if fitness >= trainer.TargetFitness {
+				logg.LogTo("DEBUG", "BackpropTrainer reached fitness %v after %d epochs", fitness, epoch+1)
+				return fitness, nil
+			}
+		}
+
+	}
+
+	return cortex.FitnessMapped(examples, trainer.Mapping)
+
+}
+
+// TrainEpoch trains cortex in place with a single pass over the examples,
+// and returns the sum of squares error of the examples as they were
+// seen during the pass, plus the regularization penalty of the cortex
+// at the end of the pass.  The cortex is compiled on the first call, and
+// again only when it is called with a different cortex or examples, so
+// the structure of the cortex must not change between calls.
+func (trainer *BackpropTrainer) TrainEpoch(cortex *Cortex, examples []*TrainingSample) (float64, error) {
+	if !trainer.descent.reuse(cortex, examples, trainer.Mapping, trainer.Optimizer, trainer.LearningRate, trainer.Regularization) {
+		descent, err := trainer.prepare(cortex, examples, trainer.Optimizer)
+		if err != nil {
+			return 0, err
+		}
+		trainer.descent = descent
+	}
+	return trainer.epoch(trainer.descent, examples)
+}
+
+// SetLearningRate sets the LearningRate, and the learning rate of the
+// Optimizer if it has one
+func (trainer *BackpropTrainer) SetLearningRate(rate float64) {
+	trainer.LearningRate = rate
+	if setter, ok := trainer.Optimizer.(LearningRateSetter); ok {
+		setter.SetLearningRate(rate)
+	}
+}
+
+// MarshalCheckpoint saves the LearningRate and the Optimizer, state
+// included, so that training can be resumed by a TrainingSession
+func (trainer *BackpropTrainer) MarshalCheckpoint() ([]byte, error) {
+	return marshalGradientCheckpoint(trainer.LearningRate, trainer.Optimizer)
+}
+
+// UnmarshalCheckpoint restores what MarshalCheckpoint saved
+func (trainer *BackpropTrainer) UnmarshalCheckpoint(data []byte) error {
+	return unmarshalGradientCheckpoint(data, &trainer.LearningRate, &trainer.Optimizer)
+}
+
+func (trainer *BackpropTrainer) prepare(cortex *Cortex, examples []*TrainingSample, optimizer Optimizer) (*gradientDescent, error) {
+	descent, err := newGradientDescent(cortex, examples, trainer.Mapping, optimizer, trainer.LearningRate, trainer.Regularization)
+	if err != nil {
+		return nil, err
+	}
+	if err := descent.plan.checkFeedForward(); err != nil {
+		return nil, err
+	}
+	return descent, nil
+}
+
+// take a gradient descent step for every example in turn, and return
+// the loss of the epoch, counting the regularization penalty once
+func (trainer *BackpropTrainer) epoch(descent *gradientDescent, examples []*TrainingSample) (float64, error) {
+	errorAccumulated := float64(0)
+	for _, sample := range examples {
+		loss, err := descent.step([]*TrainingSample{sample})
+		if err != nil {
+			return 0, err
+		}
+		errorAccumulated += loss
+	}
+	return errorAccumulated + descent.penalty(), nil
+}
+
+// draw every weight and bias of cortex the same way as RandomWeight
+func randomizeParameters(cortex *Cortex, rng *rand.Rand) {
+	parameters := cortex.Parameters()
+	for i := range parameters {
+		parameters[i] = rng.Float64()*2*math.Pi - math.Pi
+	}
+	cortex.SetParameters(parameters)
+}
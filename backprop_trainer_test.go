@@ -0,0 +1,132 @@
+package neurgo
+
+import (
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"math/rand"
+	"testing"
+)
+
+func TestBackpropTrainerXnor(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	untrained := SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+
+	trainer := NewBackpropTrainer()
+	trainer.Restarts = 20
+	trainer.Rand = rand.New(rand.NewSource(1))
+	trained, err := trainer.Fit(untrained, examples)
+	assert.True(t, err == nil)
+
+	fitness, err := trained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= trainer.TargetFitness)
+
+	for _, sample := range examples {
+		inputs := map[string][]float64{"sensor": sample.SampleInputs[0]}
+		outputs, err := trained.Step(inputs)
+		assert.True(t, err == nil)
+		expected := sample.ExpectedOutputs[0]
+		assert.True(t, vectorEqualsWithMaxDelta(outputs["actuator"], expected, 0.2))
+	}
+
+	// the cortex that was passed in should not have been modified
+	untrainedFitness, err := untrained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, untrainedFitness < fitness)
+
+}
+
+func TestBackpropTrainerRestarts(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	untrained := SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+
+	trainer := NewBackpropTrainer()
+	trainer.MaxEpochs = 10
+	single, err := trainer.Fit(untrained, examples)
+	assert.True(t, err == nil)
+	singleFitness, err := single.Fitness(examples)
+	assert.True(t, err == nil)
+
+	// the first attempt starts from the cortex that was passed in, so
+	// the restarts can only improve on it
+	trainer.Restarts = 5
+	trainer.Rand = rand.New(rand.NewSource(1))
+	restarted, err := trainer.Fit(untrained, examples)
+	assert.True(t, err == nil)
+	restartedFitness, err := restarted.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, restartedFitness >= singleFitness)
+
+}
+
+func TestBackpropTrainerRecurrent(t *testing.T) {
+
+	cortex, err := NewCortexFromJSONString(exampleRecurrentCortexJson())
+	assert.True(t, err == nil)
+
+	trainer := NewBackpropTrainer()
+	_, err = trainer.Fit(cortex, XnorTrainingSamples())
+	assert.True(t, errors.Is(err, ErrNotFeedForward))
+
+	assert.True(t, trainer.Train(cortex, XnorTrainingSamples()) == nil)
+
+}
+
+func TestBackpropTrainerTrainEpochReuse(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	cortex := SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+
+	trainer := NewBackpropTrainer()
+	_, err := trainer.TrainEpoch(cortex, examples)
+	assert.True(t, err == nil)
+	descent := trainer.descent
+
+	// the next epoch on the same cortex reuses the compiled plan, but
+	// picks up a new learning rate
+	trainer.SetLearningRate(0.01)
+	_, err = trainer.TrainEpoch(cortex, examples)
+	assert.True(t, err == nil)
+	assert.True(t, trainer.descent == descent)
+	assert.Equals(t, descent.optimizer.(*SGD).LearningRate, 0.01)
+
+	// and an optimizer set in between
+	trainer.Optimizer = NewAdam(0.01)
+	_, err = trainer.TrainEpoch(cortex, examples)
+	assert.True(t, err == nil)
+	assert.True(t, trainer.descent == descent)
+	assert.True(t, descent.optimizer == trainer.Optimizer)
+
+	// another cortex or other examples are compiled afresh
+	other := SeededXnorCortexUntrained(rand.New(rand.NewSource(2)))
+	_, err = trainer.TrainEpoch(other, examples)
+	assert.True(t, err == nil)
+	assert.True(t, trainer.descent != descent)
+	assert.True(t, trainer.descent.cortex == other)
+
+	descent = trainer.descent
+	_, err = trainer.TrainEpoch(other, examples[:2])
+	assert.True(t, err == nil)
+	assert.True(t, trainer.descent != descent)
+
+}
+
+func TestBackpropTrainerRestartOptimizer(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+
+	// five epochs are too few for any attempt to reach TargetFitness,
+	// so every restart runs, and the optimizer is left with the state of
+	// the fittest attempt, which covers only that attempt's steps
+	trainer := NewBackpropTrainer()
+	trainer.MaxEpochs = 5
+	trainer.Restarts = 3
+	trainer.Rand = rand.New(rand.NewSource(1))
+	trainer.Optimizer = NewAdam(0.1)
+	_, err := trainer.Fit(SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), examples)
+	assert.True(t, err == nil)
+	assert.Equals(t, trainer.Optimizer.(*Adam).Steps, trainer.MaxEpochs*len(examples))
+
+}
@@ -0,0 +1,166 @@
+package neurgo
+
+import (
+	"github.com/couchbaselabs/logg"
+)
+
+// BPTTTrainer trains the weights and biases of a recurrent cortex with
+// truncated backpropagation through time.  The training samples are
+// treated as a single sequence: they are fed through the cortex in
+// order, starting each epoch with every recurrent connection primed to
+// 0, and the cortex is unrolled over windows of TruncationLength
+// samples.  The gradient of the sum of squares error over each window
+// is backpropagated to the start of that window and applied before
+// moving on to the next one, while the recurrent state carries over.
+type BPTTTrainer struct {
+
+	// step size of each gradient descent update, if there is no Optimizer
+	LearningRate float64
+
+	// how the weights and biases are updated from their gradients, or
+	// nil for plain gradient descent with LearningRate.  The optimizer's
+	// state carries over from one call to Fit to the next, so training
+	// can be resumed.
+	Optimizer Optimizer
+
+	// penalties and constraints which keep the weights small, or nil
+	// for none
+	Regularization *Regularization
+
+	// maximum number of passes over the training sequence
+	MaxEpochs int
+
+	// number of samples to unroll the cortex over, or 0 to unroll it
+	// over the entire sequence
+	TruncationLength int
+
+	// stop training as soon as the cortex reaches this fitness, or
+	// 0 to always run for MaxEpochs
+	TargetFitness float64
+
+	// which sensors and actuators the samples correspond to, or nil
+	// to map them by index.  See SampleMapping.
+	Mapping *SampleMapping
+
+	// the gradient descent of the last call to TrainEpoch, reused by
+	// the next one as long as it trains the same cortex on the same
+	// sequence
+	descent *gradientDescent
+}
+
+func NewBPTTTrainer() *BPTTTrainer {
+	return &BPTTTrainer{
+		LearningRate:  0.05,
+		MaxEpochs:     10000,
+		TargetFitness: 100,
+	}
+}
+
+// Train returns a trained copy of cortex, or nil if the cortex could
+// not be trained.  Use Fit to find out why.
+func (trainer *BPTTTrainer) Train(cortex *Cortex, examples []*TrainingSample) *Cortex {
+	trained, err := trainer.Fit(cortex, examples)
+	if err != nil {
+		logg.LogWarn("BPTTTrainer unable to train cortex: %v", err)
+		return nil
+	}
+	return trained
+}
+
+// Fit is the same as Train, but returns an error rather than nil
+func (trainer *BPTTTrainer) Fit(cortex *Cortex, examples []*TrainingSample) (*Cortex, error) {
+
+	cortex = cortex.Copy()
+
+	descent, err := newGradientDescent(cortex, examples, trainer.Mapping, trainer.Optimizer, trainer.LearningRate, trainer.Regularization)
+	if err != nil {
+		return nil, err
+	}
+
+	for epoch := 0; epoch < trainer.MaxEpochs; epoch++ {
+
+		if _, err := trainer.epoch(descent, examples); err != nil {
+			return nil, err
+		}
+
+		if trainer.TargetFitness > 0 {
+			fitness, err := cortex.FitnessMapped(examples, trainer.Mapping)
+			if err != nil {
+				return nil, err
+			}
+			if fitness >= trainer.TargetFitness {
+				logg.LogTo("DEBUG", "BPTTTrainer reached fitness %v after %d epochs", fitness, epoch+1)
+				break
+			}
+		}
+
+	}
+
+	return cortex, nil
+
+}
+
+// TrainEpoch trains cortex in place with a single pass over the training
+// sequence, and returns the sum of squares error of the sequence as it
+// was seen during the pass, plus the regularization penalty of the
+// cortex at the end of the pass.  The cortex is compiled on the first call,
+// and again only when it is called with a different cortex or sequence,
+// so the structure of the cortex must not change between calls.
+func (trainer *BPTTTrainer) TrainEpoch(cortex *Cortex, examples []*TrainingSample) (float64, error) {
+	if !trainer.descent.reuse(cortex, examples, trainer.Mapping, trainer.Optimizer, trainer.LearningRate, trainer.Regularization) {
+		descent, err := newGradientDescent(cortex, examples, trainer.Mapping, trainer.Optimizer, trainer.LearningRate, trainer.Regularization)
+		if err != nil {
+			return 0, err
+		}
+		trainer.descent = descent
+	}
+	return trainer.epoch(trainer.descent, examples)
+}
+
+// SetLearningRate sets the LearningRate, and the learning rate of the
+// Optimizer if it has one
+func (trainer *BPTTTrainer) SetLearningRate(rate float64) {
+	trainer.LearningRate = rate
+	if setter, ok := trainer.Optimizer.(LearningRateSetter); ok {
+		setter.SetLearningRate(rate)
+	}
+}
+
+// MarshalCheckpoint saves the LearningRate and the Optimizer, state
+// included, so that training can be resumed by a TrainingSession
+func (trainer *BPTTTrainer) MarshalCheckpoint() ([]byte, error) {
+	return marshalGradientCheckpoint(trainer.LearningRate, trainer.Optimizer)
+}
+
+// UnmarshalCheckpoint restores what MarshalCheckpoint saved
+func (trainer *BPTTTrainer) UnmarshalCheckpoint(data []byte) error {
+	return unmarshalGradientCheckpoint(data, &trainer.LearningRate, &trainer.Optimizer)
+}
+
+// take a gradient descent step for every window of the sequence in turn,
+// starting with every recurrent connection primed to 0, and return the
+// loss of the epoch, counting the regularization penalty once
+func (trainer *BPTTTrainer) epoch(descent *gradientDescent, examples []*TrainingSample) (float64, error) {
+
+	truncationLength := trainer.TruncationLength
+	if truncationLength <= 0 {
+		truncationLength = len(examples)
+	}
+
+	descent.plan.Reset()
+
+	errorAccumulated := float64(0)
+	for start := 0; start < len(examples); start += truncationLength {
+		end := start + truncationLength
+		if end > len(examples) {
+			end = len(examples)
+		}
+		loss, err := descent.step(examples[start:end])
+		if err != nil {
+			return 0, err
+		}
+		errorAccumulated += loss
+	}
+	return errorAccumulated + descent.penalty(), nil
+
+}
@@ -0,0 +1,126 @@
+package neurgo
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	"math/rand"
+	"testing"
+)
+
+// sensor -> hidden -> output -> actuator, where hidden is in a later layer
+// than output, so that output sees the value hidden had on the previous
+// tick.  With the right weights the actuator echoes the previous input.
+func delayLineCortex() *Cortex {
+
+	sensor := &Sensor{
+		NodeId:       NewSensorId("sensor", 0.0),
+		VectorLength: 1,
+	}
+	sensor.Init()
+
+	output := &Neuron{
+		ActivationFunction: EncodableTanh(),
+		NodeId:             NewNeuronId("output", 0.25),
+		Bias:               0.2,
+	}
+	output.Init()
+
+	hidden := &Neuron{
+		ActivationFunction: EncodableTanh(),
+		NodeId:             NewNeuronId("hidden", 0.5),
+		Bias:               -0.1,
+	}
+	hidden.Init()
+
+	actuator := &Actuator{
+		NodeId:       NewActuatorId("actuator", 0.75),
+		VectorLength: 1,
+	}
+	actuator.Init()
+
+	sensor.ConnectOutbound(hidden)
+	hidden.ConnectInboundWeighted(sensor, []float64{0.3})
+
+	hidden.ConnectOutbound(output)
+	output.ConnectInboundWeighted(hidden, []float64{0.4})
+
+	output.ConnectOutbound(actuator)
+	actuator.ConnectInbound(output)
+
+	cortex := &Cortex{
+		NodeId: NewCortexId("cortex"),
+	}
+	cortex.SetSensors([]*Sensor{sensor})
+	cortex.SetNeurons([]*Neuron{output, hidden})
+	cortex.SetActuators([]*Actuator{actuator})
+
+	return cortex
+
+}
+
+func delayLineSamples() []*TrainingSample {
+	inputs := []float64{0.5, -0.3, 0.1, 0.4, -0.5, -0.2, 0.3, 0.0, -0.4, 0.2}
+	samples := make([]*TrainingSample, len(inputs))
+	previous := 0.0
+	for i, input := range inputs {
+		samples[i] = &TrainingSample{
+			SampleInputs:    [][]float64{{input}},
+			ExpectedOutputs: [][]float64{{previous}},
+		}
+		previous = input
+	}
+	return samples
+}
+
+func TestBPTTTrainerDelayLine(t *testing.T) {
+
+	samples := delayLineSamples()
+	untrained := delayLineCortex()
+
+	untrainedFitness, err := untrained.Fitness(samples)
+	assert.True(t, err == nil)
+
+	// unrolled over the whole sequence
+	trainer := NewBPTTTrainer()
+	trainer.MaxEpochs = 5000
+
+	trained, err := trainer.Fit(untrained, samples)
+	assert.True(t, err == nil)
+
+	fitness, err := trained.Fitness(samples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= trainer.TargetFitness)
+	assert.True(t, fitness > untrainedFitness)
+
+	// with a truncation length of 1 the gradient never reaches the
+	// weight from the sensor to the hidden neuron, so it can't learn
+	trainer.TruncationLength = 1
+
+	trained, err = trainer.Fit(untrained, samples)
+	assert.True(t, err == nil)
+
+	fitness, err = trained.Fitness(samples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness < trainer.TargetFitness)
+
+}
+
+func TestBPTTTrainerFeedForward(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+
+	// with a truncation length of 1 this is the same as plain backprop
+	trainer := NewBPTTTrainer()
+	trainer.LearningRate = 0.5
+	trainer.TruncationLength = 1
+
+	trained, err := trainer.Fit(SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, err == nil)
+
+	expected, err := NewBackpropTrainer().Fit(SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, err == nil)
+
+	for i, neuron := range trained.Neurons {
+		assert.Equals(t, neuron.Bias, expected.Neurons[i].Bias)
+	}
+
+}
@@ -0,0 +1,215 @@
+package neurgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Checkpointer is a trainer whose state can be saved in a checkpoint
+// and restored from it, such as the optimizer of a gradient based
+// trainer or the members of an evolve.Population.  Configuration which
+// can't be saved, such as fitness functions, is not part of the state,
+// so a checkpoint is restored into a trainer which has been set up the
+// same way as the one which saved it.
+type Checkpointer interface {
+	MarshalCheckpoint() ([]byte, error)
+	UnmarshalCheckpoint(data []byte) error
+}
+
+// A Checkpoint is everything a TrainingSession needs to carry on where
+// it left off.  Each checkpoint is a directory, holding session.json
+// with the counters, metrics history and random number state, the
+// cortex being trained and the best cortex so far as cortex.json and
+// best.json, and the state of the trainer as trainer.json.
+type Checkpoint struct {
+
+	// number of epochs or generations completed
+	Epoch int
+
+	BestFitness float64
+	History     MetricsHistory
+	RandSource  *RandSource
+
+	// the cortex being trained epoch by epoch, or nil when evolving
+	Cortex *Cortex
+
+	// the best cortex so far, if the session keeps one
+	Best *Cortex
+
+	// the state saved by the trainer's Checkpointer
+	Trainer json.RawMessage
+}
+
+const checkpointPrefix = "checkpoint-"
+
+// check that a vector restored from a checkpoint is the length the
+// trainer expects, which it won't be if the checkpoint was saved while
+// training a different cortex
+func checkpointLength(name string, length, expected int) error {
+	if length != expected {
+		return fmt.Errorf("%w: checkpoint has %d %v, expected %d",
+			ErrParameterCount,
+			length,
+			name,
+			expected)
+	}
+	return nil
+}
+
+// the part of a Checkpoint saved as session.json
+type checkpointSession struct {
+	Epoch       int
+	BestFitness EncodableFloat
+	History     MetricsHistory
+	RandSource  *RandSource
+}
+
+// WriteCheckpoint saves checkpoint in a new directory inside dir, named
+// after its Epoch, and returns the path of the new directory.  The
+// checkpoint is written to a temporary directory first and renamed once
+// it is complete, so a run which dies while writing it leaves the
+// previous checkpoints intact.
+func WriteCheckpoint(dir string, checkpoint *Checkpoint) (string, error) {
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	temporary, err := ioutil.TempDir(dir, "writing-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(temporary)
+
+	session := checkpointSession{
+		Epoch:       checkpoint.Epoch,
+		BestFitness: EncodableFloat(checkpoint.BestFitness),
+		History:     checkpoint.History,
+		RandSource:  checkpoint.RandSource,
+	}
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"session.json", session},
+		{"cortex.json", checkpoint.Cortex},
+		{"best.json", checkpoint.Best},
+		{"trainer.json", checkpoint.Trainer},
+	}
+	for _, file := range files {
+		if value, ok := file.value.(*Cortex); ok && value == nil {
+			continue
+		}
+		if value, ok := file.value.(json.RawMessage); ok && value == nil {
+			continue
+		}
+		data, err := json.MarshalIndent(file.value, "", "    ")
+		if err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(filepath.Join(temporary, file.name), data, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s%09d", checkpointPrefix, checkpoint.Epoch))
+	if err := os.RemoveAll(path); err != nil {
+		return "", err
+	}
+	if err := os.Rename(temporary, path); err != nil {
+		return "", err
+	}
+	return path, nil
+
+}
+
+// ReadCheckpoint loads the checkpoint saved in the directory at path
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+
+	data, err := ioutil.ReadFile(filepath.Join(path, "session.json"))
+	if err != nil {
+		return nil, err
+	}
+	session := checkpointSession{}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+
+	checkpoint := &Checkpoint{
+		Epoch:       session.Epoch,
+		BestFitness: float64(session.BestFitness),
+		History:     session.History,
+		RandSource:  session.RandSource,
+	}
+
+	readCortex := func(name string) (*Cortex, error) {
+		data, err := ioutil.ReadFile(filepath.Join(path, name))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return NewCortexFromJSONSBytes(data)
+	}
+	if checkpoint.Cortex, err = readCortex("cortex.json"); err != nil {
+		return nil, err
+	}
+	if checkpoint.Best, err = readCortex("best.json"); err != nil {
+		return nil, err
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(path, "trainer.json"))
+	if err == nil {
+		checkpoint.Trainer = data
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return checkpoint, nil
+
+}
+
+// Checkpoints returns the paths of the checkpoints in dir, oldest first
+func Checkpoints(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	epochs := make([]int, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, checkpointPrefix) {
+			continue
+		}
+		epoch, err := strconv.Atoi(strings.TrimPrefix(name, checkpointPrefix))
+		if err != nil {
+			continue
+		}
+		epochs = append(epochs, epoch)
+	}
+	sort.Ints(epochs)
+	paths := make([]string, len(epochs))
+	for i, epoch := range epochs {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("%s%09d", checkpointPrefix, epoch))
+	}
+	return paths, nil
+}
+
+// LatestCheckpoint loads the most recent checkpoint in dir.  Returns
+// ErrNoCheckpoint if there aren't any.
+func LatestCheckpoint(dir string) (*Checkpoint, error) {
+	paths, err := Checkpoints(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w in %v", ErrNoCheckpoint, dir)
+	}
+	return ReadCheckpoint(paths[len(paths)-1])
+}
@@ -0,0 +1,188 @@
+package neurgo
+
+import (
+	"context"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointResume(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	newTrainer := func() *BackpropTrainer {
+		trainer := NewBackpropTrainer()
+		trainer.Optimizer = NewMomentum(0.5, 0.9)
+		return trainer
+	}
+
+	uninterrupted := NewTrainingSession()
+	expected, err := uninterrupted.Train(context.Background(), NewTrainingRun(20), newTrainer(), SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, err == nil)
+
+	dir, err := ioutil.TempDir("", "neurgo-checkpoints")
+	assert.True(t, err == nil)
+	defer os.RemoveAll(dir)
+
+	// die a few epochs after the checkpoint at epoch 5
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted := NewTrainingSession()
+	interrupted.CheckpointDir = dir
+	interrupted.CheckpointInterval = 5
+	interrupted.OnEpochEnd = func(metrics *EpochMetrics) {
+		if metrics.Epoch == 7 {
+			cancel()
+		}
+	}
+	_, err = interrupted.Train(ctx, NewTrainingRun(20), newTrainer(), SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	resumed := NewTrainingSession()
+	resumed.CheckpointDir = dir
+	resumed.CheckpointInterval = 5
+	assert.True(t, resumed.Resume(dir) == nil)
+	assert.Equals(t, resumed.Epoch, 5)
+	assert.Equals(t, len(resumed.History), 5)
+
+	trained, err := resumed.Train(context.Background(), NewTrainingRun(20), newTrainer(), SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, err == nil)
+	assert.Equals(t, resumed.Epoch, 20)
+	assert.Equals(t, len(resumed.History), 20)
+	for i, metrics := range resumed.History {
+		assert.Equals(t, metrics.Epoch, i)
+		assert.Equals(t, metrics.Loss, uninterrupted.History[i].Loss)
+	}
+
+	expectedParameters := expected.Parameters()
+	for i, parameter := range trained.Parameters() {
+		assert.Equals(t, parameter, expectedParameters[i])
+	}
+
+	paths, err := Checkpoints(dir)
+	assert.True(t, err == nil)
+	assert.Equals(t, len(paths), 4)
+
+}
+
+func TestCheckpointReadWrite(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "neurgo-checkpoints")
+	assert.True(t, err == nil)
+	defer os.RemoveAll(dir)
+
+	_, err = LatestCheckpoint(dir)
+	assert.True(t, errors.Is(err, ErrNoCheckpoint))
+	assert.True(t, errors.Is(NewTrainingSession().Resume(filepath.Join(dir, "missing")), ErrNoCheckpoint))
+
+	source := NewRandSource(1)
+	source.Uint64()
+	checkpoint := &Checkpoint{
+		Epoch:       3,
+		BestFitness: 2.5,
+		History:     MetricsHistory{{Epoch: 2, Fitness: 2.5, Validation: 1}},
+		RandSource:  source,
+		Best:        XnorCortex(),
+		Trainer:     []byte(`{"LearningRate":0.5}`),
+	}
+	path, err := WriteCheckpoint(dir, checkpoint)
+	assert.True(t, err == nil)
+	assert.Equals(t, path, filepath.Join(dir, "checkpoint-000000003"))
+
+	loaded, err := LatestCheckpoint(dir)
+	assert.True(t, err == nil)
+	assert.Equals(t, loaded.Epoch, 3)
+	assert.Equals(t, loaded.BestFitness, 2.5)
+	assert.Equals(t, len(loaded.History), 1)
+	assert.Equals(t, loaded.History[0].Fitness, 2.5)
+	assert.Equals(t, *loaded.RandSource, *source)
+	assert.True(t, loaded.Cortex == nil)
+	fitness, err := loaded.Best.Fitness(XnorTrainingSamples())
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= 100)
+
+	trainer := NewBackpropTrainer()
+	trainer.LearningRate = 0
+	assert.True(t, trainer.UnmarshalCheckpoint(loaded.Trainer) == nil)
+	assert.Equals(t, trainer.LearningRate, 0.5)
+	assert.True(t, trainer.Optimizer == nil)
+
+}
+
+func TestKeepCheckpoints(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "neurgo-checkpoints")
+	assert.True(t, err == nil)
+	defer os.RemoveAll(dir)
+
+	session := NewTrainingSession()
+	session.CheckpointDir = dir
+	session.CheckpointInterval = 1
+	session.KeepCheckpoints = 2
+	_, err = session.Train(context.Background(), NewTrainingRun(5), NewBackpropTrainer(), SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), XnorTrainingSamples())
+	assert.True(t, err == nil)
+
+	paths, err := Checkpoints(dir)
+	assert.True(t, err == nil)
+	assert.Equals(t, len(paths), 2)
+	assert.Equals(t, filepath.Base(paths[0]), "checkpoint-000000004")
+	assert.Equals(t, filepath.Base(paths[1]), "checkpoint-000000005")
+
+	// a trainer which can't save its state can't be checkpointed
+	session = NewTrainingSession()
+	session.CheckpointDir = dir
+	session.CheckpointInterval = 1
+	_, err = session.Train(context.Background(), NewTrainingRun(5), &fixedRateTrainer{}, SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), XnorTrainingSamples())
+	assert.True(t, errors.Is(err, ErrNotCheckpointable))
+
+}
+
+// evolve for the given number of generations uninterrupted, and again
+// interrupted a couple of generations after the checkpoint at
+// interval, and check that resuming from the checkpoint carries on
+// exactly as the uninterrupted run did.  newTrainer must start the
+// trainer with random numbers drawn from the session's RandSource.
+func assertResumesExactly(t *testing.T, newTrainer func(session *TrainingSession) Generational, generations, interval int) {
+
+	uninterrupted := NewTrainingSession()
+	expected, err := uninterrupted.Evolve(context.Background(), newTrainer(uninterrupted), generations, 0)
+	assert.True(t, err == nil)
+
+	dir, err := ioutil.TempDir("", "neurgo-checkpoints")
+	assert.True(t, err == nil)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted := NewTrainingSession()
+	interrupted.CheckpointDir = dir
+	interrupted.CheckpointInterval = interval
+	interrupted.OnGeneration = func(metrics *EpochMetrics) {
+		if metrics.Epoch == interval+1 {
+			cancel()
+		}
+	}
+	_, err = interrupted.Evolve(ctx, newTrainer(interrupted), generations, 0)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// the trainer is started before resuming, so that the random
+	// numbers it draws don't disturb the restored RandSource
+	resumed := NewTrainingSession()
+	trainer := newTrainer(resumed)
+	assert.True(t, resumed.Resume(dir) == nil)
+	assert.Equals(t, resumed.Epoch, interval)
+	best, err := resumed.Evolve(context.Background(), trainer, generations, 0)
+	assert.True(t, err == nil)
+
+	assert.Equals(t, len(resumed.History), generations)
+	for i, metrics := range resumed.History {
+		assert.Equals(t, metrics.Fitness, uninterrupted.History[i].Fitness)
+	}
+	expectedParameters := expected.Parameters()
+	for i, parameter := range best.Parameters() {
+		assert.Equals(t, parameter, expectedParameters[i])
+	}
+
+}
@@ -0,0 +1,530 @@
+package neurgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/couchbaselabs/logg"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// CMAESTrainer tunes the weights and biases of a cortex with a fixed
+// topology using the Covariance Matrix Adaptation Evolution Strategy
+// (see http://en.wikipedia.org/wiki/CMA-ES).  The cortex is treated as
+// a black box via Parameters and SetParameters, so unlike the gradient
+// based trainers it works on any topology and activation function.
+type CMAESTrainer struct {
+
+	// number of candidates sampled per generation, or 0 to use the
+	// default of 4 + 3 ln(# of parameters).  Must be at least 2, since
+	// the mean moves towards the fittest half of the candidates.
+	PopulationSize int
+
+	// initial standard deviation of the candidates around the mean
+	Sigma float64
+
+	// maximum number of times a candidate is evaluated
+	MaxEvaluations int
+
+	// stop training as soon as a candidate reaches this fitness, or
+	// 0 to always run for MaxEvaluations
+	TargetFitness float64
+
+	// function to maximize, or nil to use the fitness of the cortex on
+	// the training samples.  Called concurrently on different cortexes.
+	Fitness FitnessFunc
+
+	// number of candidates evaluated at once, or 0 for runtime.NumCPU()
+	Concurrency int
+
+	// source of all random numbers, or nil to seed one from the clock
+	Rand *rand.Rand
+
+	// which sensors and actuators the samples correspond to, or nil
+	// to map them by index.  See SampleMapping.
+	Mapping *SampleMapping
+
+	// the search begun by Start, which StepGeneration carries on
+	search *cmaesSearch
+}
+
+func NewCMAESTrainer() *CMAESTrainer {
+	return &CMAESTrainer{
+		Sigma:          0.5,
+		MaxEvaluations: 10000,
+		TargetFitness:  100,
+	}
+}
+
+// Train returns a trained copy of cortex, or nil if the cortex could
+// not be trained.  Use Fit to find out why.
+func (trainer *CMAESTrainer) Train(cortex *Cortex, examples []*TrainingSample) *Cortex {
+	trained, err := trainer.Fit(cortex, examples)
+	if err != nil {
+		logg.LogWarn("CMAESTrainer unable to train cortex: %v", err)
+		return nil
+	}
+	return trained
+}
+
+// Fit is the same as Train, but returns an error rather than nil.  The
+// examples are ignored if the trainer has its own Fitness function.
+// Returns ErrPopulationSize if PopulationSize is 1, or ErrParameterCount
+// if the cortex has no weights or biases to tune.
+func (trainer *CMAESTrainer) Fit(cortex *Cortex, examples []*TrainingSample) (*Cortex, error) {
+
+	if err := trainer.validate(cortex); err != nil {
+		return nil, err
+	}
+
+	search, err := trainer.newSearch(cortex, examples)
+	if err != nil {
+		return nil, err
+	}
+
+	best := search.cortex.Parameters()
+	bestFitness := math.Inf(-1)
+
+	for search.evaluations < trainer.MaxEvaluations {
+
+		candidate, candidateFitness, err := search.step()
+		if err != nil {
+			return nil, err
+		}
+		if candidateFitness > bestFitness {
+			bestFitness = candidateFitness
+			copy(best, candidate)
+		}
+
+		if trainer.TargetFitness > 0 && bestFitness >= trainer.TargetFitness {
+			logg.LogTo("DEBUG", "CMAESTrainer reached fitness %v after %d evaluations", bestFitness, search.evaluations)
+			break
+		}
+
+	}
+
+	if err := search.cortex.SetParameters(best); err != nil {
+		return nil, err
+	}
+	return search.cortex, nil
+
+}
+
+// Start prepares to train a copy of cortex one generation at a time with
+// StepGeneration, so that the trainer can be run by a TrainingSession
+// instead of Fit.  MaxEvaluations and TargetFitness are then up to the
+// session.  Fails the same way as Fit if the trainer can't tune cortex.
+func (trainer *CMAESTrainer) Start(cortex *Cortex, examples []*TrainingSample) error {
+	if err := trainer.validate(cortex); err != nil {
+		return err
+	}
+	search, err := trainer.newSearch(cortex, examples)
+	if err != nil {
+		return err
+	}
+	trainer.search = search
+	return nil
+}
+
+// StepGeneration samples and evaluates the next generation of
+// candidates, moves the distribution towards the fittest of them, and
+// returns a cortex with the parameters of the fittest candidate along
+// with its fitness.  This makes the trainer a Generational.  Returns
+// ErrNotStarted if Start hasn't been called.
+func (trainer *CMAESTrainer) StepGeneration() (*Cortex, float64, error) {
+	search := trainer.search
+	if search == nil {
+		return nil, 0, ErrNotStarted
+	}
+	candidate, fitness, err := search.step()
+	if err != nil {
+		return nil, 0, err
+	}
+	cortex := search.cortex.Copy()
+	if err := cortex.SetParameters(candidate); err != nil {
+		return nil, 0, err
+	}
+	return cortex, fitness, nil
+}
+
+// MarshalCheckpoint saves the state of the search begun by Start: the
+// distribution the candidates are sampled from and the evolution paths,
+// so that training can be resumed by a TrainingSession.  The random
+// number generator is not saved: for training to be resumed
+// deterministically, Rand must wrap the session's RandSource.
+func (trainer *CMAESTrainer) MarshalCheckpoint() ([]byte, error) {
+	search := trainer.search
+	if search == nil {
+		return nil, ErrNotStarted
+	}
+	strategy := search.strategy
+	return json.Marshal(cmaesCheckpoint{
+		Evaluations:      search.evaluations,
+		EigenEvaluations: strategy.eigenEvaluations,
+		Mean:             strategy.mean,
+		Sigma:            strategy.sigma,
+		PC:               strategy.pc,
+		PS:               strategy.ps,
+		C:                strategy.C,
+		B:                strategy.B,
+		D:                strategy.D,
+		InvSqrtC:         strategy.invsqrtC,
+	})
+}
+
+// UnmarshalCheckpoint restores what MarshalCheckpoint saved, into a
+// search begun by Start on the same cortex, which must be called first
+// (and before the session is resumed, since it can draw random numbers).
+func (trainer *CMAESTrainer) UnmarshalCheckpoint(data []byte) error {
+
+	search := trainer.search
+	if search == nil {
+		return ErrNotStarted
+	}
+	checkpoint := cmaesCheckpoint{}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return err
+	}
+
+	n := search.strategy.n
+	vectors := [][]float64{checkpoint.Mean, checkpoint.PC, checkpoint.PS, checkpoint.D}
+	for _, vector := range vectors {
+		if err := checkpointLength("parameters", len(vector), n); err != nil {
+			return err
+		}
+	}
+	for _, matrix := range [][][]float64{checkpoint.C, checkpoint.B, checkpoint.InvSqrtC} {
+		if err := checkpointLength("rows", len(matrix), n); err != nil {
+			return err
+		}
+		for _, row := range matrix {
+			if err := checkpointLength("parameters", len(row), n); err != nil {
+				return err
+			}
+		}
+	}
+
+	strategy := search.strategy
+	search.evaluations = checkpoint.Evaluations
+	strategy.eigenEvaluations = checkpoint.EigenEvaluations
+	strategy.mean = checkpoint.Mean
+	strategy.sigma = checkpoint.Sigma
+	strategy.pc = checkpoint.PC
+	strategy.ps = checkpoint.PS
+	strategy.C = checkpoint.C
+	strategy.B = checkpoint.B
+	strategy.D = checkpoint.D
+	strategy.invsqrtC = checkpoint.InvSqrtC
+	return nil
+
+}
+
+// the state of a CMA-ES search saved in a checkpoint.  The rest of the
+// state follows from the number of parameters and the PopulationSize.
+type cmaesCheckpoint struct {
+	Evaluations      int
+	EigenEvaluations int
+	Mean             []float64
+	Sigma            float64
+	PC               []float64
+	PS               []float64
+	C                [][]float64
+	B                [][]float64
+	D                []float64
+	InvSqrtC         [][]float64
+}
+
+func (trainer *CMAESTrainer) validate(cortex *Cortex) error {
+	if trainer.PopulationSize == 1 {
+		return fmt.Errorf("%w: CMA-ES needs at least 2 candidates per generation, got %d",
+			ErrPopulationSize,
+			trainer.PopulationSize)
+	}
+	if len(cortex.Parameters()) == 0 {
+		return fmt.Errorf("%w: cortex has no weights or biases", ErrParameterCount)
+	}
+	return nil
+}
+
+// start a search from a copy of cortex, which must have parameters
+func (trainer *CMAESTrainer) newSearch(cortex *Cortex, examples []*TrainingSample) (*cmaesSearch, error) {
+
+	cortex = cortex.Copy()
+
+	fitness := trainer.Fitness
+	if fitness == nil {
+		if err := trainer.Mapping.validate(cortex, examples); err != nil {
+			return nil, err
+		}
+		fitness = SampleFitness(examples, trainer.Mapping)
+	}
+
+	rng := trainer.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return &cmaesSearch{
+		cortex:    cortex,
+		evaluator: newParameterEvaluator(cortex, fitness, trainer.Concurrency),
+		strategy:  newCMAES(cortex.Parameters(), trainer.Sigma, trainer.PopulationSize),
+		rng:       rng,
+	}, nil
+
+}
+
+// a CMA-ES search in progress, tuning the parameters of cortex
+type cmaesSearch struct {
+	cortex      *Cortex
+	evaluator   *parameterEvaluator
+	strategy    *cmaes
+	rng         *rand.Rand
+	evaluations int
+}
+
+// sample, evaluate and learn from a generation of candidates, and return
+// the fittest of them along with its fitness
+func (search *cmaesSearch) step() ([]float64, float64, error) {
+
+	candidates := search.strategy.sample(search.rng)
+	fitnesses, err := search.evaluator.evaluate(candidates)
+	if err != nil {
+		return nil, 0, err
+	}
+	search.evaluations += len(candidates)
+
+	fittest := 0
+	for i, fitness := range fitnesses {
+		if fitness > fitnesses[fittest] {
+			fittest = i
+		}
+	}
+
+	search.strategy.update(candidates, fitnesses, search.evaluations)
+	return candidates[fittest], fitnesses[fittest], nil
+
+}
+
+// the state of a CMA-ES search, following the notation of Hansen's
+// "The CMA Evolution Strategy: A Tutorial"
+type cmaes struct {
+	n       int
+	lambda  int
+	mu      int
+	weights []float64
+	mueff   float64
+
+	cc, cs, c1, cmu, damps, chiN float64
+
+	mean  []float64
+	sigma float64
+	pc    []float64
+	ps    []float64
+
+	// C = B diag(D^2) B^T
+	C        [][]float64
+	B        [][]float64
+	D        []float64
+	invsqrtC [][]float64
+
+	eigenEvaluations int
+}
+
+func newCMAES(mean []float64, sigma float64, lambda int) *cmaes {
+
+	n := len(mean)
+	if lambda <= 0 {
+		lambda = 4 + int(3*math.Log(float64(n)))
+	}
+	mu := lambda / 2
+
+	weights := make([]float64, mu)
+	weightSum := float64(0)
+	for i := range weights {
+		weights[i] = math.Log(float64(mu)+0.5) - math.Log(float64(i+1))
+		weightSum += weights[i]
+	}
+	squaresSum := float64(0)
+	for i := range weights {
+		weights[i] /= weightSum
+		squaresSum += weights[i] * weights[i]
+	}
+	mueff := 1 / squaresSum
+
+	nf := float64(n)
+	strategy := &cmaes{
+		n:        n,
+		lambda:   lambda,
+		mu:       mu,
+		weights:  weights,
+		mueff:    mueff,
+		cc:       (4 + mueff/nf) / (nf + 4 + 2*mueff/nf),
+		cs:       (mueff + 2) / (nf + mueff + 5),
+		c1:       2 / ((nf+1.3)*(nf+1.3) + mueff),
+		chiN:     math.Sqrt(nf) * (1 - 1/(4*nf) + 1/(21*nf*nf)),
+		mean:     append([]float64{}, mean...),
+		sigma:    sigma,
+		pc:       make([]float64, n),
+		ps:       make([]float64, n),
+		C:        identityMatrix(n),
+		B:        identityMatrix(n),
+		D:        make([]float64, n),
+		invsqrtC: identityMatrix(n),
+	}
+	strategy.cmu = math.Min(1-strategy.c1, 2*(mueff-2+1/mueff)/((nf+2)*(nf+2)+mueff))
+	strategy.damps = 1 + 2*math.Max(0, math.Sqrt((mueff-1)/(nf+1))-1) + strategy.cs
+	for i := range strategy.D {
+		strategy.D[i] = 1
+	}
+
+	return strategy
+
+}
+
+// sample lambda candidates from N(mean, sigma^2 C)
+func (strategy *cmaes) sample(rng *rand.Rand) [][]float64 {
+	candidates := make([][]float64, strategy.lambda)
+	for k := range candidates {
+		z := make([]float64, strategy.n)
+		for i := range z {
+			z[i] = strategy.D[i] * rng.NormFloat64()
+		}
+		y := matrixVectorProduct(strategy.B, z)
+		candidates[k] = make([]float64, strategy.n)
+		for i := range y {
+			candidates[k][i] = strategy.mean[i] + strategy.sigma*y[i]
+		}
+	}
+	return candidates
+}
+
+// move the distribution towards the fittest candidates
+func (strategy *cmaes) update(candidates [][]float64, fitnesses []float64, evaluations int) {
+
+	n := strategy.n
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return fitnesses[order[i]] > fitnesses[order[j]]
+	})
+
+	oldMean := strategy.mean
+	strategy.mean = make([]float64, n)
+	for i, weight := range strategy.weights {
+		for j, x := range candidates[order[i]] {
+			strategy.mean[j] += weight * x
+		}
+	}
+
+	yw := make([]float64, n)
+	for i := range yw {
+		yw[i] = (strategy.mean[i] - oldMean[i]) / strategy.sigma
+	}
+
+	// cumulation: update the evolution paths
+	csFactor := math.Sqrt(strategy.cs * (2 - strategy.cs) * strategy.mueff)
+	invsqrtCyw := matrixVectorProduct(strategy.invsqrtC, yw)
+	for i := range strategy.ps {
+		strategy.ps[i] = (1-strategy.cs)*strategy.ps[i] + csFactor*invsqrtCyw[i]
+	}
+	psNorm := vectorNorm(strategy.ps)
+	generations := float64(evaluations) / float64(strategy.lambda)
+	hsig := float64(0)
+	if psNorm/math.Sqrt(1-math.Pow(1-strategy.cs, 2*generations))/strategy.chiN < 1.4+2/float64(n+1) {
+		hsig = 1
+	}
+	ccFactor := math.Sqrt(strategy.cc * (2 - strategy.cc) * strategy.mueff)
+	for i := range strategy.pc {
+		strategy.pc[i] = (1-strategy.cc)*strategy.pc[i] + hsig*ccFactor*yw[i]
+	}
+
+	// adapt the covariance matrix
+	steps := make([][]float64, strategy.mu)
+	for k := range steps {
+		steps[k] = make([]float64, n)
+		for i, x := range candidates[order[k]] {
+			steps[k][i] = (x - oldMean[i]) / strategy.sigma
+		}
+	}
+	decay := 1 - strategy.c1 - strategy.cmu
+	correction := (1 - hsig) * strategy.cc * (2 - strategy.cc)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			rankOne := strategy.pc[i]*strategy.pc[j] + correction*strategy.C[i][j]
+			rankMu := float64(0)
+			for k, weight := range strategy.weights {
+				rankMu += weight * steps[k][i] * steps[k][j]
+			}
+			strategy.C[i][j] = decay*strategy.C[i][j] + strategy.c1*rankOne + strategy.cmu*rankMu
+		}
+	}
+
+	// adapt the step size
+	strategy.sigma *= math.Exp((strategy.cs / strategy.damps) * (psNorm/strategy.chiN - 1))
+
+	// decompose C, but not every generation since it's O(n^3)
+	if float64(evaluations-strategy.eigenEvaluations) > float64(strategy.lambda)/(strategy.c1+strategy.cmu)/float64(n)/10 {
+		strategy.eigenEvaluations = evaluations
+		strategy.decompose()
+	}
+
+}
+
+func (strategy *cmaes) decompose() {
+
+	n := strategy.n
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			strategy.C[j][i] = strategy.C[i][j]
+		}
+	}
+
+	values, vectors := symmetricEigen(strategy.C)
+	strategy.B = vectors
+	for i, value := range values {
+		strategy.D[i] = math.Sqrt(math.Max(value, 1e-20))
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			sum := float64(0)
+			for k := 0; k < n; k++ {
+				sum += strategy.B[i][k] * strategy.B[j][k] / strategy.D[k]
+			}
+			strategy.invsqrtC[i][j] = sum
+		}
+	}
+
+}
+
+func identityMatrix(n int) [][]float64 {
+	matrix := make([][]float64, n)
+	for i := range matrix {
+		matrix[i] = make([]float64, n)
+		matrix[i][i] = 1
+	}
+	return matrix
+}
+
+func matrixVectorProduct(matrix [][]float64, vector []float64) []float64 {
+	result := make([]float64, len(matrix))
+	for i, row := range matrix {
+		for j, x := range row {
+			result[i] += x * vector[j]
+		}
+	}
+	return result
+}
+
+func vectorNorm(vector []float64) float64 {
+	sum := float64(0)
+	for _, x := range vector {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
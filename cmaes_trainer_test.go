@@ -0,0 +1,187 @@
+package neurgo
+
+import (
+	"context"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCMAESTrainerXnor(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	untrained := SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+
+	trainer := NewCMAESTrainer()
+	trainer.Rand = rand.New(rand.NewSource(1))
+	trainer.Concurrency = 4
+
+	trained, err := trainer.Fit(untrained, examples)
+	assert.True(t, err == nil)
+
+	fitness, err := trained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= trainer.TargetFitness)
+
+	// the cortex that was passed in should not have been modified
+	untrainedFitness, err := untrained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, untrainedFitness < fitness)
+
+}
+
+func TestCMAESTrainerFitnessFunc(t *testing.T) {
+
+	// the fittest parameters are all 1
+	fitness := func(cortex *Cortex) (float64, error) {
+		sum := float64(0)
+		for _, parameter := range cortex.Parameters() {
+			sum += (parameter - 1) * (parameter - 1)
+		}
+		return 1 / (1 + sum), nil
+	}
+
+	trainer := NewCMAESTrainer()
+	trainer.Fitness = fitness
+	trainer.TargetFitness = 1 / (1 + 1e-6)
+	trainer.Rand = rand.New(rand.NewSource(1))
+
+	trained, err := trainer.Fit(XnorCortex(), nil)
+	assert.True(t, err == nil)
+	for _, parameter := range trained.Parameters() {
+		assert.True(t, EqualsWithMaxDelta(parameter, 1, 1e-2))
+	}
+
+}
+
+func TestCMAESTrainerReproducible(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	untrained := SeededXnorCortexUntrained(rand.New(rand.NewSource(3)))
+
+	results := make([][]float64, 2)
+	for i := range results {
+		trainer := NewCMAESTrainer()
+		trainer.MaxEvaluations = 300
+		trainer.Rand = rand.New(rand.NewSource(2))
+		trainer.Concurrency = i + 2
+		trained := trainer.Train(untrained, examples)
+		assert.True(t, trained != nil)
+		results[i] = trained.Parameters()
+	}
+	for i := range results[0] {
+		assert.True(t, results[0][i] == results[1][i])
+	}
+
+}
+
+func TestCMAESTrainerPopulationSize(t *testing.T) {
+
+	trainer := NewCMAESTrainer()
+	trainer.PopulationSize = 1
+	trainer.Rand = rand.New(rand.NewSource(1))
+	_, err := trainer.Fit(SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), XnorTrainingSamples())
+	assert.True(t, errors.Is(err, ErrPopulationSize))
+
+	// two candidates is the smallest population that still learns
+	trainer.PopulationSize = 2
+	trainer.MaxEvaluations = 100
+	trained, err := trainer.Fit(SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), XnorTrainingSamples())
+	assert.True(t, err == nil)
+	for _, parameter := range trained.Parameters() {
+		assert.False(t, math.IsNaN(parameter))
+	}
+
+}
+
+func TestCMAESTrainerNoParameters(t *testing.T) {
+
+	// Fit and Start reject a cortex with nothing to tune the same way
+	trainer := NewCMAESTrainer()
+	_, err := trainer.Fit(&Cortex{}, nil)
+	assert.True(t, errors.Is(err, ErrParameterCount))
+	assert.True(t, errors.Is(trainer.Start(&Cortex{}, nil), ErrParameterCount))
+
+}
+
+func TestCMAESTrainerSession(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+
+	trainer := NewCMAESTrainer()
+	trainer.Rand = rand.New(rand.NewSource(1))
+	trainer.Concurrency = 4
+
+	_, _, err := trainer.StepGeneration()
+	assert.True(t, errors.Is(err, ErrNotStarted))
+	assert.True(t, trainer.Start(SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), examples) == nil)
+
+	session := NewTrainingSession()
+	generations := 0
+	session.OnGeneration = func(metrics *EpochMetrics) {
+		assert.Equals(t, metrics.Epoch, generations)
+		generations += 1
+	}
+	improvements := 0
+	session.OnImprovement = func(metrics *EpochMetrics, cortex *Cortex) {
+		improvements += 1
+	}
+
+	best, err := session.Evolve(context.Background(), trainer, 1000, trainer.TargetFitness)
+	assert.True(t, err == nil)
+	fitness, err := best.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= trainer.TargetFitness)
+	assert.Equals(t, fitness, session.BestFitness)
+
+	// every generation evaluated PopulationSize candidates
+	assert.Equals(t, generations, len(session.History))
+	assert.Equals(t, trainer.search.evaluations, generations*trainer.search.strategy.lambda)
+	assert.True(t, improvements > 1)
+
+	// cancelling stops after the current generation
+	ctx, cancel := context.WithCancel(context.Background())
+	session = NewTrainingSession()
+	session.OnGeneration = func(metrics *EpochMetrics) {
+		if metrics.Epoch == 2 {
+			cancel()
+		}
+	}
+	assert.True(t, trainer.Start(SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), examples) == nil)
+	best, err = session.Evolve(ctx, trainer, 0, 0)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, best != nil)
+	assert.Equals(t, len(session.History), 3)
+
+}
+
+func TestCMAESTrainerResume(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	newTrainer := func(session *TrainingSession) Generational {
+		session.RandSource = NewRandSource(1)
+		trainer := NewCMAESTrainer()
+		trainer.Rand = rand.New(session.RandSource)
+		trainer.Concurrency = 4
+		assert.True(t, trainer.Start(SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), examples) == nil)
+		return trainer
+	}
+
+	// long enough for the covariance matrix to be decomposed both before
+	// and after the checkpoint
+	assertResumesExactly(t, newTrainer, 40, 10)
+
+	// a checkpoint can only be restored into a started trainer, for a
+	// cortex with as many parameters
+	trainer := newTrainer(NewTrainingSession()).(*CMAESTrainer)
+	data, err := trainer.MarshalCheckpoint()
+	assert.True(t, err == nil)
+	assert.True(t, errors.Is(NewCMAESTrainer().UnmarshalCheckpoint(data), ErrNotStarted))
+	other := NewCMAESTrainer()
+	other.Fitness = func(cortex *Cortex) (float64, error) { return 0, nil }
+	assert.True(t, other.Start(delayLineCortex(), nil) == nil)
+	assert.True(t, errors.Is(other.UnmarshalCheckpoint(data), ErrParameterCount))
+
+}
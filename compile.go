@@ -0,0 +1,281 @@
+package neurgo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// An EvaluationPlan is a compiled form of a Cortex which evaluates the
+// network synchronously in the calling goroutine, rather than spinning
+// up a goroutine per node and passing every value through a channel.
+//
+// Neurons are evaluated in order of their LayerIndex.  Recurrent inbound
+// connections (see IsInboundConnectionRecurrent) read the output the
+// sender produced on the previous call to Evaluate, which is 0 before
+// the first call -- the same values primeAllRecurrentOutbound feeds into
+// the channel based runtime.
+//
+// The plan reads weights, biases and activation functions from the
+// cortex on every call, so it stays valid while those are being
+// trained, but it must be recompiled after any change in topology.
+type EvaluationPlan struct {
+	Cortex    *Cortex
+	sensors   []*Sensor
+	neurons   []*plannedNeuron
+	actuators []*plannedActuator
+	nets      []float64
+	outputs   [][]float64
+	previous  [][]float64
+
+	// the inputs of the latest call to Evaluate, with any Noise added,
+	// and the factor each neuron's output was scaled by for dropout
+	inputs [][]float64
+	scales []float64
+}
+
+// where a planned node reads one of its inputs from
+type planSource struct {
+	inbound   *InboundConnection
+	sensor    int // index into plan.sensors, or -1
+	neuron    int // index into plan.neurons, or -1
+	recurrent bool
+}
+
+type plannedNeuron struct {
+	neuron         *Neuron
+	sources        []*planSource
+	weightedInputs []*weightedInput
+}
+
+type plannedActuator struct {
+	actuator *Actuator
+	sources  []*planSource
+}
+
+// Compile the cortex into an EvaluationPlan.  An error is returned if
+// the cortex has connections which the channel based runtime would not
+// be able to run either, eg an inbound connection from a node that does
+// not exist or whose weight vector does not match the sender's output.
+func (cortex *Cortex) Compile() (*EvaluationPlan, error) {
+
+	plan := &EvaluationPlan{
+		Cortex:  cortex,
+		sensors: cortex.Sensors,
+	}
+
+	// sort.Stable keeps the cortex ordering within a layer, which
+	// doesn't affect the results since connections within a layer
+	// are always recurrent.
+	neurons := make([]*Neuron, len(cortex.Neurons))
+	copy(neurons, cortex.Neurons)
+	sort.Stable(neuronsByLayer(neurons))
+
+	sensorIndexes := make(map[string]int)
+	for i, sensor := range plan.sensors {
+		sensorIndexes[sensor.NodeId.UUID] = i
+	}
+	neuronIndexes := make(map[string]int)
+	for i, neuron := range neurons {
+		neuronIndexes[neuron.NodeId.UUID] = i
+	}
+
+	plan.nets = make([]float64, len(neurons))
+	plan.scales = make([]float64, len(neurons))
+	plan.outputs = make([][]float64, len(neurons))
+	plan.previous = make([][]float64, len(neurons))
+	for i := range neurons {
+		plan.outputs[i] = []float64{0}
+		plan.previous[i] = []float64{0}
+	}
+
+	for _, neuron := range neurons {
+
+		if neuron.ActivationFunction == nil {
+			err := errors.New("not expecting neuron.ActivationFunction to be nil")
+			return nil, newNodeError(neuron.NodeId, err)
+		}
+
+		plannedNeuron := &plannedNeuron{
+			neuron:         neuron,
+			sources:        make([]*planSource, len(neuron.Inbound)),
+			weightedInputs: createEmptyWeightedInputs(neuron.Inbound),
+		}
+
+		for i, inbound := range neuron.Inbound {
+			source, err := newPlanSource(inbound, sensorIndexes, neuronIndexes)
+			if err != nil {
+				return nil, newNodeError(neuron.NodeId, err)
+			}
+			if source.neuron != -1 {
+				source.recurrent = neuron.IsInboundConnectionRecurrent(inbound)
+			}
+			if err := plan.checkWeights(source); err != nil {
+				return nil, newNodeError(neuron.NodeId, err)
+			}
+			plannedNeuron.sources[i] = source
+		}
+
+		plan.neurons = append(plan.neurons, plannedNeuron)
+
+	}
+
+	for _, actuator := range cortex.Actuators {
+
+		if len(actuator.Inbound) != actuator.VectorLength {
+			err := fmt.Errorf("# of inbound (%d) != VectorLength (%d)",
+				len(actuator.Inbound),
+				actuator.VectorLength)
+			return nil, newNodeError(actuator.NodeId, err)
+		}
+
+		plannedActuator := &plannedActuator{
+			actuator: actuator,
+			sources:  make([]*planSource, len(actuator.Inbound)),
+		}
+
+		for i, inbound := range actuator.Inbound {
+			source, err := newPlanSource(inbound, sensorIndexes, neuronIndexes)
+			if err != nil {
+				return nil, newNodeError(actuator.NodeId, err)
+			}
+			if source.sensor != -1 && plan.sensors[source.sensor].VectorLength != 1 {
+				err := fmt.Errorf("%w: actuator can only read from a sensor with VectorLength 1", ErrInvalidConnection)
+				return nil, newNodeError(actuator.NodeId, err)
+			}
+			plannedActuator.sources[i] = source
+		}
+
+		plan.actuators = append(plan.actuators, plannedActuator)
+
+	}
+
+	return plan, nil
+
+}
+
+// Evaluate the network for a single tick.  The inputs are indexed the
+// same as cortex.Sensors, and the returned outputs the same as
+// cortex.Actuators.
+func (plan *EvaluationPlan) Evaluate(inputs [][]float64) ([][]float64, error) {
+
+	if len(inputs) != len(plan.sensors) {
+		return nil, fmt.Errorf("expected %d input vectors, got %d",
+			len(plan.sensors),
+			len(inputs))
+	}
+
+	for i, sensor := range plan.sensors {
+		if len(inputs[i]) != sensor.VectorLength {
+			err := fmt.Errorf("input vector length (%d) != VectorLength (%d)",
+				len(inputs[i]),
+				sensor.VectorLength)
+			return nil, newNodeError(sensor.NodeId, err)
+		}
+	}
+
+	noise := plan.Cortex.noise()
+	if noise != nil {
+		perturbed := make([][]float64, len(inputs))
+		for i, input := range inputs {
+			perturbed[i] = noise.perturbInput(input)
+		}
+		inputs = perturbed
+	}
+	plan.inputs = inputs
+
+	for i, plannedNeuron := range plan.neurons {
+		for j, source := range plannedNeuron.sources {
+			weightedInput := plannedNeuron.weightedInputs[j]
+			weightedInput.weights = source.inbound.Weights
+			weightedInput.inputs = plan.read(source, inputs)
+		}
+		neuron := plannedNeuron.neuron
+		net, err := neuron.netInput(plannedNeuron.weightedInputs)
+		if err != nil {
+			return nil, newNodeError(neuron.NodeId, err)
+		}
+		plan.nets[i] = net
+		output := neuron.ActivationFunction.ActivationFunction(plan.nets[i])
+		plan.scales[i] = 1
+		if noise != nil {
+			output, plan.scales[i] = noise.perturbOutput(neuron, output)
+		}
+		plan.outputs[i][0] = output
+	}
+
+	outputs := make([][]float64, len(plan.actuators))
+	for i, plannedActuator := range plan.actuators {
+		outputs[i] = make([]float64, len(plannedActuator.sources))
+		for j, source := range plannedActuator.sources {
+			outputs[i][j] = plan.read(source, inputs)[0]
+		}
+	}
+
+	for i := range plan.outputs {
+		plan.previous[i][0] = plan.outputs[i][0]
+	}
+
+	return outputs, nil
+
+}
+
+// Reset the recurrent state of the plan, so that the next call to
+// Evaluate behaves as if it were the first.
+func (plan *EvaluationPlan) Reset() {
+	for i := range plan.previous {
+		plan.previous[i][0] = 0
+	}
+}
+
+func (plan *EvaluationPlan) read(source *planSource, inputs [][]float64) []float64 {
+	switch {
+	case source.sensor != -1:
+		return inputs[source.sensor]
+	case source.recurrent:
+		return plan.previous[source.neuron]
+	default:
+		return plan.outputs[source.neuron]
+	}
+}
+
+func (plan *EvaluationPlan) checkWeights(source *planSource) error {
+	expected := 1
+	if source.sensor != -1 {
+		expected = plan.sensors[source.sensor].VectorLength
+	}
+	if len(source.inbound.Weights) != expected {
+		return fmt.Errorf("%w: %v has %d weights, expected %d",
+			ErrInvalidConnection,
+			source.inbound.NodeId.UUID,
+			len(source.inbound.Weights),
+			expected)
+	}
+	return nil
+}
+
+func newPlanSource(inbound *InboundConnection, sensorIndexes, neuronIndexes map[string]int) (*planSource, error) {
+	source := &planSource{
+		inbound: inbound,
+		sensor:  -1,
+		neuron:  -1,
+	}
+	if i, ok := sensorIndexes[inbound.NodeId.UUID]; ok {
+		source.sensor = i
+	} else if i, ok := neuronIndexes[inbound.NodeId.UUID]; ok {
+		source.neuron = i
+	} else {
+		return nil, fmt.Errorf("%w: no sensor or neuron with uuid %v",
+			ErrInvalidConnection,
+			inbound.NodeId.UUID)
+	}
+	return source, nil
+}
+
+type neuronsByLayer []*Neuron
+
+func (n neuronsByLayer) Len() int      { return len(n) }
+func (n neuronsByLayer) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+func (n neuronsByLayer) Less(i, j int) bool {
+	return n[i].NodeId.LayerIndex < n[j].NodeId.LayerIndex
+}
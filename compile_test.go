@@ -0,0 +1,265 @@
+package neurgo
+
+import (
+	"context"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"testing"
+)
+
+// sensors -> n1 -> n2 -> actuators, where n2 has a recurrent connection
+// back to n1 as well as to itself
+func backEdgeRecurrentCortex() *Cortex {
+
+	sensor1 := &Sensor{
+		NodeId:       NewSensorId("sensor1", 0.0),
+		VectorLength: 2,
+	}
+	sensor1.Init()
+
+	sensor2 := &Sensor{
+		NodeId:       NewSensorId("sensor2", 0.0),
+		VectorLength: 1,
+	}
+	sensor2.Init()
+
+	neuron1 := &Neuron{
+		ActivationFunction: EncodableTanh(),
+		NodeId:             NewNeuronId("neuron1", 0.25),
+		Bias:               0.3,
+	}
+	neuron1.Init()
+
+	neuron2 := &Neuron{
+		ActivationFunction: EncodableSigmoid(),
+		NodeId:             NewNeuronId("neuron2", 0.5),
+		Bias:               -0.7,
+	}
+	neuron2.Init()
+
+	neuron3 := &Neuron{
+		ActivationFunction: EncodableIdentity(),
+		NodeId:             NewNeuronId("neuron3", 0.5),
+		Bias:               0.1,
+	}
+	neuron3.Init()
+
+	actuator1 := &Actuator{
+		NodeId:       NewActuatorId("actuator1", 0.75),
+		VectorLength: 1,
+	}
+	actuator1.Init()
+
+	actuator2 := &Actuator{
+		NodeId:       NewActuatorId("actuator2", 0.75),
+		VectorLength: 2,
+	}
+	actuator2.Init()
+
+	sensor1.ConnectOutbound(neuron1)
+	neuron1.ConnectInboundWeighted(sensor1, []float64{0.9, -1.3})
+
+	neuron2.ConnectOutbound(neuron1)
+	neuron1.ConnectInboundWeighted(neuron2, []float64{1.7})
+
+	neuron1.ConnectOutbound(neuron2)
+	neuron2.ConnectInboundWeighted(neuron1, []float64{2.1})
+
+	neuron2.ConnectOutbound(neuron2)
+	neuron2.ConnectInboundWeighted(neuron2, []float64{-0.4})
+
+	neuron1.ConnectOutbound(neuron3)
+	neuron3.ConnectInboundWeighted(neuron1, []float64{0.6})
+
+	sensor2.ConnectOutbound(neuron3)
+	neuron3.ConnectInboundWeighted(sensor2, []float64{-1.1})
+
+	neuron2.ConnectOutbound(actuator1)
+	actuator1.ConnectInbound(neuron2)
+
+	neuron3.ConnectOutbound(actuator2)
+	actuator2.ConnectInbound(neuron3)
+
+	neuron2.ConnectOutbound(actuator2)
+	actuator2.ConnectInbound(neuron2)
+
+	cortex := &Cortex{
+		NodeId: NewCortexId("cortex"),
+	}
+	cortex.SetSensors([]*Sensor{sensor1, sensor2})
+	cortex.SetNeurons([]*Neuron{neuron3, neuron2, neuron1})
+	cortex.SetActuators([]*Actuator{actuator1, actuator2})
+
+	return cortex
+
+}
+
+func backEdgeRecurrentInputs() [][][]float64 {
+	return [][][]float64{
+		{{0, 1}, {0.5}},
+		{{1, 1}, {-0.5}},
+		{{0.25, -2}, {1}},
+		{{1, 0}, {0}},
+		{{-1, 0.5}, {2}},
+		{{0, 0}, {-3}},
+	}
+}
+
+func xnorInputs() [][][]float64 {
+	inputs := make([][][]float64, 0)
+	for _, sample := range XnorTrainingSamples() {
+		inputs = append(inputs, sample.SampleInputs)
+	}
+	// run through the samples twice to exercise any recurrent state
+	return append(inputs, inputs...)
+}
+
+// Feed one set of sensor inputs per tick into the channel based runtime,
+// and collect the actuator outputs of every tick.
+func runChannelRuntime(t *testing.T, cortex *Cortex, ticks [][][]float64) [][][]float64 {
+
+	results := make([][][]float64, len(ticks))
+	for i := range results {
+		results[i] = make([][]float64, len(cortex.Actuators))
+	}
+
+	for i, sensor := range cortex.Sensors {
+		sensorIndex := i
+		sensor.SensorFunction = func(syncCounter int) []float64 {
+			return ticks[syncCounter][sensorIndex]
+		}
+	}
+
+	for i, actuator := range cortex.Actuators {
+		actuatorIndex := i
+		tick := 0
+		actuator.ActuatorFunction = func(outputs []float64) {
+			results[tick][actuatorIndex] = outputs
+			tick += 1
+		}
+	}
+
+	err := cortex.Start(context.Background())
+	assert.True(t, err == nil)
+
+	for _ = range ticks {
+		assert.True(t, cortex.SyncSensors() == nil)
+		assert.True(t, cortex.SyncActuators() == nil)
+	}
+
+	assert.True(t, cortex.Stop() == nil)
+
+	return results
+
+}
+
+func runEvaluationPlan(t *testing.T, cortex *Cortex, ticks [][][]float64) [][][]float64 {
+
+	plan, err := cortex.Compile()
+	assert.True(t, err == nil)
+
+	results := make([][][]float64, len(ticks))
+	for i, inputs := range ticks {
+		results[i], err = plan.Evaluate(inputs)
+		assert.True(t, err == nil)
+	}
+	return results
+
+}
+
+func assertIdenticalOutputs(t *testing.T, expected, actual [][][]float64) {
+	assert.Equals(t, len(actual), len(expected))
+	for tick := range expected {
+		assert.Equals(t, len(actual[tick]), len(expected[tick]))
+		for i := range expected[tick] {
+			assert.Equals(t, len(actual[tick][i]), len(expected[tick][i]))
+			for j := range expected[tick][i] {
+				assert.True(t, actual[tick][i][j] == expected[tick][i][j])
+			}
+		}
+	}
+}
+
+func assertEquivalentRuntimes(t *testing.T, cortex *Cortex, ticks [][][]float64) {
+	cortex.LinkNodesToCortex()
+	channelResults := runChannelRuntime(t, cortex, ticks)
+	planResults := runEvaluationPlan(t, cortex, ticks)
+	assertIdenticalOutputs(t, channelResults, planResults)
+}
+
+func TestCompileEquivalenceXnor(t *testing.T) {
+	assertEquivalentRuntimes(t, XnorCortex(), xnorInputs())
+}
+
+func TestCompileEquivalenceXnorUntrained(t *testing.T) {
+	for i := 0; i < 5; i++ {
+		assertEquivalentRuntimes(t, XnorCortexUntrained(), xnorInputs())
+	}
+}
+
+func TestCompileEquivalenceRecurrent(t *testing.T) {
+	cortex, err := NewCortexFromJSONString(exampleRecurrentCortexJson())
+	assert.True(t, err == nil)
+	assertEquivalentRuntimes(t, cortex, xnorInputs())
+}
+
+func TestCompileEquivalenceBackEdge(t *testing.T) {
+	assertEquivalentRuntimes(t, backEdgeRecurrentCortex(), backEdgeRecurrentInputs())
+}
+
+func TestEvaluationPlanReset(t *testing.T) {
+
+	cortex := backEdgeRecurrentCortex()
+	ticks := backEdgeRecurrentInputs()
+
+	plan, err := cortex.Compile()
+	assert.True(t, err == nil)
+
+	first, err := plan.Evaluate(ticks[0])
+	assert.True(t, err == nil)
+
+	second, err := plan.Evaluate(ticks[0])
+	assert.True(t, err == nil)
+	assert.True(t, first[0][0] != second[0][0])
+
+	plan.Reset()
+
+	again, err := plan.Evaluate(ticks[0])
+	assert.True(t, err == nil)
+	assert.True(t, first[0][0] == again[0][0])
+
+}
+
+func TestCompileInvalidConnection(t *testing.T) {
+
+	cortex := XnorCortex()
+	outputNeuron := cortex.Neurons[2]
+	outputNeuron.Inbound[0].Weights = []float64{20, 20}
+
+	_, err := cortex.Compile()
+	assert.True(t, errors.Is(err, ErrInvalidConnection))
+
+	var nodeErr *NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, "output-neuron")
+
+	cortex = XnorCortex()
+	cortex.Neurons = cortex.Neurons[1:]
+
+	_, err = cortex.Compile()
+	assert.True(t, errors.Is(err, ErrInvalidConnection))
+
+}
+
+func TestEvaluationPlanInvalidInputs(t *testing.T) {
+
+	plan, err := XnorCortex().Compile()
+	assert.True(t, err == nil)
+
+	_, err = plan.Evaluate([][]float64{})
+	assert.True(t, err != nil)
+
+	_, err = plan.Evaluate([][]float64{{1, 0, 1}})
+	assert.True(t, err != nil)
+
+}
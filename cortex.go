@@ -2,6 +2,7 @@ package neurgo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/couchbaselabs/logg"
@@ -18,42 +19,94 @@ type Cortex struct {
 	Neurons   []*Neuron
 	Actuators []*Actuator
 	SyncChan  chan *NodeId // TODO: rename to ActuatorBarrier
+
+	// whether the cortex is in training mode, in which Noise (if any)
+	// is applied.  Should not be changed while the cortex is started.
+	Training bool
+	Noise    *Noise
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stepPlan *EvaluationPlan
+
+	// the first error reported by a node goroutine since Start, which
+	// is kept in err once it has been received from errs
+	errs chan error
+	err  error
 }
 
 type ActuatorBarrier map[*NodeId]bool // TODO: fixme!! totally broken
 type UUIDToNeuronMap map[string]*Neuron
 
-func (cortex *Cortex) Run() {
+// Start launches a goroutine for every sensor, neuron and actuator in
+// the cortex.  The goroutines run until Stop is called or ctx is
+// cancelled, whichever comes first.  A node which fails, eg because it
+// receives a vector of the wrong length or its SensorFunction panics,
+// stops its goroutine and reports a *NodeError, which is returned by
+// the next call to SyncSensors, SyncActuators or Stop.
+func (cortex *Cortex) Start(ctx context.Context) error {
+
+	if cortex.cancel != nil {
+		return ErrCortexAlreadyStarted
+	}
 
 	cortex.Init()
 
-	cortex.checkRunnable()
+	if err := cortex.checkRunnable(); err != nil {
+		return err
+	}
+
+	cortex.ctx, cortex.cancel = context.WithCancel(ctx)
+	cortex.errs = make(chan error, 1)
+	cortex.err = nil
 
 	// TODO: merge slices, create Runnable() interface
 	// and make into single loop
 
 	for _, sensor := range cortex.Sensors {
-		go sensor.Run()
+		go sensor.Run(cortex.ctx)
 	}
 	for _, neuron := range cortex.Neurons {
-		go neuron.Run()
+		go neuron.Run(cortex.ctx)
 	}
 	for _, actuator := range cortex.Actuators {
-		go actuator.Run()
+		go actuator.Run(cortex.ctx)
 	}
+
+	return nil
 }
 
-func (cortex *Cortex) Shutdown() {
+// Stop cancels the goroutines launched by Start and waits for all of
+// them to return.  The cortex can be started again afterwards.  Returns
+// the error of the first node which failed since Start, if any.
+func (cortex *Cortex) Stop() error {
+
+	if cortex.cancel == nil {
+		return ErrCortexNotStarted
+	}
+
+	cortex.cancel()
+
 	for _, sensor := range cortex.Sensors {
-		sensor.Shutdown()
+		sensor.wait()
 	}
 	for _, neuron := range cortex.Neurons {
-		neuron.Shutdown()
+		neuron.wait()
 	}
 	for _, actuator := range cortex.Actuators {
-		actuator.Shutdown()
+		actuator.wait()
 	}
+
+	cortex.shutdownOutboundConnections()
 	cortex.SyncChan = nil
+	cortex.ctx = nil
+	cortex.cancel = nil
+
+	err := cortex.failure()
+	cortex.errs = nil
+	cortex.err = nil
+
+	return err
 }
 
 // Initialize/re-initialize the cortex.
@@ -302,6 +355,9 @@ func (cortex *Cortex) Copy() *Cortex {
 		actuatorCopy.ActuatorFunction = actuator.ActuatorFunction
 	}
 
+	cortexCopy.Training = cortex.Training
+	cortexCopy.Noise = cortex.Noise
+
 	return cortexCopy
 
 }
@@ -330,75 +386,122 @@ func (cortex *Cortex) nodeIdToDataMsg() nodeIdToDataMsgMap {
 
 }
 
-func (cortex *Cortex) checkRunnable() {
+func (cortex *Cortex) checkRunnable() error {
 	if cortex.SyncChan == nil {
-		log.Panicf("cortex.SyncChan is nil")
+		return ErrCortexNotInitialized
 	}
 	if validated := cortex.Validate(); !validated {
-		log.Panicf("cortex.Validate failed")
+		return ErrCortexInvalid
+	}
+	for _, sensor := range cortex.Sensors {
+		if err := sensor.checkRunnable(); err != nil {
+			return newNodeError(sensor.NodeId, err)
+		}
+	}
+	for _, neuron := range cortex.Neurons {
+		if err := neuron.checkRunnable(); err != nil {
+			return newNodeError(neuron.NodeId, err)
+		}
 	}
+	for _, actuator := range cortex.Actuators {
+		if err := actuator.checkRunnable(); err != nil {
+			return newNodeError(actuator.NodeId, err)
+		}
+	}
+	return cortex.ValidateConnections()
 
 }
 
-func (cortex *Cortex) Verify(samples []*TrainingSample) bool {
-	fitness := cortex.Fitness(samples)
-	return fitness >= FITNESS_THRESHOLD
+func (cortex *Cortex) Verify(samples []*TrainingSample) (bool, error) {
+	fitness, err := cortex.Fitness(samples)
+	if err != nil {
+		return false, err
+	}
+	return fitness >= FITNESS_THRESHOLD, nil
 }
 
-func (cortex *Cortex) Fitness(samples []*TrainingSample) float64 {
+// Fitness feeds SampleInputs[i] of each sample into cortex.Sensors[i],
+// and compares ExpectedOutputs[j] against the output of
+// cortex.Actuators[j].  The fitness is the inverse of the SSE summed over
+// all samples, as given by SafeScalarInverse, so it is capped at 1e9,
+// which an SSE of 1e-9 or less (such as an exact fit) scores.
+// See FitnessMapped to map the samples by UUID instead, and Evaluate to
+// score the cortex with a different Objective.
+func (cortex *Cortex) Fitness(samples []*TrainingSample) (float64, error) {
+	return cortex.FitnessMapped(samples, nil)
+}
+
+// FitnessMapped is the same as Fitness, but uses mapping to decide which
+// sensors and actuators the vectors in each sample correspond to.
+func (cortex *Cortex) FitnessMapped(samples []*TrainingSample, mapping *SampleMapping) (float64, error) {
+	errorAccumulated, err := cortex.EvaluateMapped(samples, mapping, SSE{})
+	if err != nil {
+		return 0, err
+	}
+	return SafeScalarInverse(errorAccumulated), nil
+}
+
+// Evaluate feeds SampleInputs[i] of each sample into cortex.Sensors[i],
+// collects the output of each of cortex.Actuators[j] to compare against
+// ExpectedOutputs[j], and returns the score given to the outputs of all
+// samples by objective.
+func (cortex *Cortex) Evaluate(samples []*TrainingSample, objective Objective) (float64, error) {
+	return cortex.EvaluateMapped(samples, nil, objective)
+}
+
+// EvaluateMapped is the same as Evaluate, but uses mapping to decide which
+// sensors and actuators the vectors in each sample correspond to.
+func (cortex *Cortex) EvaluateMapped(samples []*TrainingSample, mapping *SampleMapping, objective Objective) (float64, error) {
 
-	cortex.Init()
 	cortex.LinkNodesToCortex()
 
 	if ok := cortex.Validate(); !ok {
-		log.Panicf("Cortex did not Validate()")
+		return 0, ErrCortexInvalid
 	}
 
-	errorAccumulated := float64(0)
-
-	// assumes there is only one sensor and one actuator
-	// (to support more, this method will require more coding)
-	if len(cortex.Sensors) != 1 {
-		log.Panicf("Must have exactly one sensor")
-	}
-	if len(cortex.Actuators) != 1 {
-		log.Panicf("Must have exactly one actuator")
+	if err := mapping.validate(cortex, samples); err != nil {
+		return 0, err
 	}
 
-	// install function to sensor which will stream training samples
-	sensor := cortex.Sensors[0]
-	sensorFunc := func(syncCounter int) []float64 {
-		sampleX := samples[syncCounter]
-		return sampleX.SampleInputs[0]
+	sensorIndexes, actuatorIndexes, err := mapping.resolve(cortex)
+	if err != nil {
+		return 0, err
 	}
-	sensor.SensorFunction = sensorFunc
 
-	// install function to actuator which will collect outputs
-	actuator := cortex.Actuators[0]
-	numTimesFuncCalled := 0
-	actuatorFunc := func(outputs []float64) {
-		expected := samples[numTimesFuncCalled].ExpectedOutputs[0]
-		error := SumOfSquaresError(expected, outputs)
-		logg.LogTo("DEBUG", "expected: %v actual: %v error: %v", expected, outputs, error)
-		errorAccumulated += error
-		numTimesFuncCalled += 1
-		// cortex.SyncChan <- actuator.NodeId <-- moved to actuator itself
+	plan, err := cortex.Compile()
+	if err != nil {
+		return 0, err
 	}
-	actuator.ActuatorFunction = actuatorFunc
 
-	go cortex.Run()
+	return plan.score(samples, sensorIndexes, actuatorIndexes, objective)
 
-	for _ = range samples {
-		cortex.SyncSensors()
-		cortex.SyncActuators()
-	}
+}
 
-	cortex.Shutdown()
+// feed samples through the plan in order, continuing from its current
+// recurrent state, and score the outputs with objective
+func (plan *EvaluationPlan) score(samples []*TrainingSample, sensorIndexes, actuatorIndexes []int, objective Objective) (float64, error) {
 
-	// calculate fitness
-	fitness := float64(1) / errorAccumulated
+	expected := make([][][]float64, len(samples))
+	actual := make([][][]float64, len(samples))
+	inputs := make([][]float64, len(plan.sensors))
+
+	for n, sample := range samples {
+		for i, input := range sample.SampleInputs {
+			inputs[sensorIndexes[i]] = input
+		}
+		outputs, err := plan.Evaluate(inputs)
+		if err != nil {
+			return 0, err
+		}
+		expected[n] = sample.ExpectedOutputs
+		actual[n] = make([][]float64, len(actuatorIndexes))
+		for j, index := range actuatorIndexes {
+			actual[n][j] = outputs[index]
+		}
+		logg.LogTo("DEBUG", "expected: %v actual: %v", expected[n], actual[n])
+	}
 
-	return fitness
+	return objective.Score(expected, actual), nil
 
 }
 
@@ -459,26 +562,41 @@ func (cortex *Cortex) FindInboundConnector(nodeId *NodeId) InboundConnector {
 	return nil
 }
 
-func (cortex *Cortex) SyncSensors() {
+func (cortex *Cortex) SyncSensors() error {
+	if err := cortex.failure(); err != nil {
+		return err
+	}
 	for _, sensor := range cortex.Sensors {
 		select {
 		case sensor.SyncChan <- true:
+		case cortex.err = <-cortex.errs:
+			return cortex.err
+		case <-cortex.done():
+			return cortex.ctx.Err()
 		case <-time.After(time.Second):
-			log.Panicf("Cortex unable to send Sync message to sensor %v", sensor)
+			return newNodeError(sensor.NodeId, ErrSensorSyncTimeout)
 		}
 	}
+	return nil
 
 }
 
-func (cortex *Cortex) SyncActuators() {
+func (cortex *Cortex) SyncActuators() error {
+	if err := cortex.failure(); err != nil {
+		return err
+	}
 	actuatorBarrier := cortex.createActuatorBarrier()
 	for {
 
 		select {
 		case senderNodeId := <-cortex.SyncChan:
 			actuatorBarrier[senderNodeId] = true
+		case cortex.err = <-cortex.errs:
+			return cortex.err
+		case <-cortex.done():
+			return cortex.ctx.Err()
 		case <-time.After(time.Second):
-			log.Panicf("Timeout waiting for actuator sync message")
+			return newNodeError(cortex.firstUnsatisfied(actuatorBarrier), ErrActuatorBarrierTimeout)
 		}
 
 		if cortex.isBarrierSatisfied(actuatorBarrier) {
@@ -486,6 +604,50 @@ func (cortex *Cortex) SyncActuators() {
 		}
 
 	}
+	return nil
+}
+
+// fail reports the error which stopped the goroutine of a node.  Only
+// the first error since Start is kept.  A node which is run on its own,
+// outside a started cortex, can only log it.
+func (cortex *Cortex) fail(err *NodeError) {
+	if cortex == nil || cortex.errs == nil {
+		logg.LogWarn("%v", err)
+		return
+	}
+	select {
+	case cortex.errs <- err:
+	default:
+	}
+}
+
+// recoverNode is deferred by the goroutine of every node, so that a
+// panic in a SensorFunction, ActuatorFunction or activation function
+// stops the node with an error rather than crashing the process
+func (cortex *Cortex) recoverNode(nodeId *NodeId) {
+	if r := recover(); r != nil {
+		cortex.fail(newNodeError(nodeId, fmt.Errorf("%w: %v", ErrNodePanicked, r)))
+	}
+}
+
+// the error of the first node which failed since Start, if any
+func (cortex *Cortex) failure() error {
+	if cortex.err == nil {
+		select {
+		case cortex.err = <-cortex.errs:
+		default:
+		}
+	}
+	return cortex.err
+}
+
+// done returns the Done channel of the context passed to Start, or a nil
+// channel (which blocks forever) if the cortex has not been started.
+func (cortex *Cortex) done() <-chan struct{} {
+	if cortex.ctx == nil {
+		return nil
+	}
+	return cortex.ctx.Done()
 }
 
 func (cortex *Cortex) Validate() bool {
@@ -515,6 +677,131 @@ func (cortex *Cortex) Validate() bool {
 	return true
 }
 
+// ValidateConnections checks that the connections between the nodes are
+// consistent: every inbound connection has a matching outbound connection
+// at the sender and vice versa, inbound weights match the output length
+// of the sender, actuators have VectorLength inbound connections, and
+// every neuron has an inbound connection from some node other than
+// itself.  Returns a *NodeError wrapping ErrInvalidConnection otherwise.
+func (cortex *Cortex) ValidateConnections() error {
+
+	senders := make(map[string]OutboundConnector)
+	for _, sensor := range cortex.Sensors {
+		senders[sensor.NodeId.UUID] = sensor
+	}
+	for _, neuron := range cortex.Neurons {
+		senders[neuron.NodeId.UUID] = neuron
+	}
+	receivers := make(map[string]InboundConnector)
+	for _, neuron := range cortex.Neurons {
+		receivers[neuron.NodeId.UUID] = neuron
+	}
+	for _, actuator := range cortex.Actuators {
+		receivers[actuator.NodeId.UUID] = actuator
+	}
+
+	invalid := func(nodeId *NodeId, format string, args ...interface{}) error {
+		err := fmt.Errorf("%w: %v", ErrInvalidConnection, fmt.Sprintf(format, args...))
+		return newNodeError(nodeId, err)
+	}
+
+	checkOutbound := func(nodeId *NodeId, outbound []*OutboundConnection) error {
+		for _, connection := range outbound {
+			receiver, ok := receivers[connection.NodeId.UUID]
+			if !ok {
+				return invalid(nodeId, "outbound to unknown node %v", connection.NodeId.UUID)
+			}
+			if findInbound(receiver, nodeId.UUID) == nil {
+				return invalid(nodeId, "%v has no matching inbound", connection.NodeId.UUID)
+			}
+		}
+		return nil
+	}
+
+	checkInbound := func(nodeId *NodeId, inbound []*InboundConnection) error {
+		for _, connection := range inbound {
+			sender, ok := senders[connection.NodeId.UUID]
+			if !ok {
+				return invalid(nodeId, "inbound from unknown node %v", connection.NodeId.UUID)
+			}
+			if findOutbound(sender, nodeId.UUID) == nil {
+				return invalid(nodeId, "%v has no matching outbound", connection.NodeId.UUID)
+			}
+		}
+		return nil
+	}
+
+	for _, sensor := range cortex.Sensors {
+		if err := checkOutbound(sensor.NodeId, sensor.Outbound); err != nil {
+			return err
+		}
+	}
+	for _, neuron := range cortex.Neurons {
+		if err := checkInbound(neuron.NodeId, neuron.Inbound); err != nil {
+			return err
+		}
+		if err := checkOutbound(neuron.NodeId, neuron.Outbound); err != nil {
+			return err
+		}
+	}
+	for _, actuator := range cortex.Actuators {
+		if err := checkInbound(actuator.NodeId, actuator.Inbound); err != nil {
+			return err
+		}
+	}
+
+	for _, neuron := range cortex.Neurons {
+		driven := false
+		for _, inbound := range neuron.Inbound {
+			expected := 1
+			if sensor := cortex.FindSensor(inbound.NodeId); sensor != nil {
+				expected = sensor.VectorLength
+			}
+			if len(inbound.Weights) != expected {
+				return invalid(neuron.NodeId, "%v has %d weights, expected %d",
+					inbound.NodeId.UUID,
+					len(inbound.Weights),
+					expected)
+			}
+			if inbound.NodeId.UUID != neuron.NodeId.UUID {
+				driven = true
+			}
+		}
+		if !driven {
+			return invalid(neuron.NodeId, "no inbound connection from another node")
+		}
+	}
+
+	for _, actuator := range cortex.Actuators {
+		if len(actuator.Inbound) != actuator.VectorLength {
+			return invalid(actuator.NodeId, "# of inbound (%d) != VectorLength (%d)",
+				len(actuator.Inbound),
+				actuator.VectorLength)
+		}
+	}
+
+	return nil
+
+}
+
+func findInbound(connector InboundConnector, uuid string) *InboundConnection {
+	for _, inbound := range connector.inbound() {
+		if inbound.NodeId.UUID == uuid {
+			return inbound
+		}
+	}
+	return nil
+}
+
+func findOutbound(connector OutboundConnector, uuid string) *OutboundConnection {
+	for _, outbound := range connector.outbound() {
+		if outbound.NodeId.UUID == uuid {
+			return outbound
+		}
+	}
+	return nil
+}
+
 func (cortex *Cortex) Repair() {
 	cortex.LinkNodesToCortex()
 }
@@ -547,6 +834,15 @@ func (cortex *Cortex) createActuatorBarrier() ActuatorBarrier {
 	return actuatorBarrier
 }
 
+func (cortex *Cortex) firstUnsatisfied(barrier ActuatorBarrier) *NodeId {
+	for _, actuator := range cortex.Actuators {
+		if !barrier[actuator.NodeId] {
+			return actuator.NodeId
+		}
+	}
+	return nil
+}
+
 func (cortex *Cortex) isBarrierSatisfied(barrier ActuatorBarrier) bool {
 	for _, value := range barrier {
 		if value == false {
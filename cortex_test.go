@@ -1,7 +1,9 @@
 package neurgo
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/couchbaselabs/go.assert"
 	"github.com/couchbaselabs/logg"
 	"io/ioutil"
@@ -34,7 +36,8 @@ func TestCortexCopy(t *testing.T) {
 
 	// get the fitness
 	examples := XnorTrainingSamples()
-	fitness := xnorCortex.Fitness(examples)
+	fitness, err := xnorCortex.Fitness(examples)
+	assert.True(t, err == nil)
 	assert.True(t, fitness >= FITNESS_THRESHOLD)
 	logg.LogTo("DEBUG", "Original cortex has fitness: %v", fitness)
 
@@ -57,7 +60,8 @@ func TestCortexCopy(t *testing.T) {
 	}
 
 	// get the fitness of the copied cortex
-	fitness = xnorCortexCopy.Fitness(examples)
+	fitness, err = xnorCortexCopy.Fitness(examples)
+	assert.True(t, err == nil)
 	assert.True(t, fitness >= FITNESS_THRESHOLD)
 
 }
@@ -74,6 +78,14 @@ func exampleCortexJson() string {
 
 }
 
+// same as exampleCortexJson, plus a recurrent connection from the
+// output neuron back to itself
+func exampleRecurrentCortexJson() string {
+	jsonString := `{"NodeId":{"UUID":"cortex","NodeType":"CORTEX","LayerIndex":0},"Sensors":[{"NodeId":{"UUID":"sensor","NodeType":"SENSOR","LayerIndex":0},"VectorLength":2,"Outbound":[{"NodeId":{"UUID":"hidden-neuron1","NodeType":"NEURON","LayerIndex":0.25}},{"NodeId":{"UUID":"hidden-neuron2","NodeType":"NEURON","LayerIndex":0.25}}]}],"Neurons":[{"NodeId":{"UUID":"hidden-neuron1","NodeType":"NEURON","LayerIndex":0.25},"Bias":-30,"Inbound":[{"NodeId":{"UUID":"sensor","NodeType":"SENSOR","LayerIndex":0},"Weights":[20,20]}],"Outbound":[{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35}}],"ActivationFunction":{"Name":"sigmoid"}},{"NodeId":{"UUID":"hidden-neuron2","NodeType":"NEURON","LayerIndex":0.25},"Bias":10,"Inbound":[{"NodeId":{"UUID":"sensor","NodeType":"SENSOR","LayerIndex":0},"Weights":[-20,-20]}],"Outbound":[{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35}}],"ActivationFunction":{"Name":"sigmoid"}},{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35},"Bias":-10,"Inbound":[{"NodeId":{"UUID":"hidden-neuron1","NodeType":"NEURON","LayerIndex":0.25},"Weights":[20]},{"NodeId":{"UUID":"hidden-neuron2","NodeType":"NEURON","LayerIndex":0.25},"Weights":[20]},{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35},"Weights":[0.0955837638877588]}],"Outbound":[{"NodeId":{"UUID":"actuator","NodeType":"ACTUATOR","LayerIndex":0.5}},{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35}}],"ActivationFunction":{"Name":"sigmoid"}}],"Actuators":[{"NodeId":{"UUID":"actuator","NodeType":"ACTUATOR","LayerIndex":0.5},"VectorLength":1,"Inbound":[{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35},"Weights":null}]}]}`
+	return jsonString
+
+}
+
 func TestCortexJsonUnmarshal(t *testing.T) {
 
 	jsonBytes := []byte(exampleCortexJson())
@@ -105,8 +117,96 @@ func TestCortexInit(t *testing.T) {
 	neuron := cortex.Neurons[0]
 	assert.True(t, neuron.DataChan != nil)
 
-	cortex.Run()
-	cortex.Shutdown()
+	err = cortex.Start(context.Background())
+	assert.True(t, err == nil)
+	err = cortex.Stop()
+	assert.True(t, err == nil)
+
+}
+
+func TestCortexStartStop(t *testing.T) {
+
+	xnorCortex := XnorCortex()
+
+	err := xnorCortex.Stop()
+	assert.True(t, err == ErrCortexNotStarted)
+
+	err = xnorCortex.Start(context.Background())
+	assert.True(t, err == nil)
+
+	err = xnorCortex.Start(context.Background())
+	assert.True(t, err == ErrCortexAlreadyStarted)
+
+	err = xnorCortex.Stop()
+	assert.True(t, err == nil)
+
+	// make sure it can be restarted after being stopped
+	err = xnorCortex.Start(context.Background())
+	assert.True(t, err == nil)
+	err = xnorCortex.Stop()
+	assert.True(t, err == nil)
+
+}
+
+func TestCortexStartInvalid(t *testing.T) {
+
+	xnorCortex := XnorCortex()
+
+	// actuator expects one inbound connection, give it none
+	actuator := xnorCortex.Actuators[0]
+	actuator.Inbound = nil
+
+	err := xnorCortex.Start(context.Background())
+	assert.True(t, err != nil)
+
+	var nodeErr *NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, actuator.NodeId.UUID)
+
+}
+
+func TestValidateConnections(t *testing.T) {
+
+	xnorCortex := XnorCortex()
+	assert.True(t, xnorCortex.ValidateConnections() == nil)
+
+	// the output neuron still has an inbound connection from the
+	// hidden neuron, but the hidden neuron no longer sends to it
+	hiddenNeuron := xnorCortex.Neurons[0]
+	hiddenNeuron.Outbound = nil
+
+	err := xnorCortex.ValidateConnections()
+	assert.True(t, errors.Is(err, ErrInvalidConnection))
+
+	var nodeErr *NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, "output-neuron")
+
+	err = xnorCortex.Start(context.Background())
+	assert.True(t, errors.Is(err, ErrInvalidConnection))
+
+	xnorCortex = XnorCortex()
+	xnorCortex.Neurons[2].Inbound[0].Weights = []float64{20, 20}
+	err = xnorCortex.ValidateConnections()
+	assert.True(t, errors.Is(err, ErrInvalidConnection))
+
+}
+
+func TestCortexContextCancel(t *testing.T) {
+
+	xnorCortex := XnorCortex()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := xnorCortex.Start(ctx)
+	assert.True(t, err == nil)
+
+	cancel()
+
+	err = xnorCortex.SyncActuators()
+	assert.True(t, err == context.Canceled)
+
+	err = xnorCortex.Stop()
+	assert.True(t, err == nil)
 
 }
 
@@ -131,13 +231,103 @@ func TestSyncActuators(t *testing.T) {
 
 	syncChan <- actuatorNodeId
 
-	cortex.SyncActuators()
+	err := cortex.SyncActuators()
+	assert.True(t, err == nil)
+
+}
+
+func TestSyncActuatorsTimeout(t *testing.T) {
+
+	xnorCortex := XnorCortex()
+
+	err := xnorCortex.Start(context.Background())
+	assert.True(t, err == nil)
+
+	// without syncing the sensors, the actuator never fires
+	err = xnorCortex.SyncActuators()
+	assert.True(t, errors.Is(err, ErrActuatorBarrierTimeout))
+
+	var nodeErr *NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, "actuator")
+
+	err = xnorCortex.Stop()
+	assert.True(t, err == nil)
+
+}
+
+func TestSyncSensorsTimeout(t *testing.T) {
+
+	xnorCortex := XnorCortex()
+
+	// the sensor goroutine is not running, so nobody reads the sync message
+	err := xnorCortex.SyncSensors()
+	assert.True(t, errors.Is(err, ErrSensorSyncTimeout))
+
+	var nodeErr *NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, "sensor")
+
+}
+
+func TestCortexNodeFailure(t *testing.T) {
+
+	// the sensor sends one value to neurons expecting two
+	xnorCortex := XnorCortex()
+	xnorCortex.Sensors[0].SensorFunction = func(syncCounter int) []float64 {
+		return []float64{1}
+	}
+
+	err := xnorCortex.Start(context.Background())
+	assert.True(t, err == nil)
+	assert.True(t, xnorCortex.SyncSensors() == nil)
+
+	err = xnorCortex.SyncActuators()
+	assert.True(t, errors.Is(err, ErrVectorLength))
+	var nodeErr *NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.NodeType, NodeType(NEURON))
+
+	// the failure sticks until the cortex is stopped
+	assert.True(t, errors.Is(xnorCortex.SyncSensors(), ErrVectorLength))
+	assert.True(t, errors.Is(xnorCortex.Stop(), ErrVectorLength))
+
+	// the cortex can be restarted once the problem is fixed
+	xnorCortex.Sensors[0].SensorFunction = func(syncCounter int) []float64 {
+		return []float64{1, 1}
+	}
+	err = xnorCortex.Start(context.Background())
+	assert.True(t, err == nil)
+	assert.True(t, xnorCortex.SyncSensors() == nil)
+	assert.True(t, xnorCortex.SyncActuators() == nil)
+	assert.True(t, xnorCortex.Stop() == nil)
+
+}
+
+func TestCortexNodePanic(t *testing.T) {
+
+	xnorCortex := XnorCortex()
+	xnorCortex.Actuators[0].ActuatorFunction = func(outputs []float64) {
+		panic("broken actuator")
+	}
+
+	err := xnorCortex.Start(context.Background())
+	assert.True(t, err == nil)
+	assert.True(t, xnorCortex.SyncSensors() == nil)
+
+	err = xnorCortex.SyncActuators()
+	assert.True(t, errors.Is(err, ErrNodePanicked))
+	var nodeErr *NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, "actuator")
+
+	assert.True(t, errors.Is(xnorCortex.Stop(), ErrNodePanicked))
 
 }
 
 func TestRecurrentCortex(t *testing.T) {
 
-	jsonString := `{"NodeId":{"UUID":"cortex","NodeType":"CORTEX","LayerIndex":0},"Sensors":[{"NodeId":{"UUID":"sensor","NodeType":"SENSOR","LayerIndex":0},"VectorLength":2,"Outbound":[{"NodeId":{"UUID":"hidden-neuron1","NodeType":"NEURON","LayerIndex":0.25}},{"NodeId":{"UUID":"hidden-neuron2","NodeType":"NEURON","LayerIndex":0.25}}]}],"Neurons":[{"NodeId":{"UUID":"hidden-neuron1","NodeType":"NEURON","LayerIndex":0.25},"Bias":-30,"Inbound":[{"NodeId":{"UUID":"sensor","NodeType":"SENSOR","LayerIndex":0},"Weights":[20,20]}],"Outbound":[{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35}}],"ActivationFunction":{"Name":"sigmoid"}},{"NodeId":{"UUID":"hidden-neuron2","NodeType":"NEURON","LayerIndex":0.25},"Bias":10,"Inbound":[{"NodeId":{"UUID":"sensor","NodeType":"SENSOR","LayerIndex":0},"Weights":[-20,-20]}],"Outbound":[{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35}}],"ActivationFunction":{"Name":"sigmoid"}},{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35},"Bias":-10,"Inbound":[{"NodeId":{"UUID":"hidden-neuron1","NodeType":"NEURON","LayerIndex":0.25},"Weights":[20]},{"NodeId":{"UUID":"hidden-neuron2","NodeType":"NEURON","LayerIndex":0.25},"Weights":[20]},{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35},"Weights":[0.0955837638877588]}],"Outbound":[{"NodeId":{"UUID":"actuator","NodeType":"ACTUATOR","LayerIndex":0.5}},{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35}}],"ActivationFunction":{"Name":"sigmoid"}}],"Actuators":[{"NodeId":{"UUID":"actuator","NodeType":"ACTUATOR","LayerIndex":0.5},"VectorLength":1,"Inbound":[{"NodeId":{"UUID":"output-neuron","NodeType":"NEURON","LayerIndex":0.35},"Weights":null}]}]}`
+	jsonString := exampleRecurrentCortexJson()
 
 	jsonBytes := []byte(jsonString)
 
@@ -152,7 +342,8 @@ func TestRecurrentCortex(t *testing.T) {
 
 	cortex.LinkNodesToCortex()
 
-	fitness := cortex.Fitness(examples)
+	fitness, err := cortex.Fitness(examples)
+	assert.True(t, err == nil)
 	assert.True(t, fitness >= 0)
 
 }
@@ -167,7 +358,8 @@ func TestCortexFitness(t *testing.T) {
 	log.Printf("training samples: %v", examples)
 
 	// get the fitness
-	fitness := xnorCortex.Fitness(examples)
+	fitness, err := xnorCortex.Fitness(examples)
+	assert.True(t, err == nil)
 	log.Printf("cortex fitness: %v", fitness)
 
 	assert.True(t, fitness >= 1e8)
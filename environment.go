@@ -0,0 +1,211 @@
+package neurgo
+
+import (
+	"context"
+	"fmt"
+)
+
+// An Environment is something a cortex interacts with to earn reward,
+// rather than learning from TrainingSamples, as in reinforcement
+// learning.  On every step of an episode the cortex observes the
+// environment through its sensors, and acts on it through its actuators.
+//
+// The EpisodeRunner calls the methods of an Environment from a single
+// goroutine, in a fixed order on every step: Observe for each sensor,
+// then Act for each actuator, then Reward.
+type Environment interface {
+
+	// Reset starts a new episode
+	Reset()
+
+	// Observe returns the input for cortex.Sensors[sensor], which must
+	// be VectorLength long
+	Observe(sensor int) []float64
+
+	// Act is given the output of cortex.Actuators[actuator].  Once every
+	// actuator has acted, the environment should advance by one step.
+	Act(actuator int, outputs []float64)
+
+	// Reward returns the reward earned by the latest step
+	Reward() float64
+
+	// Done is true once the episode is over
+	Done() bool
+}
+
+// EpisodeRunner runs a cortex through episodes of an Environment
+type EpisodeRunner struct {
+
+	// number of episodes to run
+	Episodes int
+
+	// maximum number of steps per episode, or 0 to run every episode
+	// until the environment is Done
+	MaxSteps int
+
+	// evaluate the cortex with an EvaluationPlan rather than running it
+	// with a goroutine per node, which is much faster and gives the same
+	// outputs
+	Compiled bool
+}
+
+func NewEpisodeRunner() *EpisodeRunner {
+	return &EpisodeRunner{
+		Episodes: 1,
+		MaxSteps: 1000,
+	}
+}
+
+// Run returns the reward earned by cortex per episode, ie the cumulative
+// reward of the episode if there is only one.  Unless the runner is
+// Compiled, the cortex is started for each episode and stopped at the
+// end of it, with its SensorFunctions wired to Observe and its
+// ActuatorFunctions to Act for the duration of the run.
+func (runner *EpisodeRunner) Run(cortex *Cortex, environment Environment) (float64, error) {
+
+	if runner.Episodes <= 0 {
+		return 0, nil
+	}
+
+	episode := runner.runEpisode
+	if runner.Compiled {
+		plan, err := cortex.Compile()
+		if err != nil {
+			return 0, err
+		}
+		episode = func(cortex *Cortex, environment Environment) (float64, error) {
+			plan.Reset()
+			return runner.runCompiledEpisode(plan, cortex, environment)
+		}
+	}
+
+	total := float64(0)
+	for i := 0; i < runner.Episodes; i++ {
+		reward, err := episode(cortex, environment)
+		if err != nil {
+			return 0, fmt.Errorf("episode %d: %w", i, err)
+		}
+		total += reward
+	}
+	return total / float64(runner.Episodes), nil
+
+}
+
+// EnvironmentFitness returns a FitnessFunc which scores a cortex by the
+// reward runner.Run earns it.  Since the FitnessFunc may be called
+// concurrently, newEnvironment is called to create a separate
+// environment for every evaluation.
+func EnvironmentFitness(runner *EpisodeRunner, newEnvironment func() Environment) FitnessFunc {
+	return func(cortex *Cortex) (float64, error) {
+		return runner.Run(cortex, newEnvironment())
+	}
+}
+
+// run a single episode on the channel based runtime
+func (runner *EpisodeRunner) runEpisode(cortex *Cortex, environment Environment) (float64, error) {
+
+	observations := make([][]float64, len(cortex.Sensors))
+	actions := make([][]float64, len(cortex.Actuators))
+
+	for i, sensor := range cortex.Sensors {
+		defer func(sensor *Sensor, original SensorFunction) {
+			sensor.SensorFunction = original
+		}(sensor, sensor.SensorFunction)
+		i := i
+		sensor.SensorFunction = func(syncCounter int) []float64 {
+			return observations[i]
+		}
+	}
+	for j, actuator := range cortex.Actuators {
+		defer func(actuator *Actuator, original ActuatorFunction) {
+			actuator.ActuatorFunction = original
+		}(actuator, actuator.ActuatorFunction)
+		j := j
+		actuator.ActuatorFunction = func(outputs []float64) {
+			actions[j] = outputs
+		}
+	}
+
+	if err := cortex.Start(context.Background()); err != nil {
+		return 0, err
+	}
+	defer cortex.Stop()
+
+	return runner.steps(cortex, environment, func() error {
+		for i := range observations {
+			observations[i] = environment.Observe(i)
+		}
+		if err := checkObservations(cortex, observations); err != nil {
+			return err
+		}
+		if err := cortex.SyncSensors(); err != nil {
+			return err
+		}
+		if err := cortex.SyncActuators(); err != nil {
+			return err
+		}
+		for j, action := range actions {
+			environment.Act(j, action)
+		}
+		return nil
+	})
+
+}
+
+// run a single episode with an EvaluationPlan
+func (runner *EpisodeRunner) runCompiledEpisode(plan *EvaluationPlan, cortex *Cortex, environment Environment) (float64, error) {
+
+	observations := make([][]float64, len(cortex.Sensors))
+
+	return runner.steps(cortex, environment, func() error {
+		for i := range observations {
+			observations[i] = environment.Observe(i)
+		}
+		if err := checkObservations(cortex, observations); err != nil {
+			return err
+		}
+		actions, err := plan.Evaluate(observations)
+		if err != nil {
+			return err
+		}
+		for j, action := range actions {
+			environment.Act(j, action)
+		}
+		return nil
+	})
+
+}
+
+// reset the environment and call step until the episode is over,
+// returning the cumulative reward
+func (runner *EpisodeRunner) steps(cortex *Cortex, environment Environment, step func() error) (float64, error) {
+
+	environment.Reset()
+
+	total := float64(0)
+	for n := 0; runner.MaxSteps <= 0 || n < runner.MaxSteps; n++ {
+		if environment.Done() {
+			break
+		}
+		if err := step(); err != nil {
+			return 0, err
+		}
+		total += environment.Reward()
+	}
+	return total, nil
+
+}
+
+func checkObservations(cortex *Cortex, observations [][]float64) error {
+	for i, observation := range observations {
+		sensor := cortex.Sensors[i]
+		if len(observation) != sensor.VectorLength {
+			err := fmt.Errorf("%w: observation has length %d, expected %d",
+				ErrObservationShape,
+				len(observation),
+				sensor.VectorLength)
+			return newNodeError(sensor.NodeId, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package neurgo
+
+import (
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"testing"
+)
+
+// xnorEnvironment presents each of the xnor samples in turn, and rewards
+// the cortex for getting close to the expected output
+type xnorEnvironment struct {
+	samples []*TrainingSample
+	step    int
+	reward  float64
+}
+
+func (env *xnorEnvironment) Reset() {
+	env.samples = XnorTrainingSamples()
+	env.step = 0
+}
+
+func (env *xnorEnvironment) Observe(sensor int) []float64 {
+	return env.samples[env.step].SampleInputs[sensor]
+}
+
+func (env *xnorEnvironment) Act(actuator int, outputs []float64) {
+	expected := env.samples[env.step].ExpectedOutputs[actuator]
+	env.reward = 1 - math.Abs(expected[0]-outputs[0])
+	env.step += 1
+}
+
+func (env *xnorEnvironment) Reward() float64 {
+	return env.reward
+}
+
+func (env *xnorEnvironment) Done() bool {
+	return env.step >= len(env.samples)
+}
+
+func TestEpisodeRunner(t *testing.T) {
+
+	cortex := XnorCortex()
+	runner := NewEpisodeRunner()
+	runner.Episodes = 2
+
+	reward, err := runner.Run(cortex, &xnorEnvironment{})
+	assert.True(t, err == nil)
+	assert.True(t, EqualsWithMaxDelta(reward, 4, 0.01))
+
+	// the compiled evaluator earns exactly the same reward
+	runner.Compiled = true
+	compiledReward, err := runner.Run(cortex, &xnorEnvironment{})
+	assert.True(t, err == nil)
+	assert.True(t, EqualsWithMaxDelta(compiledReward, reward, 1e-9))
+
+	// MaxSteps cuts episodes short
+	runner.MaxSteps = 2
+	shortReward, err := runner.Run(cortex, &xnorEnvironment{})
+	assert.True(t, err == nil)
+	assert.True(t, EqualsWithMaxDelta(shortReward, 2, 0.01))
+
+	// the sensor functions are restored afterwards
+	runner.Compiled = false
+	cortex.Sensors[0].SensorFunction = func(syncCounter int) []float64 {
+		return []float64{42, 42}
+	}
+	_, err = runner.Run(cortex, &xnorEnvironment{})
+	assert.True(t, err == nil)
+	assert.Equals(t, cortex.Sensors[0].SensorFunction(0)[0], 42.0)
+
+}
+
+func TestEpisodeRunnerObservationShape(t *testing.T) {
+
+	cortex := XnorCortex()
+	env := &xnorEnvironment{}
+	runner := NewEpisodeRunner()
+
+	// the xnor cortex has a single sensor expecting 2 inputs
+	cortex.Sensors[0].VectorLength = 3
+	cortex.Neurons[0].Inbound[0].Weights = []float64{20, 20, 0}
+	cortex.Neurons[1].Inbound[0].Weights = []float64{-20, -20, 0}
+
+	for _, compiled := range []bool{false, true} {
+		runner.Compiled = compiled
+		_, err := runner.Run(cortex, env)
+		assert.True(t, errors.Is(err, ErrObservationShape))
+		var nodeErr *NodeError
+		assert.True(t, errors.As(err, &nodeErr))
+		assert.Equals(t, nodeErr.NodeId.UUID, cortex.Sensors[0].NodeId.UUID)
+	}
+
+}
+
+func TestEnvironmentFitness(t *testing.T) {
+
+	runner := NewEpisodeRunner()
+	runner.Compiled = true
+	fitness := EnvironmentFitness(runner, func() Environment {
+		return &xnorEnvironment{}
+	})
+
+	trained, err := fitness(XnorCortex())
+	assert.True(t, err == nil)
+	untrained, err := fitness(XnorCortexUntrained())
+	assert.True(t, err == nil)
+	assert.True(t, trained > untrained)
+
+}
@@ -0,0 +1,59 @@
+package neurgo
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCortexNotInitialized   = errors.New("cortex has not been initialized")
+	ErrCortexInvalid          = errors.New("cortex failed validation")
+	ErrCortexAlreadyStarted   = errors.New("cortex has already been started")
+	ErrCortexNotStarted       = errors.New("cortex has not been started")
+	ErrSensorSyncTimeout      = errors.New("timeout sending sync message to sensor")
+	ErrActuatorBarrierTimeout = errors.New("timeout waiting for actuator sync message")
+	ErrInvalidConnection      = errors.New("invalid connection")
+	ErrMissingSensorInput     = errors.New("no input given for sensor")
+	ErrUnknownSensor          = errors.New("input given for unknown sensor")
+	ErrSampleShape            = errors.New("training sample does not match cortex")
+	ErrNotFeedForward         = errors.New("cortex is not feedforward")
+	ErrParameterCount         = errors.New("wrong number of parameters")
+	ErrObservationShape       = errors.New("observation does not match sensor")
+	ErrNoLearningRate         = errors.New("trainer does not have a learning rate")
+	ErrNotDifferentiable      = errors.New("not differentiable")
+	ErrNoCheckpoint           = errors.New("no checkpoint found")
+	ErrNotCheckpointable      = errors.New("trainer cannot be checkpointed")
+	ErrVectorLength           = errors.New("vector has the wrong length")
+	ErrNodePanicked           = errors.New("node panicked")
+	ErrNodeStopped            = errors.New("node has already stopped")
+	ErrPopulationSize         = errors.New("population size is too small")
+	ErrNotStarted             = errors.New("trainer has not been started")
+	ErrCheckpointInvalid      = errors.New("checkpoint is invalid")
+)
+
+// NodeError ties an error to the node that caused it, so that callers
+// can find out which sensor, neuron or actuator misbehaved.  Use
+// errors.Is to compare against the Err* values above, and errors.As
+// to recover the offending NodeId.
+type NodeError struct {
+	NodeId *NodeId
+	Err    error
+}
+
+func (e *NodeError) Error() string {
+	if e.NodeId == nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %v", e.NodeId.UUID, e.Err)
+}
+
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+func newNodeError(nodeId *NodeId, err error) *NodeError {
+	return &NodeError{
+		NodeId: nodeId,
+		Err:    err,
+	}
+}
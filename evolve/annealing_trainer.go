@@ -0,0 +1,287 @@
+package evolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/couchbaselabs/logg"
+	ng "github.com/maxxk/neurgo"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// A CoolingSchedule gives the temperature of simulated annealing at each
+// iteration, starting from 0
+type CoolingSchedule func(iteration int) float64
+
+// ExponentialCooling starts at initial and multiplies the temperature by
+// rate at every iteration
+func ExponentialCooling(initial, rate float64) CoolingSchedule {
+	return func(iteration int) float64 {
+		return initial * math.Pow(rate, float64(iteration))
+	}
+}
+
+// LinearCooling starts at initial and reaches 0 after the given number of
+// iterations
+func LinearCooling(initial float64, iterations int) CoolingSchedule {
+	return func(iteration int) float64 {
+		return math.Max(0, initial*(1-float64(iteration)/float64(iterations)))
+	}
+}
+
+// SimulatedAnnealingTrainer evolves a single cortex by simulated
+// annealing (see http://en.wikipedia.org/wiki/Simulated_annealing).  Each
+// iteration proposes a mutated copy of the current cortex, which replaces
+// it if it is fitter, and otherwise with probability
+// exp(-(fitness lost) / temperature).  The fittest cortex seen is
+// returned, which need not be the current one at the end.
+type SimulatedAnnealingTrainer struct {
+
+	// number of candidates proposed
+	MaxIterations int
+
+	// stop training as soon as a cortex reaches this fitness
+	TargetFitness float64
+
+	// the temperature at each iteration, which is measured in units of
+	// fitness, so should be scaled to the fitness differences expected
+	// between neighbouring candidates
+	Schedule CoolingSchedule
+
+	// probability that a candidate has its topology changed by one of
+	// the StructuralMutations rather than its weights and biases
+	// perturbed.  0 keeps the topology fixed.
+	StructuralRate float64
+
+	// the topological mutations to choose from, or nil to use
+	// AddNeuron, AddInlink and AddOutlink
+	StructuralMutations []Mutation
+
+	// function to maximize, or nil to use the fitness of the cortex on
+	// the training samples
+	Fitness ng.FitnessFunc
+
+	// source of all random choices, or nil to seed one from the clock
+	Rand *rand.Rand
+
+	// which sensors and actuators the samples correspond to, or nil
+	// to map them by index.  See ng.SampleMapping.
+	Mapping *ng.SampleMapping
+
+	// the search begun by Start, which StepGeneration carries on
+	search *annealingSearch
+}
+
+func NewSimulatedAnnealingTrainer() *SimulatedAnnealingTrainer {
+	return &SimulatedAnnealingTrainer{
+		MaxIterations: 5000,
+		TargetFitness: 100,
+		Schedule:      ExponentialCooling(0.1, 0.995),
+	}
+}
+
+// Train returns an evolved copy of cortex, or nil if the cortex could
+// not be trained.  Use Fit to find out why.
+func (trainer *SimulatedAnnealingTrainer) Train(cortex *ng.Cortex, examples []*ng.TrainingSample) *ng.Cortex {
+	trained, err := trainer.Fit(cortex, examples)
+	if err != nil {
+		logg.LogWarn("SimulatedAnnealingTrainer unable to train cortex: %v", err)
+		return nil
+	}
+	return trained
+}
+
+// Fit is the same as Train, but returns an error rather than nil.  The
+// examples are ignored if the trainer has its own Fitness function.
+func (trainer *SimulatedAnnealingTrainer) Fit(cortex *ng.Cortex, examples []*ng.TrainingSample) (*ng.Cortex, error) {
+
+	search, err := trainer.newSearch(cortex, examples)
+	if err != nil {
+		return nil, err
+	}
+	best, bestFitness := search.current, search.currentFitness
+
+	for search.iteration < trainer.MaxIterations {
+
+		if bestFitness >= trainer.TargetFitness {
+			logg.LogTo("DEBUG", "SimulatedAnnealingTrainer reached fitness %v after %d iterations", bestFitness, search.iteration)
+			break
+		}
+
+		if err := search.step(); err != nil {
+			return nil, err
+		}
+		if search.currentFitness > bestFitness {
+			best, bestFitness = search.current, search.currentFitness
+		}
+
+	}
+
+	return best, nil
+
+}
+
+// Start prepares to evolve a copy of cortex one iteration at a time with
+// StepGeneration, so that the trainer can be run by an ng.TrainingSession
+// instead of Fit.  MaxIterations and TargetFitness are then up to the
+// session.
+func (trainer *SimulatedAnnealingTrainer) Start(cortex *ng.Cortex, examples []*ng.TrainingSample) error {
+	search, err := trainer.newSearch(cortex, examples)
+	if err != nil {
+		return err
+	}
+	trainer.search = search
+	return nil
+}
+
+// StepGeneration proposes a candidate and decides whether to accept it,
+// and returns the current cortex along with its fitness.  This makes the
+// trainer an ng.Generational, whose session keeps track of the fittest
+// cortex seen.  Returns ng.ErrNotStarted if Start hasn't been called.
+func (trainer *SimulatedAnnealingTrainer) StepGeneration() (*ng.Cortex, float64, error) {
+	search := trainer.search
+	if search == nil {
+		return nil, 0, ng.ErrNotStarted
+	}
+	if err := search.step(); err != nil {
+		return nil, 0, err
+	}
+	return search.current, search.currentFitness, nil
+}
+
+// MarshalCheckpoint saves the state of the annealing begun by Start: the
+// current cortex, its fitness and the number of iterations so far, which
+// the temperature depends on, so that training can be resumed by an
+// ng.TrainingSession.  The random number generator is not saved: for
+// training to be resumed deterministically, Rand must wrap the session's
+// RandSource.
+func (trainer *SimulatedAnnealingTrainer) MarshalCheckpoint() ([]byte, error) {
+	search := trainer.search
+	if search == nil {
+		return nil, ng.ErrNotStarted
+	}
+	return json.Marshal(annealingCheckpoint{
+		Iteration:      search.iteration,
+		Current:        search.current,
+		CurrentFitness: ng.EncodableFloat(search.currentFitness),
+	})
+}
+
+// UnmarshalCheckpoint restores what MarshalCheckpoint saved, into
+// annealing begun by Start, which must be called first.
+func (trainer *SimulatedAnnealingTrainer) UnmarshalCheckpoint(data []byte) error {
+	search := trainer.search
+	if search == nil {
+		return ng.ErrNotStarted
+	}
+	checkpoint := annealingCheckpoint{}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return err
+	}
+	if checkpoint.Current == nil {
+		return fmt.Errorf("%w: no current cortex", ng.ErrCheckpointInvalid)
+	}
+	checkpoint.Current.LinkNodesToCortex()
+	search.iteration = checkpoint.Iteration
+	search.current = checkpoint.Current
+	search.currentFitness = float64(checkpoint.CurrentFitness)
+	return nil
+}
+
+// the state of simulated annealing saved in a checkpoint
+type annealingCheckpoint struct {
+	Iteration      int
+	Current        *ng.Cortex
+	CurrentFitness ng.EncodableFloat
+}
+
+// start annealing from a copy of cortex
+func (trainer *SimulatedAnnealingTrainer) newSearch(cortex *ng.Cortex, examples []*ng.TrainingSample) (*annealingSearch, error) {
+
+	rng := trainer.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	fitness := trainer.Fitness
+	if fitness == nil {
+		fitness = ng.SampleFitness(examples, trainer.Mapping)
+	}
+	structural := trainer.StructuralMutations
+	if structural == nil {
+		structural = []Mutation{AddNeuron, AddInlink, AddOutlink}
+	}
+
+	current := cortex.Copy()
+	if err := Validate(current); err != nil {
+		return nil, err
+	}
+	currentFitness, err := fitness(current)
+	if err != nil {
+		return nil, err
+	}
+
+	return &annealingSearch{
+		trainer:        trainer,
+		fitness:        fitness,
+		structural:     structural,
+		rng:            rng,
+		current:        current,
+		currentFitness: currentFitness,
+	}, nil
+
+}
+
+// simulated annealing in progress
+type annealingSearch struct {
+	trainer        *SimulatedAnnealingTrainer
+	fitness        ng.FitnessFunc
+	structural     []Mutation
+	rng            *rand.Rand
+	iteration      int
+	current        *ng.Cortex
+	currentFitness float64
+}
+
+// propose a mutated copy of the current cortex, and move to it if it's
+// accepted
+func (search *annealingSearch) step() error {
+
+	iteration := search.iteration
+	search.iteration += 1
+
+	candidate := search.current.Copy()
+	mutations := ParameterMutations()
+	if search.rng.Float64() < search.trainer.StructuralRate {
+		mutations = search.structural
+	}
+	if !Mutate(candidate, search.rng, mutations) {
+		return nil
+	}
+
+	candidateFitness, err := search.fitness(candidate)
+	if err != nil {
+		return err
+	}
+
+	if search.trainer.accept(search.currentFitness, candidateFitness, iteration, search.rng) {
+		search.current, search.currentFitness = candidate, candidateFitness
+	}
+	return nil
+
+}
+
+// the Metropolis criterion
+func (trainer *SimulatedAnnealingTrainer) accept(currentFitness, candidateFitness float64, iteration int, rng *rand.Rand) bool {
+	if candidateFitness >= currentFitness {
+		return true
+	}
+	if math.IsNaN(candidateFitness) {
+		return false
+	}
+	temperature := trainer.Schedule(iteration)
+	if temperature <= 0 {
+		return false
+	}
+	return rng.Float64() < math.Exp((candidateFitness-currentFitness)/temperature)
+}
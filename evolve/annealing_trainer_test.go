@@ -0,0 +1,102 @@
+package evolve
+
+import (
+	"context"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"math/rand"
+	"testing"
+)
+
+func TestCoolingSchedules(t *testing.T) {
+
+	exponential := ExponentialCooling(2, 0.5)
+	assert.Equals(t, exponential(0), 2.0)
+	assert.Equals(t, exponential(2), 0.5)
+
+	linear := LinearCooling(2, 4)
+	assert.Equals(t, linear(0), 2.0)
+	assert.Equals(t, linear(2), 1.0)
+	assert.Equals(t, linear(5), 0.0)
+
+}
+
+func TestSimulatedAnnealingTrainerXnor(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	untrained := ng.SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+
+	trainer := NewSimulatedAnnealingTrainer()
+	trainer.StructuralRate = 0.1
+	trainer.Rand = rand.New(rand.NewSource(1))
+
+	trained, err := trainer.Fit(untrained, examples)
+	assert.True(t, err == nil)
+	assert.True(t, Validate(trained) == nil)
+
+	fitness, err := trained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= trainer.TargetFitness)
+
+	// the cortex that was passed in should not have been modified
+	untrainedFitness, err := untrained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, untrainedFitness < fitness)
+
+}
+
+func TestSimulatedAnnealingTrainerReproducible(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	untrained := ng.SeededXnorCortexUntrained(rand.New(rand.NewSource(2)))
+
+	train := func() *ng.Cortex {
+		trainer := NewSimulatedAnnealingTrainer()
+		trainer.MaxIterations = 200
+		trainer.StructuralRate = 0.5
+		trainer.Rand = rand.New(rand.NewSource(3))
+		return trainer.Train(untrained, examples)
+	}
+
+	// new neurons get random UUIDs, which changes the parameter layout,
+	// so compare the outcome rather than the parameters
+	first, second := train(), train()
+	assert.Equals(t, len(first.Neurons), len(second.Neurons))
+	firstFitness, err := first.Fitness(examples)
+	assert.True(t, err == nil)
+	secondFitness, err := second.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.Equals(t, firstFitness, secondFitness)
+
+}
+
+func TestSimulatedAnnealingTrainerSession(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+
+	trainer := NewSimulatedAnnealingTrainer()
+	trainer.Rand = rand.New(rand.NewSource(1))
+
+	_, _, err := trainer.StepGeneration()
+	assert.True(t, errors.Is(err, ng.ErrNotStarted))
+	assert.True(t, trainer.Start(ng.SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), examples) == nil)
+
+	// the current cortex can get worse, but the session keeps the best
+	session := ng.NewTrainingSession()
+	worse := 0
+	session.OnGeneration = func(metrics *ng.EpochMetrics) {
+		if metrics.Fitness < session.BestFitness {
+			worse += 1
+		}
+	}
+
+	best, err := session.Evolve(context.Background(), trainer, 300, 0)
+	assert.True(t, err == nil)
+	assert.Equals(t, len(session.History), 300)
+	assert.True(t, worse > 0)
+	fitness, err := best.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.Equals(t, fitness, session.BestFitness)
+
+}
@@ -0,0 +1,256 @@
+package evolve
+
+import (
+	"encoding/json"
+	"fmt"
+	ng "github.com/maxxk/neurgo"
+)
+
+// the state of a population saved in a checkpoint, with members and
+// species referring to each other by index
+type populationCheckpoint struct {
+	Generation    int
+	NextSpeciesId int
+	Members       []*memberCheckpoint
+	Species       []*speciesCheckpoint
+	History       []*statsCheckpoint
+
+	// the archive of the NoveltySearch, if there is one
+	Archive [][]ng.EncodableFloat `json:",omitempty"`
+}
+
+type memberCheckpoint struct {
+	Cortex          *ng.Cortex
+	Fitness         ng.EncodableFloat
+	AdjustedFitness ng.EncodableFloat
+	Scores          []ng.EncodableFloat
+	Rank            int
+	Crowding        ng.EncodableFloat
+	Behavior        []ng.EncodableFloat
+	Novelty         ng.EncodableFloat
+	Blended         ng.EncodableFloat
+}
+
+type speciesCheckpoint struct {
+	Id             int
+	Representative *ng.Cortex
+	Members        []int
+}
+
+type statsCheckpoint struct {
+	Generation   int
+	BestFitness  ng.EncodableFloat
+	MeanFitness  ng.EncodableFloat
+	WorstFitness ng.EncodableFloat
+	NumSpecies   int
+	MeanNeurons  ng.EncodableFloat
+	Best         *ng.Cortex
+}
+
+// MarshalCheckpoint saves the members, species, generation and History
+// of the population, and the Archive of its NoveltySearch, so that
+// evolution can be resumed by an ng.TrainingSession.  The random number
+// generator is not saved: for evolution to be resumed deterministically,
+// Rand must wrap the session's RandSource.
+func (population *Population) MarshalCheckpoint() ([]byte, error) {
+
+	checkpoint := populationCheckpoint{
+		Generation:    population.Generation,
+		NextSpeciesId: population.nextSpeciesId,
+	}
+
+	indexes := make(map[*Member]int)
+	for i, member := range population.Members {
+		indexes[member] = i
+		checkpoint.Members = append(checkpoint.Members, &memberCheckpoint{
+			Cortex:          member.Cortex,
+			Fitness:         ng.EncodableFloat(member.Fitness),
+			AdjustedFitness: ng.EncodableFloat(member.AdjustedFitness),
+			Scores:          encodableFloats(member.Scores),
+			Rank:            member.Rank,
+			Crowding:        ng.EncodableFloat(member.Crowding),
+			Behavior:        encodableFloats(member.Behavior),
+			Novelty:         ng.EncodableFloat(member.Novelty),
+			Blended:         ng.EncodableFloat(member.blended),
+		})
+	}
+
+	for _, species := range population.Species {
+		encoded := &speciesCheckpoint{
+			Id:             species.Id,
+			Representative: species.Representative,
+			Members:        make([]int, 0, len(species.Members)),
+		}
+		for _, member := range species.Members {
+			if i, ok := indexes[member]; ok {
+				encoded.Members = append(encoded.Members, i)
+			}
+		}
+		checkpoint.Species = append(checkpoint.Species, encoded)
+	}
+
+	for _, stats := range population.History {
+		checkpoint.History = append(checkpoint.History, &statsCheckpoint{
+			Generation:   stats.Generation,
+			BestFitness:  ng.EncodableFloat(stats.BestFitness),
+			MeanFitness:  ng.EncodableFloat(stats.MeanFitness),
+			WorstFitness: ng.EncodableFloat(stats.WorstFitness),
+			NumSpecies:   stats.NumSpecies,
+			MeanNeurons:  ng.EncodableFloat(stats.MeanNeurons),
+			Best:         stats.Best,
+		})
+	}
+
+	if population.Novelty != nil {
+		for _, behavior := range population.Novelty.Archive {
+			checkpoint.Archive = append(checkpoint.Archive, encodableFloats(behavior))
+		}
+	}
+
+	return json.Marshal(checkpoint)
+
+}
+
+// UnmarshalCheckpoint restores what MarshalCheckpoint saved.  The
+// population must have been set up the same way as the one which saved
+// the checkpoint, with the same Fitness or Objectives, Novelty and so on.
+// Returns ng.ErrCheckpointInvalid, leaving the population untouched, if
+// the checkpoint is missing a cortex or its species refer to members
+// which it doesn't have.
+func (population *Population) UnmarshalCheckpoint(data []byte) error {
+
+	checkpoint := populationCheckpoint{}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return err
+	}
+	if err := checkpoint.validate(); err != nil {
+		return err
+	}
+	checkpoint.linkNodesToCortexes()
+
+	population.Generation = checkpoint.Generation
+	population.nextSpeciesId = checkpoint.NextSpeciesId
+
+	population.Members = make([]*Member, len(checkpoint.Members))
+	for i, encoded := range checkpoint.Members {
+		population.Members[i] = &Member{
+			Cortex:          encoded.Cortex,
+			Fitness:         float64(encoded.Fitness),
+			AdjustedFitness: float64(encoded.AdjustedFitness),
+			Scores:          decodeFloats(encoded.Scores),
+			Rank:            encoded.Rank,
+			Crowding:        float64(encoded.Crowding),
+			Behavior:        decodeFloats(encoded.Behavior),
+			Novelty:         float64(encoded.Novelty),
+			blended:         float64(encoded.Blended),
+		}
+	}
+
+	population.Species = make([]*Species, len(checkpoint.Species))
+	for i, encoded := range checkpoint.Species {
+		species := &Species{
+			Id:             encoded.Id,
+			Representative: encoded.Representative,
+		}
+		for _, index := range encoded.Members {
+			member := population.Members[index]
+			member.Species = species
+			species.Members = append(species.Members, member)
+		}
+		population.Species[i] = species
+	}
+
+	population.History = make([]*GenerationStats, len(checkpoint.History))
+	for i, encoded := range checkpoint.History {
+		population.History[i] = &GenerationStats{
+			Generation:   encoded.Generation,
+			BestFitness:  float64(encoded.BestFitness),
+			MeanFitness:  float64(encoded.MeanFitness),
+			WorstFitness: float64(encoded.WorstFitness),
+			NumSpecies:   encoded.NumSpecies,
+			MeanNeurons:  float64(encoded.MeanNeurons),
+			Best:         encoded.Best,
+		}
+	}
+
+	if population.Novelty != nil {
+		population.Novelty.Archive = nil
+		for _, behavior := range checkpoint.Archive {
+			population.Novelty.Archive = append(population.Novelty.Archive, decodeFloats(behavior))
+		}
+	}
+
+	return nil
+
+}
+
+// check that every member has a cortex, and every species refers to
+// members which exist
+func (checkpoint *populationCheckpoint) validate() error {
+	for i, member := range checkpoint.Members {
+		if member == nil || member.Cortex == nil {
+			return fmt.Errorf("%w: member %d has no cortex", ng.ErrCheckpointInvalid, i)
+		}
+	}
+	for _, species := range checkpoint.Species {
+		if species == nil {
+			return fmt.Errorf("%w: missing species", ng.ErrCheckpointInvalid)
+		}
+		for _, index := range species.Members {
+			if index < 0 || index >= len(checkpoint.Members) {
+				return fmt.Errorf("%w: species %d refers to member %d of %d",
+					ng.ErrCheckpointInvalid,
+					species.Id,
+					index,
+					len(checkpoint.Members))
+			}
+		}
+	}
+	for _, stats := range checkpoint.History {
+		if stats == nil {
+			return fmt.Errorf("%w: missing generation stats", ng.ErrCheckpointInvalid)
+		}
+	}
+	return nil
+}
+
+// point the nodes of every decoded cortex back at their cortex, as
+// ng.NewCortexFromJSONSBytes does, since the links aren't saved
+func (checkpoint *populationCheckpoint) linkNodesToCortexes() {
+	link := func(cortex *ng.Cortex) {
+		if cortex != nil {
+			cortex.LinkNodesToCortex()
+		}
+	}
+	for _, member := range checkpoint.Members {
+		link(member.Cortex)
+	}
+	for _, species := range checkpoint.Species {
+		link(species.Representative)
+	}
+	for _, stats := range checkpoint.History {
+		link(stats.Best)
+	}
+}
+
+func encodableFloats(xs []float64) []ng.EncodableFloat {
+	if xs == nil {
+		return nil
+	}
+	encodable := make([]ng.EncodableFloat, len(xs))
+	for i, x := range xs {
+		encodable[i] = ng.EncodableFloat(x)
+	}
+	return encodable
+}
+
+func decodeFloats(encodable []ng.EncodableFloat) []float64 {
+	if encodable == nil {
+		return nil
+	}
+	xs := make([]float64, len(encodable))
+	for i, x := range encodable {
+		xs[i] = float64(x)
+	}
+	return xs
+}
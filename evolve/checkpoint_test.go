@@ -0,0 +1,152 @@
+package evolve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestPopulationCheckpointLinksCortexes(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	newPopulation := func() *Population {
+		rng := rand.New(rand.NewSource(1))
+		return NewPopulation(ng.SeededXnorCortexUntrained(rng), 10, ng.SampleFitness(examples, nil), rng)
+	}
+
+	population := newPopulation()
+	for i := 0; i < 3; i++ {
+		_, _, err := population.StepGeneration()
+		assert.True(t, err == nil)
+	}
+	data, err := population.MarshalCheckpoint()
+	assert.True(t, err == nil)
+
+	restored := newPopulation()
+	assert.True(t, restored.UnmarshalCheckpoint(data) == nil)
+
+	cortexes := make([]*ng.Cortex, 0)
+	for _, member := range restored.Members {
+		cortexes = append(cortexes, member.Cortex)
+	}
+	for _, species := range restored.Species {
+		cortexes = append(cortexes, species.Representative)
+	}
+	for _, stats := range restored.History {
+		cortexes = append(cortexes, stats.Best)
+	}
+	assert.Equals(t, len(cortexes), 10+len(restored.Species)+3)
+
+	// every cortex can be run as it is, without being copied first
+	for _, cortex := range cortexes {
+		assert.True(t, cortex.Validate())
+		assert.True(t, cortex.Start(context.Background()) == nil)
+		assert.True(t, cortex.Stop() == nil)
+	}
+
+}
+
+func TestPopulationCheckpointInvalidSpecies(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	newPopulation := func() *Population {
+		rng := rand.New(rand.NewSource(1))
+		return NewPopulation(ng.SeededXnorCortexUntrained(rng), 10, ng.SampleFitness(examples, nil), rng)
+	}
+
+	population := newPopulation()
+	_, _, err := population.StepGeneration()
+	assert.True(t, err == nil)
+	data, err := population.MarshalCheckpoint()
+	assert.True(t, err == nil)
+
+	for _, index := range []int{-1, 10} {
+		checkpoint := populationCheckpoint{}
+		assert.True(t, json.Unmarshal(data, &checkpoint) == nil)
+		checkpoint.Species[0].Members[0] = index
+		invalid, err := json.Marshal(checkpoint)
+		assert.True(t, err == nil)
+
+		err = newPopulation().UnmarshalCheckpoint(invalid)
+		assert.True(t, errors.Is(err, ng.ErrCheckpointInvalid))
+	}
+
+}
+
+func TestSimulatedAnnealingTrainerResume(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	assertResumesExactly(t, func(session *ng.TrainingSession) ng.Generational {
+		session.RandSource = ng.NewRandSource(1)
+		trainer := NewSimulatedAnnealingTrainer()
+		trainer.Rand = rand.New(session.RandSource)
+		cortex := ng.SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+		assert.True(t, trainer.Start(cortex, examples) == nil)
+		return trainer
+	}, 60, 20)
+
+}
+
+func TestMemeticTrainerResume(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	assertResumesExactly(t, func(session *ng.TrainingSession) ng.Generational {
+		session.RandSource = ng.NewRandSource(1)
+		trainer := NewMemeticTrainer()
+		trainer.Rand = rand.New(session.RandSource)
+		cortex := ng.SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+		assert.True(t, trainer.Start(cortex, examples) == nil)
+		return trainer
+	}, 30, 10)
+
+}
+
+// assertResumesExactly checks that training interrupted after a checkpoint
+// and resumed from it evolves exactly as training that was never
+// interrupted.  Mutations give new nodes random ids, so the fitnesses are
+// compared rather than the cortexes.
+func assertResumesExactly(t *testing.T, newTrainer func(session *ng.TrainingSession) ng.Generational, generations, interval int) {
+
+	uninterrupted := ng.NewTrainingSession()
+	_, err := uninterrupted.Evolve(context.Background(), newTrainer(uninterrupted), generations, 0)
+	assert.True(t, err == nil)
+
+	dir, err := ioutil.TempDir("", "neurgo-checkpoints")
+	assert.True(t, err == nil)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted := ng.NewTrainingSession()
+	interrupted.CheckpointDir = dir
+	interrupted.CheckpointInterval = interval
+	interrupted.OnGeneration = func(metrics *ng.EpochMetrics) {
+		if metrics.Epoch == interval+1 {
+			cancel()
+		}
+	}
+	_, err = interrupted.Evolve(ctx, newTrainer(interrupted), generations, 0)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// the trainer is started before resuming, so that the random
+	// numbers it draws don't disturb the restored RandSource
+	resumed := ng.NewTrainingSession()
+	trainer := newTrainer(resumed)
+	assert.True(t, resumed.Resume(dir) == nil)
+	assert.Equals(t, resumed.Epoch, interval)
+	best, err := resumed.Evolve(context.Background(), trainer, generations, 0)
+	assert.True(t, err == nil)
+	assert.True(t, Validate(best) == nil)
+
+	assert.Equals(t, len(resumed.History), len(uninterrupted.History))
+	for i, metrics := range resumed.History {
+		assert.Equals(t, metrics.Fitness, uninterrupted.History[i].Fitness)
+	}
+	assert.Equals(t, resumed.BestFitness, uninterrupted.BestFitness)
+
+}
@@ -0,0 +1,189 @@
+package evolve
+
+import (
+	"fmt"
+	ng "github.com/maxxk/neurgo"
+	"math/rand"
+)
+
+// Crossover recombines two cortexes which descend from a common ancestor,
+// aligning their neurons and connections by NodeId.UUID.
+//
+// Parent a is treated as the fitter of the two: the child has all of its
+// neurons and connections, along with its sensors and actuators.  Genes
+// which both parents share (the bias and activation of a neuron, the
+// weights of a connection) are inherited from either parent at random.
+// Neurons which only b has are each inherited with probability 1/2,
+// along with their connections, and connections which only b has
+// between neurons the child inherited from a are also inherited with
+// probability 1/2.
+//
+// The child is then repaired so that it passes Validate: neurons from b
+// which ended up without a feedforward inbound connection are dropped,
+// weight vectors are resized to match their sender, and the Outbound
+// connections of every node are rebuilt from the Inbound ones.
+func Crossover(a, b *ng.Cortex, rng *rand.Rand) *ng.Cortex {
+
+	child := a.Copy()
+	child.NodeId = ng.NewCortexId(fmt.Sprintf("cortex-%s", ng.NewUuid()))
+
+	inA := child.NeuronUUIDMap()
+	inherited := make(map[string]bool)
+
+	neurons := child.Neurons
+	for _, neuronB := range b.Neurons {
+		if neuron, ok := inA[neuronB.NodeId.UUID]; ok {
+			if rng.Intn(2) == 0 {
+				neuron.Bias = neuronB.Bias
+				neuron.ActivationFunction = neuronB.ActivationFunction
+			}
+			continue
+		}
+		if rng.Intn(2) == 0 {
+			neuron := &ng.Neuron{
+				NodeId:             ng.NewNeuronId(neuronB.NodeId.UUID, neuronB.NodeId.LayerIndex),
+				Bias:               neuronB.Bias,
+				ActivationFunction: neuronB.ActivationFunction,
+				Inbound:            make([]*ng.InboundConnection, 0),
+			}
+			neurons = append(neurons, neuron)
+			inherited[neuron.NodeId.UUID] = true
+		}
+	}
+	child.SetNeurons(neurons)
+
+	childNodeIds := make(map[string]*ng.NodeId)
+	for _, nodeId := range senderNodeIds(child) {
+		childNodeIds[nodeId.UUID] = nodeId
+	}
+
+	for _, neuronB := range b.Neurons {
+
+		neuron := child.FindNeuron(neuronB.NodeId)
+		if neuron == nil {
+			continue
+		}
+		inboundA := neuron.InboundUUIDMap()
+
+		for _, connectionB := range neuronB.Inbound {
+
+			if connection, ok := inboundA[connectionB.NodeId.UUID]; ok {
+				if rng.Intn(2) == 0 {
+					connection.Weights = copyWeights(connectionB.Weights)
+				}
+				continue
+			}
+
+			source, ok := childNodeIds[connectionB.NodeId.UUID]
+			if !ok || !canLink(source, neuron.NodeId) {
+				continue
+			}
+			touchesInherited := inherited[neuron.NodeId.UUID] || inherited[source.UUID]
+			if touchesInherited || rng.Intn(2) == 0 {
+				connection := &ng.InboundConnection{
+					NodeId:  source,
+					Weights: copyWeights(connectionB.Weights),
+				}
+				neuron.Inbound = append(neuron.Inbound, connection)
+			}
+
+		}
+	}
+
+	dropUndriven(child, inherited)
+	resizeWeights(child, rng)
+	rebuildOutbound(child)
+
+	return child
+
+}
+
+// drop neurons inherited from the second parent which don't have a
+// feedforward inbound connection, along with any connections from them,
+// until there are none left
+func dropUndriven(cortex *ng.Cortex, inherited map[string]bool) {
+
+	for {
+
+		dropped := make(map[string]bool)
+		neurons := make([]*ng.Neuron, 0, len(cortex.Neurons))
+		for _, neuron := range cortex.Neurons {
+			if inherited[neuron.NodeId.UUID] && len(feedForwardInbound(cortex, neuron)) == 0 {
+				dropped[neuron.NodeId.UUID] = true
+			} else {
+				neurons = append(neurons, neuron)
+			}
+		}
+		if len(dropped) == 0 {
+			return
+		}
+		cortex.Neurons = neurons
+
+		for _, neuron := range cortex.Neurons {
+			inbound := make([]*ng.InboundConnection, 0, len(neuron.Inbound))
+			for _, connection := range neuron.Inbound {
+				if !dropped[connection.NodeId.UUID] {
+					inbound = append(inbound, connection)
+				}
+			}
+			neuron.Inbound = inbound
+		}
+
+	}
+
+}
+
+// truncate or pad every inbound weight vector so that it matches the
+// length of the vector its sender outputs
+func resizeWeights(cortex *ng.Cortex, rng *rand.Rand) {
+	for _, neuron := range cortex.Neurons {
+		for _, connection := range neuron.Inbound {
+			expected := outputLength(cortex, connection.NodeId)
+			if len(connection.Weights) > expected {
+				connection.Weights = connection.Weights[:expected]
+			}
+			if len(connection.Weights) < expected {
+				padding := randomWeights(expected-len(connection.Weights), rng)
+				connection.Weights = append(connection.Weights, padding...)
+			}
+		}
+	}
+}
+
+// replace the Outbound connections of every sensor and neuron with ones
+// that mirror the Inbound connections of the neurons and actuators
+func rebuildOutbound(cortex *ng.Cortex) {
+
+	for _, sensor := range cortex.Sensors {
+		sensor.Outbound = make([]*ng.OutboundConnection, 0)
+	}
+	for _, neuron := range cortex.Neurons {
+		neuron.Outbound = make([]*ng.OutboundConnection, 0)
+	}
+
+	addOutbound := func(receiver *ng.NodeId, inbound []*ng.InboundConnection) {
+		for _, connection := range inbound {
+			outbound := &ng.OutboundConnection{NodeId: receiver}
+			if sensor := cortex.FindSensor(connection.NodeId); sensor != nil {
+				sensor.Outbound = append(sensor.Outbound, outbound)
+			} else {
+				neuron := cortex.FindNeuron(connection.NodeId)
+				neuron.Outbound = append(neuron.Outbound, outbound)
+			}
+		}
+	}
+	for _, neuron := range cortex.Neurons {
+		addOutbound(neuron.NodeId, neuron.Inbound)
+	}
+	for _, actuator := range cortex.Actuators {
+		addOutbound(actuator.NodeId, actuator.Inbound)
+	}
+
+}
+
+func copyWeights(weights []float64) []float64 {
+	if weights == nil {
+		return nil
+	}
+	return append([]float64{}, weights...)
+}
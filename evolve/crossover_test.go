@@ -0,0 +1,98 @@
+package evolve
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"math/rand"
+	"testing"
+)
+
+// a descendant of cortex with a few random mutations applied
+func mutatedDescendant(cortex *ng.Cortex, rng *rand.Rand, count int) *ng.Cortex {
+	descendant := cortex.Copy()
+	mutations := append(TopologicalMutations(), ParameterMutations()...)
+	for i := 0; i < count; i++ {
+		Mutate(descendant, rng, mutations)
+	}
+	return descendant
+}
+
+func TestCrossoverValid(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+	ancestor := ng.XnorCortex()
+
+	for i := 0; i < 20; i++ {
+
+		a := mutatedDescendant(ancestor, rng, 10)
+		b := mutatedDescendant(ancestor, rng, 10)
+
+		child := Crossover(a, b, rng)
+		if err := Validate(child); err != nil {
+			t.Fatalf("iteration %d: %v", i, err)
+		}
+
+		// every neuron of the fitter parent is inherited
+		for _, neuron := range a.Neurons {
+			assert.True(t, child.FindNeuron(neuron.NodeId) != nil)
+		}
+
+		// every neuron comes from one of the parents, and so does
+		// its bias
+		for _, neuron := range child.Neurons {
+			neuronA := a.FindNeuron(neuron.NodeId)
+			neuronB := b.FindNeuron(neuron.NodeId)
+			assert.True(t, neuronA != nil || neuronB != nil)
+			fromA := neuronA != nil && neuronA.Bias == neuron.Bias
+			fromB := neuronB != nil && neuronB.Bias == neuron.Bias
+			assert.True(t, fromA || fromB)
+		}
+
+		_, err := child.Fitness(ng.XnorTrainingSamples())
+		assert.True(t, err == nil)
+
+		// Mutate can carry on from where Crossover left off
+		Mutate(child, rng, TopologicalMutations())
+		assert.True(t, Validate(child) == nil)
+
+		assertRunnable(t, child)
+
+		// parents are left untouched
+		assert.True(t, Validate(a) == nil)
+		assert.True(t, Validate(b) == nil)
+
+	}
+
+}
+
+func TestCrossoverWithSelf(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(2))
+	a := mutatedDescendant(ng.XnorCortex(), rng, 10)
+
+	child := Crossover(a, a, rng)
+	assert.True(t, Validate(child) == nil)
+	assert.Equals(t, len(child.Neurons), len(a.Neurons))
+
+	examples := ng.XnorTrainingSamples()
+	fitnessA, err := a.Fitness(examples)
+	assert.True(t, err == nil)
+	fitnessChild, err := child.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.Equals(t, fitnessChild, fitnessA)
+
+}
+
+func TestCrossoverReproducible(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(3))
+	a := mutatedDescendant(ng.XnorCortex(), rng, 10)
+	b := mutatedDescendant(ng.XnorCortex(), rng, 10)
+
+	first := Crossover(a, b, rand.New(rand.NewSource(4)))
+	second := Crossover(a, b, rand.New(rand.NewSource(4)))
+
+	first.NodeId = second.NodeId
+	assert.Equals(t, first.String(), second.String())
+
+}
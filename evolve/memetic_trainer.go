@@ -0,0 +1,279 @@
+package evolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/couchbaselabs/logg"
+	ng "github.com/maxxk/neurgo"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// MemeticTrainer evolves a single cortex by stochastic hill climbing, in
+// the style of DXNN's memetic algorithm.  Each iteration applies a few
+// topological mutations to the best cortex found so far, then tunes the
+// weights and biases of the result with parameter mutations, keeping
+// each one only if it improves the fitness.  The tuned cortex replaces
+// the best one if it is fitter.
+type MemeticTrainer struct {
+
+	// number of topological mutation rounds
+	MaxIterations int
+
+	// stop tuning the weights and biases after this many parameter
+	// mutations in a row fail to improve the fitness
+	MaxAttempts int
+
+	// stop training as soon as the cortex reaches this fitness
+	TargetFitness float64
+
+	// the topological mutations to choose from, or nil to use
+	// TopologicalMutations()
+	Mutations []Mutation
+
+	// source of all random choices, or nil to seed one from the clock
+	Rand *rand.Rand
+
+	// which sensors and actuators the samples correspond to, or nil
+	// to map them by index.  See ng.SampleMapping.
+	Mapping *ng.SampleMapping
+
+	// the search begun by Start, which StepGeneration carries on
+	search *memeticSearch
+}
+
+func NewMemeticTrainer() *MemeticTrainer {
+	return &MemeticTrainer{
+		MaxIterations: 100,
+		MaxAttempts:   50,
+		TargetFitness: 100,
+	}
+}
+
+// Train returns an evolved copy of cortex, or nil if the cortex could
+// not be trained.  Use Fit to find out why.
+func (trainer *MemeticTrainer) Train(cortex *ng.Cortex, examples []*ng.TrainingSample) *ng.Cortex {
+	trained, err := trainer.Fit(cortex, examples)
+	if err != nil {
+		logg.LogWarn("MemeticTrainer unable to train cortex: %v", err)
+		return nil
+	}
+	return trained
+}
+
+// Fit is the same as Train, but returns an error rather than nil
+func (trainer *MemeticTrainer) Fit(cortex *ng.Cortex, examples []*ng.TrainingSample) (*ng.Cortex, error) {
+
+	search, err := trainer.newSearch(cortex, examples)
+	if err != nil {
+		return nil, err
+	}
+
+	for search.iteration < trainer.MaxIterations {
+
+		if search.bestFitness >= trainer.TargetFitness {
+			logg.LogTo("DEBUG", "MemeticTrainer reached fitness %v after %d iterations", search.bestFitness, search.iteration)
+			break
+		}
+
+		if err := search.step(); err != nil {
+			return nil, err
+		}
+
+	}
+
+	return search.best, nil
+
+}
+
+// Start prepares to evolve a copy of cortex one iteration at a time with
+// StepGeneration, so that the trainer can be run by an ng.TrainingSession
+// instead of Fit.  MaxIterations is then up to the session, although
+// TargetFitness still cuts the tuning of the weights and biases short.
+func (trainer *MemeticTrainer) Start(cortex *ng.Cortex, examples []*ng.TrainingSample) error {
+	search, err := trainer.newSearch(cortex, examples)
+	if err != nil {
+		return err
+	}
+	trainer.search = search
+	return nil
+}
+
+// StepGeneration mutates the topology of the best cortex and tunes its
+// weights and biases, and returns the best cortex so far along with its
+// fitness.  This makes the trainer an ng.Generational.  Returns
+// ng.ErrNotStarted if Start hasn't been called.
+func (trainer *MemeticTrainer) StepGeneration() (*ng.Cortex, float64, error) {
+	search := trainer.search
+	if search == nil {
+		return nil, 0, ng.ErrNotStarted
+	}
+	if err := search.step(); err != nil {
+		return nil, 0, err
+	}
+	return search.best, search.bestFitness, nil
+}
+
+// MarshalCheckpoint saves the state of the hill climbing begun by Start:
+// the best cortex, its fitness and the number of iterations so far, so
+// that training can be resumed by an ng.TrainingSession.  The random
+// number generator is not saved: for training to be resumed
+// deterministically, Rand must wrap the session's RandSource.
+func (trainer *MemeticTrainer) MarshalCheckpoint() ([]byte, error) {
+	search := trainer.search
+	if search == nil {
+		return nil, ng.ErrNotStarted
+	}
+	return json.Marshal(memeticCheckpoint{
+		Iteration:   search.iteration,
+		Best:        search.best,
+		BestFitness: ng.EncodableFloat(search.bestFitness),
+	})
+}
+
+// UnmarshalCheckpoint restores what MarshalCheckpoint saved, into hill
+// climbing begun by Start, which must be called first.
+func (trainer *MemeticTrainer) UnmarshalCheckpoint(data []byte) error {
+	search := trainer.search
+	if search == nil {
+		return ng.ErrNotStarted
+	}
+	checkpoint := memeticCheckpoint{}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return err
+	}
+	if checkpoint.Best == nil {
+		return fmt.Errorf("%w: no best cortex", ng.ErrCheckpointInvalid)
+	}
+	checkpoint.Best.LinkNodesToCortex()
+	search.iteration = checkpoint.Iteration
+	search.best = checkpoint.Best
+	search.bestFitness = float64(checkpoint.BestFitness)
+	return nil
+}
+
+// the state of memetic hill climbing saved in a checkpoint
+type memeticCheckpoint struct {
+	Iteration   int
+	Best        *ng.Cortex
+	BestFitness ng.EncodableFloat
+}
+
+// start hill climbing from a copy of cortex
+func (trainer *MemeticTrainer) newSearch(cortex *ng.Cortex, examples []*ng.TrainingSample) (*memeticSearch, error) {
+
+	rng := trainer.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	mutations := trainer.Mutations
+	if mutations == nil {
+		mutations = TopologicalMutations()
+	}
+
+	best := cortex.Copy()
+	if err := Validate(best); err != nil {
+		return nil, err
+	}
+	bestFitness, err := best.FitnessMapped(examples, trainer.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memeticSearch{
+		trainer:     trainer,
+		examples:    examples,
+		mutations:   mutations,
+		rng:         rng,
+		best:        best,
+		bestFitness: bestFitness,
+	}, nil
+
+}
+
+// memetic hill climbing in progress
+type memeticSearch struct {
+	trainer     *MemeticTrainer
+	examples    []*ng.TrainingSample
+	mutations   []Mutation
+	rng         *rand.Rand
+	iteration   int
+	best        *ng.Cortex
+	bestFitness float64
+}
+
+// mutate the topology of the best cortex, tune the result, and keep it
+// if it's fitter
+func (search *memeticSearch) step() error {
+
+	iteration := search.iteration
+	search.iteration += 1
+
+	candidate := search.best.Copy()
+	count := numMutations(candidate, search.rng)
+	for i := 0; i < count; i++ {
+		Mutate(candidate, search.rng, search.mutations)
+	}
+
+	candidate, candidateFitness, err := search.trainer.tune(candidate, search.examples, search.rng)
+	if err != nil {
+		return err
+	}
+
+	if candidateFitness > search.bestFitness {
+		logg.LogTo("DEBUG", "MemeticTrainer iteration %d improved fitness %v -> %v", iteration, search.bestFitness, candidateFitness)
+		search.best = candidate
+		search.bestFitness = candidateFitness
+	}
+	return nil
+
+}
+
+// hill climb the weights and biases of cortex, returning the fittest
+// version found along with its fitness
+func (trainer *MemeticTrainer) tune(cortex *ng.Cortex, examples []*ng.TrainingSample, rng *rand.Rand) (*ng.Cortex, float64, error) {
+
+	fitness, err := cortex.FitnessMapped(examples, trainer.Mapping)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for attempt := 0; attempt < trainer.MaxAttempts; attempt++ {
+
+		if fitness >= trainer.TargetFitness {
+			break
+		}
+
+		trial := cortex.Copy()
+		count := numMutations(trial, rng)
+		for i := 0; i < count; i++ {
+			Mutate(trial, rng, ParameterMutations())
+		}
+
+		trialFitness, err := trial.FitnessMapped(examples, trainer.Mapping)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if trialFitness > fitness {
+			cortex = trial
+			fitness = trialFitness
+			attempt = -1
+		}
+
+	}
+
+	return cortex, fitness, nil
+
+}
+
+// choose how many mutations to apply, which like DXNN is a random number
+// between 1 and sqrt(# of neurons)
+func numMutations(cortex *ng.Cortex, rng *rand.Rand) int {
+	max := int(math.Sqrt(float64(len(cortex.Neurons))))
+	if max < 1 {
+		return 1
+	}
+	return 1 + rng.Intn(max)
+}
@@ -0,0 +1,75 @@
+package evolve
+
+import (
+	"context"
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"math/rand"
+	"testing"
+)
+
+func TestMemeticTrainerXnor(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+	examples := ng.XnorTrainingSamples()
+	untrained := ng.SeededXnorCortexUntrained(rng)
+
+	trainer := NewMemeticTrainer()
+	trainer.TargetFitness = 10
+	trainer.Rand = rng
+
+	trained, err := trainer.Fit(untrained, examples)
+	assert.True(t, err == nil)
+	assert.True(t, Validate(trained) == nil)
+
+	fitness, err := trained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= trainer.TargetFitness)
+
+	// the cortex that was passed in should not have been modified
+	untrainedFitness, err := untrained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, untrainedFitness < fitness)
+
+}
+
+func TestMemeticTrainerReproducible(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	untrained := ng.SeededXnorCortexUntrained(rand.New(rand.NewSource(2)))
+
+	fitnesses := make([]float64, 2)
+	for i := range fitnesses {
+		trainer := NewMemeticTrainer()
+		trainer.MaxIterations = 10
+		trainer.Rand = rand.New(rand.NewSource(3))
+		trained := trainer.Train(untrained, examples)
+		assert.True(t, trained != nil)
+		fitness, err := trained.Fitness(examples)
+		assert.True(t, err == nil)
+		fitnesses[i] = fitness
+	}
+	assert.Equals(t, fitnesses[0], fitnesses[1])
+
+}
+
+func TestMemeticTrainerSession(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+
+	trainer := NewMemeticTrainer()
+	trainer.Rand = rand.New(rand.NewSource(1))
+	assert.True(t, trainer.Start(ng.SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), examples) == nil)
+
+	session := ng.NewTrainingSession()
+	best, err := session.Evolve(context.Background(), trainer, trainer.MaxIterations, trainer.TargetFitness)
+	assert.True(t, err == nil)
+	assert.True(t, Validate(best) == nil)
+	assert.True(t, len(session.History) > 0)
+
+	// the best cortex never gets worse
+	for i := 1; i < len(session.History); i++ {
+		assert.True(t, session.History[i].Fitness >= session.History[i-1].Fitness)
+	}
+
+}
@@ -0,0 +1,453 @@
+// Package evolve changes the topology and parameters of a neurgo cortex
+// with DXNN style mutation operators, and trains cortexes by evolving
+// them rather than by gradient descent.
+package evolve
+
+import (
+	"errors"
+	ng "github.com/maxxk/neurgo"
+	"math"
+	"math/rand"
+)
+
+// weights and biases are kept within +/- this value
+const WeightSaturationLimit = 2 * math.Pi
+
+var ErrNotDriven = errors.New("neuron has no feedforward inbound connection")
+
+// A Mutation changes cortex in place, using rng for every random choice.
+// It returns false, leaving the cortex untouched, if it cannot be applied
+// (eg, RemoveNeuron on a cortex where every neuron feeds an actuator).
+//
+// Every Mutation keeps the cortex valid as defined by Validate, as long
+// as it was valid beforehand.  In particular sensors and actuators are
+// never added or removed, and actuators always keep VectorLength inbound
+// connections in the same order.  Connections between two different
+// neurons in the same layer are never created, since the channel based
+// runtime deadlocks when such neurons prime each other.
+type Mutation func(cortex *ng.Cortex, rng *rand.Rand) bool
+
+// TopologicalMutations are the mutations which change the structure of
+// the network
+func TopologicalMutations() []Mutation {
+	return []Mutation{
+		AddNeuron,
+		AddInlink,
+		AddOutlink,
+		SpliceNeuron,
+		RemoveNeuron,
+		RemoveLink,
+		ChangeActivation,
+	}
+}
+
+// ParameterMutations are the mutations which only change weights and biases
+func ParameterMutations() []Mutation {
+	return []Mutation{
+		PerturbWeights,
+		PerturbBias,
+	}
+}
+
+// Mutate tries the given mutations in random order until one of them
+// succeeds, and returns false if none of them could be applied.
+func Mutate(cortex *ng.Cortex, rng *rand.Rand, mutations []Mutation) bool {
+	for _, i := range rng.Perm(len(mutations)) {
+		if mutations[i](cortex, rng) {
+			cortex.ResetStep()
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error if cortex.ValidateConnections fails, or if
+// some neuron has no feedforward inbound connection (one from a sensor,
+// or from a neuron in a lower layer).  The latter guarantees that every
+// neuron is driven by the sensors on every tick, rather than only by
+// recurrent connections.
+func Validate(cortex *ng.Cortex) error {
+	if err := cortex.ValidateConnections(); err != nil {
+		return err
+	}
+	for _, neuron := range cortex.Neurons {
+		if len(feedForwardInbound(cortex, neuron)) == 0 {
+			return &ng.NodeError{NodeId: neuron.NodeId, Err: ErrNotDriven}
+		}
+	}
+	return nil
+}
+
+// AddNeuron adds a neuron to a randomly chosen neuron layer, with an
+// inbound connection from a sensor or neuron in a preceding layer and an
+// outbound connection to a neuron in another layer.
+func AddNeuron(cortex *ng.Cortex, rng *rand.Rand) bool {
+
+	if len(cortex.Neurons) == 0 {
+		return false
+	}
+	cortex.Init()
+
+	layerIndex := chooseNeuron(cortex.Neurons, rng).NodeId.LayerIndex
+
+	sources := make([]*ng.NodeId, 0)
+	for _, nodeId := range senderNodeIds(cortex) {
+		if nodeId.LayerIndex < layerIndex {
+			sources = append(sources, nodeId)
+		}
+	}
+	if len(sources) == 0 {
+		return false
+	}
+	source := chooseNodeId(sources, rng)
+
+	targets := make([]*ng.Neuron, 0)
+	for _, target := range cortex.Neurons {
+		if target.NodeId.LayerIndex != layerIndex {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return false
+	}
+	target := chooseNeuron(targets, rng)
+
+	neuron := createNeuron(cortex, layerIndex, rng)
+	connect(cortex, source, neuron.NodeId, randomWeights(outputLength(cortex, source), rng))
+	connect(cortex, neuron.NodeId, target.NodeId, randomWeights(1, rng))
+
+	return true
+
+}
+
+// AddInlink connects a randomly chosen sensor or neuron to a randomly
+// chosen neuron it isn't already connected to.
+func AddInlink(cortex *ng.Cortex, rng *rand.Rand) bool {
+
+	if len(cortex.Neurons) == 0 {
+		return false
+	}
+	cortex.Init()
+
+	neuron := chooseNeuron(cortex.Neurons, rng)
+
+	sources := make([]*ng.NodeId, 0)
+	for _, nodeId := range senderNodeIds(cortex) {
+		if canLink(nodeId, neuron.NodeId) && neuron.InboundUUIDMap()[nodeId.UUID] == nil {
+			sources = append(sources, nodeId)
+		}
+	}
+	if len(sources) == 0 {
+		return false
+	}
+	source := chooseNodeId(sources, rng)
+
+	connect(cortex, source, neuron.NodeId, randomWeights(outputLength(cortex, source), rng))
+	return true
+
+}
+
+// AddOutlink connects a randomly chosen neuron to a randomly chosen
+// neuron it isn't already connected to.  Links are never added to
+// actuators, since they already have all the inbound connections their
+// VectorLength allows.
+func AddOutlink(cortex *ng.Cortex, rng *rand.Rand) bool {
+
+	if len(cortex.Neurons) == 0 {
+		return false
+	}
+	cortex.Init()
+
+	neuron := chooseNeuron(cortex.Neurons, rng)
+
+	targets := make([]*ng.Neuron, 0)
+	for _, target := range cortex.Neurons {
+		if canLink(neuron.NodeId, target.NodeId) && target.InboundUUIDMap()[neuron.NodeId.UUID] == nil {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return false
+	}
+	target := chooseNeuron(targets, rng)
+
+	connect(cortex, neuron.NodeId, target.NodeId, randomWeights(1, rng))
+	return true
+
+}
+
+// SpliceNeuron chooses a feedforward connection and inserts a new neuron
+// into it, in a layer between the sender and receiver (creating a new
+// layer if they are adjacent).  The receiver reads the new neuron at the
+// same inbound position it used to read the sender, so actuator outputs
+// stay in the same order.
+func SpliceNeuron(cortex *ng.Cortex, rng *rand.Rand) bool {
+
+	type link struct {
+		source  *ng.NodeId
+		inbound *ng.InboundConnection
+		target  *ng.NodeId
+	}
+
+	links := make([]link, 0)
+	addLinks := func(target *ng.NodeId, inbound []*ng.InboundConnection) {
+		for _, connection := range inbound {
+			if connection.NodeId.LayerIndex < target.LayerIndex {
+				links = append(links, link{connection.NodeId, connection, target})
+			}
+		}
+	}
+	for _, neuron := range cortex.Neurons {
+		addLinks(neuron.NodeId, neuron.Inbound)
+	}
+	for _, actuator := range cortex.Actuators {
+		addLinks(actuator.NodeId, actuator.Inbound)
+	}
+	if len(links) == 0 {
+		return false
+	}
+	cortex.Init()
+
+	chosen := links[rng.Intn(len(links))]
+
+	layerMap := cortex.NodeIdLayerMap()
+	layerIndex := layerMap.LayerBetweenOrNew(chosen.source.LayerIndex, chosen.target.LayerIndex)
+	neuron := createNeuron(cortex, layerIndex, rng)
+
+	sender := cortex.FindConnector(chosen.source)
+	ng.DisconnectOutbound(sender, chosen.target)
+	connect(cortex, chosen.source, neuron.NodeId, randomWeights(outputLength(cortex, chosen.source), rng))
+
+	receiver := cortex.FindInboundConnector(chosen.target).(ng.OutboundConnectable)
+	ng.ConnectOutbound(neuron, receiver)
+	chosen.inbound.NodeId = neuron.NodeId
+	if cortex.FindActuator(chosen.target) == nil {
+		chosen.inbound.Weights = randomWeights(1, rng)
+	} else {
+		chosen.inbound.Weights = nil
+	}
+
+	return true
+
+}
+
+// RemoveNeuron removes a randomly chosen neuron along with all of its
+// connections.  Neurons which feed an actuator, or which are the only
+// feedforward inbound connection of another neuron, are never removed.
+func RemoveNeuron(cortex *ng.Cortex, rng *rand.Rand) bool {
+
+	candidates := make([]*ng.Neuron, 0)
+	for _, neuron := range cortex.Neurons {
+		if isRemovable(cortex, neuron) {
+			candidates = append(candidates, neuron)
+		}
+	}
+	if len(candidates) == 0 {
+		return false
+	}
+	chosen := chooseNeuron(candidates, rng)
+
+	for _, outbound := range chosen.Outbound {
+		if outbound.NodeId.UUID != chosen.NodeId.UUID {
+			ng.DisconnectInbound(cortex.FindInboundConnector(outbound.NodeId), chosen.NodeId)
+		}
+	}
+	for _, inbound := range chosen.Inbound {
+		if inbound.NodeId.UUID != chosen.NodeId.UUID {
+			ng.DisconnectOutbound(cortex.FindConnector(inbound.NodeId), chosen.NodeId)
+		}
+	}
+
+	neurons := make([]*ng.Neuron, 0, len(cortex.Neurons)-1)
+	for _, neuron := range cortex.Neurons {
+		if neuron != chosen {
+			neurons = append(neurons, neuron)
+		}
+	}
+	cortex.Neurons = neurons
+
+	return true
+
+}
+
+// RemoveLink removes a randomly chosen inbound connection of a neuron,
+// as long as the neuron still has a feedforward inbound connection
+// afterwards.  Actuator connections are never removed.
+func RemoveLink(cortex *ng.Cortex, rng *rand.Rand) bool {
+
+	type link struct {
+		neuron *ng.Neuron
+		source *ng.NodeId
+	}
+
+	links := make([]link, 0)
+	for _, neuron := range cortex.Neurons {
+		feedForward := feedForwardInbound(cortex, neuron)
+		for _, inbound := range neuron.Inbound {
+			if len(feedForward) == 1 && feedForward[0] == inbound {
+				continue
+			}
+			links = append(links, link{neuron, inbound.NodeId})
+		}
+	}
+	if len(links) == 0 {
+		return false
+	}
+
+	chosen := links[rng.Intn(len(links))]
+	ng.DisconnectInbound(chosen.neuron, chosen.source)
+	ng.DisconnectOutbound(cortex.FindConnector(chosen.source), chosen.neuron.NodeId)
+
+	return true
+
+}
+
+// ChangeActivation gives a randomly chosen neuron a different activation
+// function
+func ChangeActivation(cortex *ng.Cortex, rng *rand.Rand) bool {
+
+	if len(cortex.Neurons) == 0 {
+		return false
+	}
+	neuron := chooseNeuron(cortex.Neurons, rng)
+
+	activations := make([]*ng.EncodableActivation, 0)
+	for _, activation := range ng.AllEncodableActivations() {
+		if neuron.ActivationFunction == nil || activation.Name != neuron.ActivationFunction.Name {
+			activations = append(activations, activation)
+		}
+	}
+	neuron.ActivationFunction = activations[rng.Intn(len(activations))]
+	return true
+
+}
+
+// PerturbWeights adds a random amount to the inbound weights of a
+// randomly chosen neuron.  As in DXNN, each weight is perturbed with
+// probability 1/sqrt(# of weights), but at least one weight always is.
+func PerturbWeights(cortex *ng.Cortex, rng *rand.Rand) bool {
+
+	if len(cortex.Neurons) == 0 {
+		return false
+	}
+	neuron := chooseNeuron(cortex.Neurons, rng)
+
+	weights := make([]*float64, 0)
+	for _, inbound := range neuron.Inbound {
+		for i := range inbound.Weights {
+			weights = append(weights, &inbound.Weights[i])
+		}
+	}
+	if len(weights) == 0 {
+		return false
+	}
+
+	probability := 1 / math.Sqrt(float64(len(weights)))
+	forced := rng.Intn(len(weights))
+	for i, weight := range weights {
+		if i == forced || rng.Float64() < probability {
+			*weight = perturb(*weight, rng)
+		}
+	}
+	return true
+
+}
+
+// PerturbBias adds a random amount to the bias of a randomly chosen neuron
+func PerturbBias(cortex *ng.Cortex, rng *rand.Rand) bool {
+	if len(cortex.Neurons) == 0 {
+		return false
+	}
+	neuron := chooseNeuron(cortex.Neurons, rng)
+	neuron.Bias = perturb(neuron.Bias, rng)
+	return true
+}
+
+// the inbound connections of neuron which are not recurrent
+func feedForwardInbound(cortex *ng.Cortex, neuron *ng.Neuron) []*ng.InboundConnection {
+	feedForward := make([]*ng.InboundConnection, 0)
+	for _, inbound := range neuron.Inbound {
+		if cortex.FindSensor(inbound.NodeId) != nil || !neuron.IsInboundConnectionRecurrent(inbound) {
+			feedForward = append(feedForward, inbound)
+		}
+	}
+	return feedForward
+}
+
+func isRemovable(cortex *ng.Cortex, neuron *ng.Neuron) bool {
+	for _, outbound := range neuron.Outbound {
+		if outbound.NodeId.UUID == neuron.NodeId.UUID {
+			continue
+		}
+		receiver := cortex.FindNeuron(outbound.NodeId)
+		if receiver == nil {
+			// feeds an actuator
+			return false
+		}
+		feedForward := feedForwardInbound(cortex, receiver)
+		if len(feedForward) == 1 && feedForward[0].NodeId.UUID == neuron.NodeId.UUID {
+			return false
+		}
+	}
+	return true
+}
+
+// whether a connection from source to target is allowed, see Mutation
+func canLink(source, target *ng.NodeId) bool {
+	return source.UUID == target.UUID || source.LayerIndex != target.LayerIndex
+}
+
+// connect source to target in both directions.  The cortex must have
+// been initialized, so that target has a DataChan.
+func connect(cortex *ng.Cortex, source, target *ng.NodeId, weights []float64) {
+	receiver := cortex.FindInboundConnector(target)
+	ng.ConnectOutbound(cortex.FindConnector(source), receiver.(ng.OutboundConnectable))
+	ng.ConnectInboundWeighted(receiver, source, weights)
+}
+
+// create a neuron with an activation function and bias chosen by rng
+func createNeuron(cortex *ng.Cortex, layerIndex float64, rng *rand.Rand) *ng.Neuron {
+	neuron := cortex.CreateNeuronInLayer(layerIndex)
+	activations := ng.AllEncodableActivations()
+	neuron.ActivationFunction = activations[rng.Intn(len(activations))]
+	neuron.Bias = randomInRange(-math.Pi, math.Pi, rng)
+	return neuron
+}
+
+// the nodes which can be the source of a connection
+func senderNodeIds(cortex *ng.Cortex) []*ng.NodeId {
+	return append(cortex.SensorNodeIds(), cortex.NeuronNodeIds()...)
+}
+
+// the length of the vector sent by the sensor or neuron with nodeId
+func outputLength(cortex *ng.Cortex, nodeId *ng.NodeId) int {
+	if sensor := cortex.FindSensor(nodeId); sensor != nil {
+		return sensor.VectorLength
+	}
+	return 1
+}
+
+func chooseNeuron(neurons []*ng.Neuron, rng *rand.Rand) *ng.Neuron {
+	return neurons[rng.Intn(len(neurons))]
+}
+
+func chooseNodeId(nodeIds []*ng.NodeId, rng *rand.Rand) *ng.NodeId {
+	return nodeIds[rng.Intn(len(nodeIds))]
+}
+
+func perturb(value float64, rng *rand.Rand) float64 {
+	value += randomInRange(-math.Pi, math.Pi, rng)
+	return ng.Saturate(value, -WeightSaturationLimit, WeightSaturationLimit)
+}
+
+func randomInRange(min, max float64, rng *rand.Rand) float64 {
+	return rng.Float64()*(max-min) + min
+}
+
+func randomWeights(length int, rng *rand.Rand) []float64 {
+	weights := make([]float64, length)
+	for i := range weights {
+		weights[i] = randomInRange(-math.Pi, math.Pi, rng)
+	}
+	return weights
+}
@@ -0,0 +1,123 @@
+package evolve
+
+import (
+	"context"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"math/rand"
+	"testing"
+)
+
+// run the cortex with goroutines for a few ticks, to make sure it
+// neither deadlocks nor panics
+func assertRunnable(t *testing.T, cortex *ng.Cortex) {
+
+	for _, sensor := range cortex.Sensors {
+		vectorLength := sensor.VectorLength
+		sensor.SensorFunction = func(syncCounter int) []float64 {
+			return make([]float64, vectorLength)
+		}
+	}
+	for _, actuator := range cortex.Actuators {
+		actuator.ActuatorFunction = func(outputs []float64) {}
+	}
+
+	assert.True(t, cortex.Start(context.Background()) == nil)
+	for i := 0; i < 3; i++ {
+		assert.True(t, cortex.SyncSensors() == nil)
+		assert.True(t, cortex.SyncActuators() == nil)
+	}
+	assert.True(t, cortex.Stop() == nil)
+
+}
+
+func TestMutationsKeepCortexValid(t *testing.T) {
+
+	mutations := append(TopologicalMutations(), ParameterMutations()...)
+
+	for i, mutation := range mutations {
+
+		rng := rand.New(rand.NewSource(int64(i)))
+		cortex := ng.XnorCortex()
+
+		for j := 0; j < 50; j++ {
+			mutation(cortex, rng)
+			if err := Validate(cortex); err != nil {
+				t.Fatalf("mutation %d, iteration %d: %v", i, j, err)
+			}
+			_, err := cortex.Compile()
+			assert.True(t, err == nil)
+
+			// mix in other mutations so that the one being tested
+			// sees a variety of topologies
+			Mutate(cortex, rng, mutations)
+			assert.True(t, Validate(cortex) == nil)
+		}
+
+		assertRunnable(t, cortex)
+
+		_, err := cortex.Copy().Fitness(ng.XnorTrainingSamples())
+		assert.True(t, err == nil)
+
+	}
+
+}
+
+func TestSpliceNeuronKeepsActuatorOrder(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+	cortex := ng.XnorCortex()
+	actuator := cortex.Actuators[0]
+
+	for i := 0; i < 20; i++ {
+		assert.True(t, SpliceNeuron(cortex, rng))
+		assert.True(t, Validate(cortex) == nil)
+		assert.Equals(t, len(actuator.Inbound), 1)
+		assert.True(t, actuator.Inbound[0].Weights == nil)
+	}
+
+	assert.Equals(t, len(cortex.Neurons), 23)
+
+}
+
+func TestRemoveNeuron(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+	cortex := ng.XnorCortex()
+
+	// either hidden neuron can go, but not both, since the output
+	// neuron needs a feedforward inbound connection
+	assert.True(t, RemoveNeuron(cortex, rng))
+	assert.Equals(t, len(cortex.Neurons), 2)
+	assert.True(t, Validate(cortex) == nil)
+
+	assert.False(t, RemoveNeuron(cortex, rng))
+	assert.False(t, RemoveLink(cortex, rng))
+
+	assertRunnable(t, cortex)
+
+}
+
+func TestValidateNotDriven(t *testing.T) {
+
+	cortex := ng.XnorCortex()
+	outputNeuron := cortex.Neurons[2]
+
+	// move the hidden neurons above the output neuron, so its only
+	// inbound connections become recurrent
+	for _, neuron := range cortex.Neurons[0:2] {
+		neuron.NodeId.LayerIndex = 0.4
+	}
+	for _, inbound := range outputNeuron.Inbound {
+		inbound.NodeId.LayerIndex = 0.4
+	}
+
+	err := Validate(cortex)
+	assert.True(t, errors.Is(err, ErrNotDriven))
+
+	var nodeErr *ng.NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, "output-neuron")
+
+}
@@ -0,0 +1,197 @@
+package evolve
+
+import (
+	"fmt"
+	ng "github.com/maxxk/neurgo"
+	"math"
+	"sort"
+)
+
+// Behavior characterizes what a cortex does, rather than how well it
+// does it: it is the outputs of every actuator, concatenated in order,
+// for each of the probes in turn.  The probes are fed in order to a
+// freshly reset cortex, so recurrent networks are characterized by how
+// they respond to the sequence as a whole.  SampleInputs[i] of each
+// probe is fed into cortex.Sensors[i], and ExpectedOutputs is ignored.
+func Behavior(cortex *ng.Cortex, probes []*ng.TrainingSample) ([]float64, error) {
+
+	plan, err := cortex.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	behavior := []float64{}
+	for n, probe := range probes {
+		outputs, err := plan.Evaluate(probe.SampleInputs)
+		if err != nil {
+			return nil, fmt.Errorf("probe %d: %w", n, err)
+		}
+		for _, output := range outputs {
+			behavior = append(behavior, output...)
+		}
+	}
+	return behavior, nil
+
+}
+
+// NoveltySearch rewards cortexes for behaving differently from the rest
+// of the population and from the behaviors seen in earlier generations,
+// rather than (or as well as) for their fitness, which helps evolution
+// escape the plateaus and local optima of deceptive problems.  See
+// Lehman and Stanley, "Abandoning Objectives: Evolution through the
+// Search for Novelty Alone".
+type NoveltySearch struct {
+
+	// the samples used to characterize each cortex, see Behavior
+	Probes []*ng.TrainingSample
+
+	// novelty is the mean distance to this many nearest neighbours
+	K int
+
+	// behaviors remembered from earlier generations
+	Archive [][]float64
+
+	// behaviors at least this novel, measured as the euclidean distance
+	// between behaviors, are added to the Archive
+	ArchiveThreshold float64
+
+	// how much of the score used for selection comes from novelty, from
+	// 0 (fitness only) to 1 (novelty only).  Novelty and fitness are both
+	// scaled to [0, 1] across the population before they are blended.
+	NoveltyWeight float64
+}
+
+func NewNoveltySearch(probes []*ng.TrainingSample) *NoveltySearch {
+	return &NoveltySearch{
+		Probes:           probes,
+		K:                15,
+		ArchiveThreshold: 0.5,
+		NoveltyWeight:    1.0,
+	}
+}
+
+// Novelty is the mean euclidean distance from behavior to its K nearest
+// neighbours among others and the Archive.  others should not contain
+// behavior itself.
+func (search *NoveltySearch) Novelty(behavior []float64, others [][]float64) float64 {
+
+	distances := make([]float64, 0, len(others)+len(search.Archive))
+	for _, other := range others {
+		distances = append(distances, behaviorDistance(behavior, other))
+	}
+	for _, archived := range search.Archive {
+		distances = append(distances, behaviorDistance(behavior, archived))
+	}
+	if len(distances) == 0 {
+		return 0
+	}
+
+	sort.Float64s(distances)
+	k := search.K
+	if k <= 0 || k > len(distances) {
+		k = len(distances)
+	}
+	total := float64(0)
+	for _, distance := range distances[:k] {
+		total += distance
+	}
+	return total / float64(k)
+
+}
+
+// Score returns the novelty of each of a generation's behaviors relative
+// to the rest of the generation and the Archive, then archives the
+// behaviors which were novel enough.
+func (search *NoveltySearch) Score(behaviors [][]float64) []float64 {
+
+	novelties := make([]float64, len(behaviors))
+	others := make([][]float64, 0, len(behaviors))
+	for i, behavior := range behaviors {
+		others = others[:0]
+		others = append(others, behaviors[:i]...)
+		others = append(others, behaviors[i+1:]...)
+		novelties[i] = search.Novelty(behavior, others)
+	}
+
+	for i, novelty := range novelties {
+		if novelty >= search.ArchiveThreshold {
+			search.Archive = append(search.Archive, behaviors[i])
+		}
+	}
+
+	return novelties
+
+}
+
+// Blend combines each member's novelty and fitness according to
+// NoveltyWeight, after scaling both to [0, 1] across all the members.
+func (search *NoveltySearch) Blend(novelties, fitnesses []float64) []float64 {
+	novelties = scaleToUnit(novelties)
+	fitnesses = scaleToUnit(fitnesses)
+	blended := make([]float64, len(novelties))
+	for i := range blended {
+		blended[i] = search.NoveltyWeight*novelties[i] + (1-search.NoveltyWeight)*fitnesses[i]
+	}
+	return blended
+}
+
+// score the members for novelty, and blend it with their fitness
+func (population *Population) scoreNovelty() {
+
+	search := population.Novelty
+	behaviors := make([][]float64, len(population.Members))
+	fitnesses := make([]float64, len(population.Members))
+	for i, member := range population.Members {
+		behaviors[i] = member.Behavior
+		fitnesses[i] = member.Fitness
+	}
+
+	novelties := search.Score(behaviors)
+	blended := search.Blend(novelties, fitnesses)
+	for i, member := range population.Members {
+		member.Novelty = novelties[i]
+		member.blended = blended[i]
+		if population.Objectives != nil {
+			member.Scores = append(member.Scores, member.Novelty)
+		}
+	}
+
+}
+
+func behaviorDistance(a, b []float64) float64 {
+	sum := float64(0)
+	for i := range a {
+		sum += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Sqrt(sum)
+}
+
+// scale xs linearly so that the smallest is 0 and the largest 1, or all
+// to 1 if they are all the same.  Infinite values are clamped to the
+// largest finite value first.
+func scaleToUnit(xs []float64) []float64 {
+
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for _, x := range xs {
+		if !math.IsInf(x, 0) && !math.IsNaN(x) {
+			lowest = math.Min(lowest, x)
+			highest = math.Max(highest, x)
+		}
+	}
+
+	scaled := make([]float64, len(xs))
+	for i, x := range xs {
+		switch {
+		case highest <= lowest:
+			scaled[i] = 1
+		case math.IsNaN(x) || math.IsInf(x, -1):
+			scaled[i] = 0
+		case math.IsInf(x, 1):
+			scaled[i] = 1
+		default:
+			scaled[i] = (x - lowest) / (highest - lowest)
+		}
+	}
+	return scaled
+
+}
@@ -0,0 +1,75 @@
+package evolve
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"math/rand"
+	"testing"
+)
+
+func TestBehavior(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	behavior, err := Behavior(ng.XnorCortex(), examples)
+	assert.True(t, err == nil)
+	assert.Equals(t, len(behavior), len(examples))
+	for i, example := range examples {
+		assert.True(t, ng.EqualsWithMaxDelta(behavior[i], example.ExpectedOutputs[0][0], 0.01))
+	}
+
+}
+
+func TestNoveltySearchScore(t *testing.T) {
+
+	search := NewNoveltySearch(nil)
+	search.K = 1
+	search.ArchiveThreshold = 1.5
+
+	novelties := search.Score([][]float64{{0}, {1}, {3}})
+	assert.Equals(t, novelties[0], 1.0)
+	assert.Equals(t, novelties[1], 1.0)
+	assert.Equals(t, novelties[2], 2.0)
+
+	// only the most novel behavior was archived, and now counts as a
+	// neighbour of later generations
+	assert.Equals(t, len(search.Archive), 1)
+	assert.Equals(t, search.Novelty([]float64{4}, nil), 1.0)
+
+}
+
+func TestNoveltySearchBlend(t *testing.T) {
+
+	search := NewNoveltySearch(nil)
+	search.NoveltyWeight = 0.25
+
+	blended := search.Blend([]float64{0, 2, 4}, []float64{10, 30, 20})
+	assert.Equals(t, blended[0], 0.0)
+	assert.Equals(t, blended[1], 0.25*0.5+0.75*1)
+	assert.Equals(t, blended[2], 0.25*1+0.75*0.5)
+
+}
+
+func TestPopulationEvolveXnorNovelty(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	rng := rand.New(rand.NewSource(1))
+	seed := ng.SeededXnorCortexUntrained(rng)
+
+	population := NewPopulation(seed, 30, ng.SampleFitness(examples, nil), rng)
+	population.Novelty = NewNoveltySearch(examples)
+	population.Novelty.NoveltyWeight = 0.5
+	population.Concurrency = 4
+
+	best, err := population.Evolve(100, 10)
+	assert.True(t, err == nil)
+
+	fitness, err := best.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= 10)
+
+	assert.True(t, len(population.Novelty.Archive) > 0)
+	for _, member := range population.Members {
+		assert.Equals(t, len(member.Behavior), len(examples))
+	}
+
+}
@@ -0,0 +1,184 @@
+package evolve
+
+import (
+	ng "github.com/maxxk/neurgo"
+	"math"
+	"sort"
+)
+
+// A MultiFitnessFunc scores a cortex on several objectives at once,
+// higher being better for every one of them.  Like ng.FitnessFunc it is
+// called concurrently on different cortexes.
+type MultiFitnessFunc func(cortex *ng.Cortex) ([]float64, error)
+
+// A ComplexityMeasure counts how big a cortex is
+type ComplexityMeasure func(cortex *ng.Cortex) int
+
+// NeuronCount is the number of neurons in the cortex
+func NeuronCount(cortex *ng.Cortex) int {
+	return len(cortex.Neurons)
+}
+
+// ConnectionCount is the number of inbound connections of every neuron
+// and actuator in the cortex
+func ConnectionCount(cortex *ng.Cortex) int {
+	count := 0
+	for _, neuron := range cortex.Neurons {
+		count += len(neuron.Inbound)
+	}
+	for _, actuator := range cortex.Actuators {
+		count += len(actuator.Inbound)
+	}
+	return count
+}
+
+// ParameterCount is the number of weights and biases in the cortex
+func ParameterCount(cortex *ng.Cortex) int {
+	return len(cortex.ParameterLayout())
+}
+
+// WithComplexity returns a MultiFitnessFunc whose first score is given by
+// fitness, followed by the negation of each of the measures, so that
+// smaller cortexes score higher on them.
+func WithComplexity(fitness ng.FitnessFunc, measures ...ComplexityMeasure) MultiFitnessFunc {
+	return func(cortex *ng.Cortex) ([]float64, error) {
+		score, err := fitness(cortex)
+		if err != nil {
+			return nil, err
+		}
+		scores := []float64{score}
+		for _, measure := range measures {
+			scores = append(scores, -float64(measure(cortex)))
+		}
+		return scores, nil
+	}
+}
+
+// Dominates is true if a is at least as good as b on every objective,
+// and better on at least one of them.
+func Dominates(a, b []float64) bool {
+	better := false
+	for i := range a {
+		if a[i] < b[i] {
+			return false
+		}
+		if a[i] > b[i] {
+			better = true
+		}
+	}
+	return better
+}
+
+// NonDominatedSort sorts the score vectors into Pareto fronts as in
+// NSGA-II.  The first front holds the indexes of the score vectors which
+// no other vector dominates, the second front those which are only
+// dominated by vectors in the first front, and so on.
+func NonDominatedSort(scores [][]float64) [][]int {
+
+	dominatedBy := make([]int, len(scores))
+	dominates := make([][]int, len(scores))
+	front := []int{}
+
+	for p := range scores {
+		for q := range scores {
+			if Dominates(scores[p], scores[q]) {
+				dominates[p] = append(dominates[p], q)
+			} else if Dominates(scores[q], scores[p]) {
+				dominatedBy[p] += 1
+			}
+		}
+		if dominatedBy[p] == 0 {
+			front = append(front, p)
+		}
+	}
+
+	fronts := [][]int{}
+	for len(front) > 0 {
+		fronts = append(fronts, front)
+		next := []int{}
+		for _, p := range front {
+			for _, q := range dominates[p] {
+				dominatedBy[q] -= 1
+				if dominatedBy[q] == 0 {
+					next = append(next, q)
+				}
+			}
+		}
+		sort.Ints(next)
+		front = next
+	}
+
+	return fronts
+
+}
+
+// CrowdingDistance returns the NSGA-II crowding distance of each member
+// of front, which holds indexes into scores.  The distance measures how
+// far a vector is from its neighbours in the front, and is infinite for
+// the vectors at the extremes of each objective, so preferring larger
+// distances keeps the front spread out.
+func CrowdingDistance(scores [][]float64, front []int) []float64 {
+
+	distances := make([]float64, len(front))
+	if len(front) == 0 {
+		return distances
+	}
+
+	order := make([]int, len(front))
+	for objective := range scores[front[0]] {
+
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return scores[front[order[i]]][objective] < scores[front[order[j]]][objective]
+		})
+
+		lowest := scores[front[order[0]]][objective]
+		highest := scores[front[order[len(order)-1]]][objective]
+		distances[order[0]] = math.Inf(1)
+		distances[order[len(order)-1]] = math.Inf(1)
+		if highest == lowest {
+			continue
+		}
+
+		for i := 1; i < len(order)-1; i++ {
+			below := scores[front[order[i-1]]][objective]
+			above := scores[front[order[i+1]]][objective]
+			distances[order[i]] += (above - below) / (highest - lowest)
+		}
+
+	}
+
+	return distances
+
+}
+
+// rank the members into Pareto fronts by their Scores
+func (population *Population) rankPareto() {
+
+	scores := make([][]float64, len(population.Members))
+	for i, member := range population.Members {
+		scores[i] = member.Scores
+	}
+
+	for rank, front := range NonDominatedSort(scores) {
+		distances := CrowdingDistance(scores, front)
+		for i, index := range front {
+			population.Members[index].Rank = rank
+			population.Members[index].Crowding = distances[i]
+		}
+	}
+
+}
+
+// better is true if a should be selected over b
+func (population *Population) better(a, b *Member) bool {
+	if population.Objectives == nil {
+		return a.AdjustedFitness > b.AdjustedFitness
+	}
+	if a.Rank != b.Rank {
+		return a.Rank < b.Rank
+	}
+	return a.Crowding > b.Crowding
+}
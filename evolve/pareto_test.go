@@ -0,0 +1,100 @@
+package evolve
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDominates(t *testing.T) {
+	assert.True(t, Dominates([]float64{2, 1}, []float64{1, 1}))
+	assert.False(t, Dominates([]float64{1, 1}, []float64{1, 1}))
+	assert.False(t, Dominates([]float64{2, 0}, []float64{1, 1}))
+}
+
+func TestNonDominatedSort(t *testing.T) {
+
+	scores := [][]float64{
+		{1, 1}, // dominated by 1, 2 and 3
+		{3, 1}, // dominated by 3
+		{1, 3},
+		{3, 2},
+		{0, 0}, // dominated by everything
+	}
+
+	fronts := NonDominatedSort(scores)
+	assert.Equals(t, len(fronts), 4)
+	expected := [][]int{{2, 3}, {1}, {0}, {4}}
+	for i, front := range fronts {
+		assert.Equals(t, len(front), len(expected[i]))
+		for j, index := range front {
+			assert.Equals(t, index, expected[i][j])
+		}
+	}
+
+}
+
+func TestCrowdingDistance(t *testing.T) {
+
+	scores := [][]float64{
+		{0, 4},
+		{1, 3},
+		{3, 1},
+		{4, 0},
+	}
+	front := []int{0, 1, 2, 3}
+
+	distances := CrowdingDistance(scores, front)
+	assert.True(t, math.IsInf(distances[0], 1))
+	assert.True(t, math.IsInf(distances[3], 1))
+	assert.Equals(t, distances[1], 3.0/4+3.0/4)
+	assert.Equals(t, distances[2], distances[1])
+
+}
+
+func TestWithComplexity(t *testing.T) {
+
+	cortex := ng.XnorCortex()
+	examples := ng.XnorTrainingSamples()
+	objectives := WithComplexity(ng.SampleFitness(examples, nil), NeuronCount, ConnectionCount, ParameterCount)
+
+	scores, err := objectives(cortex)
+	assert.True(t, err == nil)
+	fitness, err := cortex.Fitness(examples)
+	assert.True(t, err == nil)
+	expected := []float64{fitness, -3, -5, -9}
+	assert.Equals(t, len(scores), len(expected))
+	for i, score := range scores {
+		assert.Equals(t, score, expected[i])
+	}
+
+}
+
+func TestPopulationEvolveXnorPareto(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	rng := rand.New(rand.NewSource(1))
+	seed := ng.SeededXnorCortexUntrained(rng)
+
+	population := NewPopulation(seed, 30, nil, rng)
+	population.Objectives = WithComplexity(ng.SampleFitness(examples, nil), NeuronCount)
+	population.Concurrency = 4
+
+	best, err := population.Evolve(100, 10)
+	assert.True(t, err == nil)
+	assert.True(t, Validate(best) == nil)
+
+	fitness, err := best.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= 10)
+
+	// the member with the best fitness is never dominated
+	assert.Equals(t, population.Best().Rank, 0)
+	for _, member := range population.Members {
+		assert.Equals(t, len(member.Scores), 2)
+		assert.Equals(t, member.Scores[1], -float64(len(member.Cortex.Neurons)))
+	}
+
+}
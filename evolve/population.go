@@ -0,0 +1,410 @@
+package evolve
+
+import (
+	"fmt"
+	"github.com/couchbaselabs/logg"
+	ng "github.com/maxxk/neurgo"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// A Member of a population, along with its score from the latest call
+// to Evaluate
+type Member struct {
+	Cortex *ng.Cortex
+
+	// the score given by the population's Fitness function
+	Fitness float64
+
+	// Fitness divided by the size of the member's species, or if the
+	// population has a NoveltySearch, the blend of Fitness and Novelty
+	// divided by the size of the member's species
+	AdjustedFitness float64
+
+	Species *Species
+
+	// the scores given by the population's Objectives, if any, along
+	// with the member's Pareto front (0 being the best) and crowding
+	// distance within it
+	Scores   []float64
+	Rank     int
+	Crowding float64
+
+	// the behavior and novelty of the member, if the population has a
+	// NoveltySearch
+	Behavior []float64
+	Novelty  float64
+
+	// the blend of Fitness and Novelty used for fitness sharing
+	blended float64
+}
+
+// GenerationStats summarizes one generation of a population
+type GenerationStats struct {
+	Generation   int
+	BestFitness  float64
+	MeanFitness  float64
+	WorstFitness float64
+	NumSpecies   int
+	MeanNeurons  float64
+
+	// the fittest cortex of the generation
+	Best *ng.Cortex
+}
+
+// Population evolves a fixed number of cortexes, generation by
+// generation.  Each generation is evaluated concurrently, grouped into
+// species by Compatibility, and scored with fitness sharing.  The next
+// generation keeps the Elitism fittest members unchanged, and fills the
+// rest with mutated offspring of parents chosen by tournament selection
+// on the shared fitness (or on Pareto rank, see Objectives), recombined
+// with Crossover some of the time.
+type Population struct {
+	Members    []*Member
+	Species    []*Species
+	Generation int
+
+	// statistics of every generation evaluated so far
+	History []*GenerationStats
+
+	Fitness ng.FitnessFunc
+
+	// if not nil, members are scored with Objectives rather than Fitness.
+	// Their Fitness is the first score, and selection prefers members
+	// in better Pareto fronts, then members with larger crowding
+	// distances, rather than members with higher shared fitness.  See
+	// WithComplexity to trade fitness off against size.
+	Objectives MultiFitnessFunc
+
+	// if not nil, members are also scored by how novel their behavior is,
+	// and selection is based on the blend of novelty and fitness given
+	// by the NoveltySearch.  If there are Objectives too, novelty is
+	// added to them as a final objective instead.
+	Novelty *NoveltySearch
+
+	// the mutations applied to offspring, or nil to use
+	// TopologicalMutations() and ParameterMutations()
+	Mutations []Mutation
+
+	// members closer than this to a species representative join it
+	CompatibilityThreshold float64
+
+	// the coefficients passed to Compatibility
+	TopologyCoefficient float64
+	WeightCoefficient   float64
+
+	// number of the fittest members copied unchanged into the next
+	// generation
+	Elitism int
+
+	// number of members competing in each tournament
+	TournamentSize int
+
+	// probability that an offspring has two parents rather than one
+	CrossoverRate float64
+
+	// number of members evaluated at once, or 0 for runtime.NumCPU()
+	Concurrency int
+
+	// source of all random choices
+	Rand *rand.Rand
+
+	nextSpeciesId int
+}
+
+// NewPopulation creates a population of size members: a copy of seed,
+// and copies of seed with a few random mutations applied.  size must be
+// at least 1, or evaluating the population fails with
+// ng.ErrPopulationSize.
+func NewPopulation(seed *ng.Cortex, size int, fitness ng.FitnessFunc, rng *rand.Rand) *Population {
+
+	population := &Population{
+		Fitness:                fitness,
+		CompatibilityThreshold: 1.0,
+		TopologyCoefficient:    1.0,
+		WeightCoefficient:      0.4,
+		Elitism:                1,
+		TournamentSize:         3,
+		CrossoverRate:          0.5,
+		Rand:                   rng,
+	}
+
+	for i := 0; i < size; i++ {
+		cortex := seed.Copy()
+		if i > 0 {
+			population.mutate(cortex)
+		}
+		population.Members = append(population.Members, &Member{Cortex: cortex})
+	}
+
+	return population
+
+}
+
+// Evaluate scores every member with the Fitness function, groups the
+// members into species and applies fitness sharing.  Returns the
+// statistics of the generation, which are also appended to History.
+// Returns ng.ErrPopulationSize if the population has no members.
+func (population *Population) Evaluate() (*GenerationStats, error) {
+
+	if len(population.Members) == 0 {
+		return nil, fmt.Errorf("%w: population has no members", ng.ErrPopulationSize)
+	}
+
+	if err := population.evaluateConcurrently(); err != nil {
+		return nil, err
+	}
+
+	if population.Novelty != nil {
+		population.scoreNovelty()
+	}
+	population.speciate()
+	population.shareFitness()
+	if population.Objectives != nil {
+		population.rankPareto()
+	}
+
+	stats := population.stats()
+	population.History = append(population.History, stats)
+
+	logg.LogTo("DEBUG", "Generation %d best: %v mean: %v species: %d",
+		stats.Generation,
+		stats.BestFitness,
+		stats.MeanFitness,
+		stats.NumSpecies)
+
+	return stats, nil
+
+}
+
+// Best returns the fittest member, as of the last call to Evaluate
+func (population *Population) Best() *Member {
+	var best *Member
+	for _, member := range population.Members {
+		if best == nil || member.Fitness > best.Fitness {
+			best = member
+		}
+	}
+	return best
+}
+
+// NextGeneration replaces the members with the next generation.  The
+// current generation must have been evaluated.
+func (population *Population) NextGeneration() {
+
+	ranked := make([]*Member, len(population.Members))
+	copy(ranked, population.Members)
+	if population.Objectives == nil {
+		sort.Stable(byFitness(ranked))
+	} else {
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return population.better(ranked[i], ranked[j])
+		})
+	}
+
+	next := make([]*Member, 0, len(population.Members))
+
+	for i := 0; i < population.Elitism && i < len(ranked); i++ {
+		next = append(next, &Member{Cortex: ranked[i].Cortex.Copy()})
+	}
+
+	for len(next) < len(population.Members) {
+		next = append(next, &Member{Cortex: population.offspring()})
+	}
+
+	population.Members = next
+	population.Generation += 1
+
+}
+
+// Evolve evaluates and breeds the population until a member reaches
+// targetFitness or maxGenerations have been evaluated, and returns the
+// fittest cortex found.
+func (population *Population) Evolve(maxGenerations int, targetFitness float64) (*ng.Cortex, error) {
+
+	var best *ng.Cortex
+	bestFitness := math.Inf(-1)
+
+	for generation := 0; generation < maxGenerations; generation++ {
+
+		if generation > 0 {
+			population.NextGeneration()
+		}
+
+		stats, err := population.Evaluate()
+		if err != nil {
+			return nil, err
+		}
+		if best == nil || stats.BestFitness > bestFitness {
+			best = stats.Best
+			bestFitness = stats.BestFitness
+		}
+		if bestFitness >= targetFitness {
+			break
+		}
+
+	}
+
+	return best, nil
+
+}
+
+// StepGeneration evaluates the current generation, after replacing it
+// with the next one if it has already been evaluated, and returns its
+// fittest cortex and that cortex's fitness.  This makes a Population an
+// ng.Generational, so that it can be run by an ng.TrainingSession.
+func (population *Population) StepGeneration() (*ng.Cortex, float64, error) {
+	if n := len(population.History); n > 0 && population.History[n-1].Generation == population.Generation {
+		population.NextGeneration()
+	}
+	stats, err := population.Evaluate()
+	if err != nil {
+		return nil, 0, err
+	}
+	return stats.Best, stats.BestFitness, nil
+}
+
+func (population *Population) evaluateConcurrently() error {
+
+	concurrency := population.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	members := make(chan *Member)
+	errs := make([]error, concurrency)
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for member := range members {
+				if errs[worker] != nil {
+					continue
+				}
+				errs[worker] = population.evaluate(member)
+			}
+		}(i)
+	}
+
+	for _, member := range population.Members {
+		members <- member
+	}
+	close(members)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+
+}
+
+func (population *Population) evaluate(member *Member) error {
+	if population.Novelty != nil {
+		behavior, err := Behavior(member.Cortex, population.Novelty.Probes)
+		if err != nil {
+			return err
+		}
+		member.Behavior = behavior
+	}
+	if population.Objectives == nil {
+		fitness, err := population.Fitness(member.Cortex)
+		member.Fitness = fitness
+		return err
+	}
+	scores, err := population.Objectives(member.Cortex)
+	if err != nil {
+		return err
+	}
+	member.Scores = scores
+	member.Fitness = scores[0]
+	return nil
+}
+
+// breed a single offspring from the current generation
+func (population *Population) offspring() *ng.Cortex {
+
+	parent := population.tournament(population.Members)
+
+	var child *ng.Cortex
+	mates := parent.Species.Members
+	if len(mates) > 1 && population.Rand.Float64() < population.CrossoverRate {
+		mate := population.tournament(mates)
+		if mate.Fitness > parent.Fitness {
+			parent, mate = mate, parent
+		}
+		child = Crossover(parent.Cortex, mate.Cortex, population.Rand)
+	} else {
+		child = parent.Cortex.Copy()
+	}
+
+	population.mutate(child)
+	return child
+
+}
+
+// choose the best of TournamentSize randomly chosen candidates
+func (population *Population) tournament(candidates []*Member) *Member {
+	var winner *Member
+	for i := 0; i < population.TournamentSize || winner == nil; i++ {
+		candidate := candidates[population.Rand.Intn(len(candidates))]
+		if winner == nil || population.better(candidate, winner) {
+			winner = candidate
+		}
+	}
+	return winner
+}
+
+func (population *Population) mutate(cortex *ng.Cortex) {
+	mutations := population.Mutations
+	if mutations == nil {
+		mutations = append(TopologicalMutations(), ParameterMutations()...)
+	}
+	count := numMutations(cortex, population.Rand)
+	for i := 0; i < count; i++ {
+		Mutate(cortex, population.Rand, mutations)
+	}
+}
+
+func (population *Population) stats() *GenerationStats {
+
+	stats := &GenerationStats{
+		Generation:   population.Generation,
+		BestFitness:  math.Inf(-1),
+		WorstFitness: math.Inf(1),
+		NumSpecies:   len(population.Species),
+	}
+
+	totalFitness := float64(0)
+	totalNeurons := 0
+	for _, member := range population.Members {
+		if member.Fitness > stats.BestFitness || stats.Best == nil {
+			stats.BestFitness = member.Fitness
+			stats.Best = member.Cortex
+		}
+		stats.WorstFitness = math.Min(stats.WorstFitness, member.Fitness)
+		totalFitness += member.Fitness
+		totalNeurons += len(member.Cortex.Neurons)
+	}
+
+	size := float64(len(population.Members))
+	stats.MeanFitness = totalFitness / size
+	stats.MeanNeurons = float64(totalNeurons) / size
+
+	return stats
+
+}
+
+// sorts members from fittest to least fit
+type byFitness []*Member
+
+func (m byFitness) Len() int           { return len(m) }
+func (m byFitness) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byFitness) Less(i, j int) bool { return m[i].Fitness > m[j].Fitness }
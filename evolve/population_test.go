@@ -0,0 +1,190 @@
+package evolve
+
+import (
+	"context"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestCompatibility(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+	a := ng.XnorCortex()
+
+	assert.Equals(t, Compatibility(a, a.Copy(), 1, 1), 0.0)
+
+	// only the weights differ
+	b := a.Copy()
+	b.Neurons[0].Inbound[0].Weights[0] += 4
+	assert.Equals(t, Compatibility(a, b, 1, 0), 0.0)
+	assert.True(t, Compatibility(a, b, 0, 1) > 0)
+
+	// the topology differs too
+	c := a.Copy()
+	assert.True(t, AddNeuron(c, rng))
+	assert.True(t, Compatibility(a, c, 1, 0) > 0)
+	assert.Equals(t, Compatibility(a, c, 1, 1), Compatibility(c, a, 1, 1))
+
+}
+
+func TestSpeciation(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+	examples := ng.XnorTrainingSamples()
+	population := NewPopulation(ng.XnorCortex(), 10, ng.SampleFitness(examples, nil), rng)
+
+	population.CompatibilityThreshold = 1000
+	_, err := population.Evaluate()
+	assert.True(t, err == nil)
+	assert.Equals(t, len(population.Species), 1)
+	for _, member := range population.Members {
+		assert.Equals(t, member.AdjustedFitness, member.Fitness/10)
+	}
+
+	// nothing is closer than 0, so every member gets its own species
+	population.CompatibilityThreshold = 0
+	stats, err := population.Evaluate()
+	assert.True(t, err == nil)
+	assert.Equals(t, stats.NumSpecies, 10)
+	for _, member := range population.Members {
+		assert.Equals(t, len(member.Species.Members), 1)
+		assert.Equals(t, member.AdjustedFitness, member.Fitness)
+	}
+
+	assert.Equals(t, len(population.History), 2)
+
+}
+
+func TestPopulationEvolveXnor(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+
+	evolve := func() (*ng.Cortex, *Population) {
+		rng := rand.New(rand.NewSource(1))
+		seed := ng.SeededXnorCortexUntrained(rng)
+		population := NewPopulation(seed, 30, ng.SampleFitness(examples, nil), rng)
+		population.Concurrency = 4
+		best, err := population.Evolve(100, 10)
+		assert.True(t, err == nil)
+		return best, population
+	}
+
+	best, population := evolve()
+	assert.True(t, Validate(best) == nil)
+
+	fitness, err := best.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= 10)
+
+	last := population.History[len(population.History)-1]
+	assert.Equals(t, last.Generation, population.Generation)
+	assert.True(t, last.BestFitness >= last.MeanFitness)
+	assert.True(t, last.MeanFitness >= last.WorstFitness)
+
+	// concurrent evaluation doesn't affect the outcome
+	_, again := evolve()
+	assert.Equals(t, len(again.History), len(population.History))
+	for i, stats := range population.History {
+		assert.Equals(t, again.History[i].BestFitness, stats.BestFitness)
+		assert.Equals(t, again.History[i].NumSpecies, stats.NumSpecies)
+	}
+
+}
+
+func TestPopulationEmpty(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	rng := rand.New(rand.NewSource(1))
+	population := NewPopulation(ng.SeededXnorCortexUntrained(rng), 0, ng.SampleFitness(examples, nil), rng)
+
+	_, err := population.Evaluate()
+	assert.True(t, errors.Is(err, ng.ErrPopulationSize))
+	best, err := population.Evolve(10, 10)
+	assert.True(t, errors.Is(err, ng.ErrPopulationSize))
+	assert.True(t, best == nil)
+	_, err = ng.NewTrainingSession().Evolve(context.Background(), population, 10, 10)
+	assert.True(t, errors.Is(err, ng.ErrPopulationSize))
+
+}
+
+func TestPopulationTrainingSession(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	rng := rand.New(rand.NewSource(1))
+	population := NewPopulation(ng.SeededXnorCortexUntrained(rng), 30, ng.SampleFitness(examples, nil), rng)
+	population.Concurrency = 4
+
+	session := ng.NewTrainingSession()
+	generations := 0
+	session.OnGeneration = func(metrics *ng.EpochMetrics) {
+		generations += 1
+	}
+
+	best, err := session.Evolve(context.Background(), population, 100, 10)
+	assert.True(t, err == nil)
+	fitness, err := best.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= 10)
+
+	// every generation was evaluated exactly once
+	assert.Equals(t, generations, len(population.History))
+	for i, stats := range population.History {
+		assert.Equals(t, stats.Generation, i)
+		assert.Equals(t, session.History[i].Fitness, stats.BestFitness)
+	}
+
+}
+
+func TestPopulationResume(t *testing.T) {
+
+	examples := ng.XnorTrainingSamples()
+	newPopulation := func(session *ng.TrainingSession) *Population {
+		session.RandSource = ng.NewRandSource(1)
+		rng := rand.New(session.RandSource)
+		population := NewPopulation(ng.SeededXnorCortexUntrained(rng), 20, ng.SampleFitness(examples, nil), rng)
+		population.Concurrency = 4
+		return population
+	}
+
+	uninterrupted := ng.NewTrainingSession()
+	_, err := uninterrupted.Evolve(context.Background(), newPopulation(uninterrupted), 12, 0)
+	assert.True(t, err == nil)
+
+	dir, err := ioutil.TempDir("", "neurgo-checkpoints")
+	assert.True(t, err == nil)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted := ng.NewTrainingSession()
+	interrupted.CheckpointDir = dir
+	interrupted.CheckpointInterval = 4
+	interrupted.OnGeneration = func(metrics *ng.EpochMetrics) {
+		if metrics.Epoch == 5 {
+			cancel()
+		}
+	}
+	_, err = interrupted.Evolve(ctx, newPopulation(interrupted), 12, 0)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	resumed := ng.NewTrainingSession()
+	population := newPopulation(resumed)
+	assert.True(t, resumed.Resume(dir) == nil)
+	assert.Equals(t, resumed.Epoch, 4)
+	best, err := resumed.Evolve(context.Background(), population, 12, 0)
+	assert.True(t, err == nil)
+	assert.True(t, Validate(best) == nil)
+
+	// the resumed population evolved exactly as the uninterrupted one
+	assert.Equals(t, len(resumed.History), 12)
+	assert.Equals(t, len(population.History), 12)
+	for i, metrics := range resumed.History {
+		assert.Equals(t, metrics.Fitness, uninterrupted.History[i].Fitness)
+		assert.Equals(t, population.History[i].Generation, i)
+	}
+
+}
@@ -0,0 +1,157 @@
+package evolve
+
+import (
+	ng "github.com/maxxk/neurgo"
+	"math"
+)
+
+// A Species is a group of population members whose genomes are within
+// the compatibility threshold of its Representative.
+type Species struct {
+	Id             int
+	Representative *ng.Cortex
+	Members        []*Member
+}
+
+// Compatibility is the NEAT genome distance between two cortexes, with
+// genes aligned by UUID.  The genes are the neurons, keyed by UUID, and
+// the connections, keyed by the UUIDs of the sender and receiver.
+//
+//	distance = topologyCoefficient * disjoint / N + weightCoefficient * W
+//
+// where disjoint is the number of genes only one of the cortexes has, N
+// is the number of genes in the larger genome, and W is the mean absolute
+// difference of the biases and weights of the matching genes.
+func Compatibility(a, b *ng.Cortex, topologyCoefficient, weightCoefficient float64) float64 {
+
+	keysA, genesA := parameterGenes(a)
+	keysB, genesB := parameterGenes(b)
+
+	disjoint := 0
+	matching := 0
+	difference := float64(0)
+
+	for _, key := range keysA {
+		valuesB, ok := genesB[key]
+		if !ok {
+			disjoint += 1
+			continue
+		}
+		matching += 1
+		difference += meanAbsDifference(genesA[key], valuesB)
+	}
+	for _, key := range keysB {
+		if _, ok := genesA[key]; !ok {
+			disjoint += 1
+		}
+	}
+
+	size := len(keysA)
+	if len(keysB) > size {
+		size = len(keysB)
+	}
+
+	distance := float64(0)
+	if size > 0 {
+		distance += topologyCoefficient * float64(disjoint) / float64(size)
+	}
+	if matching > 0 {
+		distance += weightCoefficient * difference / float64(matching)
+	}
+	return distance
+
+}
+
+// the genes of cortex, each mapped to the parameters it carries: the
+// bias of a neuron, or the weights of a connection.  The keys are also
+// returned in a fixed order, so that sums over them are reproducible.
+func parameterGenes(cortex *ng.Cortex) ([]string, map[string][]float64) {
+	keys := make([]string, 0)
+	genes := make(map[string][]float64)
+	add := func(key string, values []float64) {
+		keys = append(keys, key)
+		genes[key] = values
+	}
+	for _, neuron := range cortex.Neurons {
+		add(neuron.NodeId.UUID, []float64{neuron.Bias})
+		for _, inbound := range neuron.Inbound {
+			add(inbound.NodeId.UUID+"->"+neuron.NodeId.UUID, inbound.Weights)
+		}
+	}
+	for _, actuator := range cortex.Actuators {
+		for _, inbound := range actuator.Inbound {
+			add(inbound.NodeId.UUID+"->"+actuator.NodeId.UUID, nil)
+		}
+	}
+	return keys, genes
+}
+
+func meanAbsDifference(xs, ys []float64) float64 {
+	if len(xs) == 0 || len(xs) != len(ys) {
+		return 0
+	}
+	total := float64(0)
+	for i, x := range xs {
+		total += math.Abs(x - ys[i])
+	}
+	return total / float64(len(xs))
+}
+
+// assign every member to the first species whose representative it is
+// compatible with, creating new species as needed, and drop the species
+// which end up empty
+func (population *Population) speciate() {
+
+	for _, species := range population.Species {
+		species.Members = nil
+	}
+
+	for _, member := range population.Members {
+		member.Species = nil
+		for _, species := range population.Species {
+			distance := Compatibility(
+				member.Cortex,
+				species.Representative,
+				population.TopologyCoefficient,
+				population.WeightCoefficient,
+			)
+			if distance < population.CompatibilityThreshold {
+				member.Species = species
+				break
+			}
+		}
+		if member.Species == nil {
+			population.nextSpeciesId += 1
+			member.Species = &Species{
+				Id:             population.nextSpeciesId,
+				Representative: member.Cortex,
+			}
+			population.Species = append(population.Species, member.Species)
+		}
+		member.Species.Members = append(member.Species.Members, member)
+	}
+
+	// the first member of each species represents it in the next
+	// generation
+	species := make([]*Species, 0, len(population.Species))
+	for _, s := range population.Species {
+		if len(s.Members) > 0 {
+			s.Representative = s.Members[0].Cortex
+			species = append(species, s)
+		}
+	}
+	population.Species = species
+
+}
+
+// fitness sharing: divide the fitness of every member by the size of
+// its species, so that no single species can take over the population
+func (population *Population) shareFitness() {
+	for _, member := range population.Members {
+		fitness := member.Fitness
+		if population.Novelty != nil {
+			fitness = member.blended
+		}
+		member.AdjustedFitness = fitness / float64(len(member.Species.Members))
+	}
+}
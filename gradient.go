@@ -0,0 +1,374 @@
+package neurgo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// NeuronGradient holds the partial derivatives of a loss with respect to
+// the bias and inbound weights of a single neuron.  Weights is indexed
+// the same way as neuron.Inbound and each of its InboundConnection.Weights.
+type NeuronGradient struct {
+	Bias    float64
+	Weights [][]float64
+}
+
+// Gradients maps the UUID of every neuron in a cortex to its gradient.
+type Gradients map[string]*NeuronGradient
+
+// NewGradients creates zeroed gradients shaped like the neurons in cortex
+func NewGradients(cortex *Cortex) Gradients {
+	gradients := make(Gradients)
+	for _, neuron := range cortex.Neurons {
+		weights := make([][]float64, len(neuron.Inbound))
+		for i, inbound := range neuron.Inbound {
+			weights[i] = make([]float64, len(inbound.Weights))
+		}
+		gradients[neuron.NodeId.UUID] = &NeuronGradient{
+			Weights: weights,
+		}
+	}
+	return gradients
+}
+
+// Zero resets every gradient to 0 so the Gradients can be reused
+func (gradients Gradients) Zero() {
+	for _, gradient := range gradients {
+		gradient.Bias = 0
+		for _, weights := range gradient.Weights {
+			for i := range weights {
+				weights[i] = 0
+			}
+		}
+	}
+}
+
+// a snapshot of the values computed during one call to Evaluate, which
+// are needed to backpropagate through that call afterwards
+type planTick struct {
+	inputs   [][]float64
+	nets     []float64
+	outputs  []float64
+	previous []float64
+	scales   []float64
+}
+
+// trace is the same as Evaluate, but also returns a planTick
+func (plan *EvaluationPlan) trace(inputs [][]float64) (*planTick, [][]float64, error) {
+
+	tick := &planTick{
+		inputs:   make([][]float64, len(inputs)),
+		nets:     make([]float64, len(plan.neurons)),
+		outputs:  make([]float64, len(plan.neurons)),
+		previous: make([]float64, len(plan.neurons)),
+		scales:   make([]float64, len(plan.neurons)),
+	}
+	for i := range plan.previous {
+		tick.previous[i] = plan.previous[i][0]
+	}
+
+	outputs, err := plan.Evaluate(inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	copy(tick.inputs, plan.inputs)
+	copy(tick.nets, plan.nets)
+	copy(tick.scales, plan.scales)
+	for i := range plan.outputs {
+		tick.outputs[i] = plan.outputs[i][0]
+	}
+
+	return tick, outputs, nil
+
+}
+
+// checkFeedForward returns an error if any of the neurons has a recurrent
+// inbound connection
+func (plan *EvaluationPlan) checkFeedForward() error {
+	for _, plannedNeuron := range plan.neurons {
+		for _, source := range plannedNeuron.sources {
+			if source.recurrent {
+				err := fmt.Errorf("%w: recurrent connection from %v",
+					ErrNotFeedForward,
+					source.inbound.NodeId.UUID)
+				return newNodeError(plannedNeuron.neuron.NodeId, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkDifferentiable returns an error if any of the neurons has an
+// activation function without a known derivative
+func (plan *EvaluationPlan) checkDifferentiable() error {
+	for _, plannedNeuron := range plan.neurons {
+		if plannedNeuron.neuron.ActivationFunction.Derivative == nil {
+			err := fmt.Errorf("%w: activation function has no derivative", ErrNotDifferentiable)
+			return newNodeError(plannedNeuron.neuron.NodeId, err)
+		}
+	}
+	return nil
+}
+
+// Backpropagate the gradient of a loss with respect to the actuator
+// outputs of tick (indexed like the outputs returned by Evaluate) back
+// through the network, and add the resulting gradient of every bias and
+// weight to gradients.
+//
+// carry holds the gradient of the loss with respect to the output of
+// each neuron on this tick that flowed back from recurrent connections
+// on the following tick.  The return value is the same thing for the
+// preceding tick, ready to be passed to the next call.
+func (plan *EvaluationPlan) backpropagate(tick *planTick, outputGradients [][]float64, carry []float64, gradients Gradients) []float64 {
+
+	// the gradient of the loss with respect to the output of each neuron
+	outputDeltas := make([]float64, len(plan.neurons))
+	copy(outputDeltas, carry)
+
+	previousDeltas := make([]float64, len(plan.neurons))
+
+	for i, plannedActuator := range plan.actuators {
+		for j, source := range plannedActuator.sources {
+			if source.neuron != -1 {
+				outputDeltas[source.neuron] += outputGradients[i][j]
+			}
+		}
+	}
+
+	// neurons are sorted by layer, so walking them backwards guarantees
+	// that every neuron has received the deltas of all its receivers
+	for i := len(plan.neurons) - 1; i >= 0; i-- {
+
+		plannedNeuron := plan.neurons[i]
+		neuron := plannedNeuron.neuron
+		gradient := gradients[neuron.NodeId.UUID]
+
+		derivative := neuron.ActivationFunction.Derivative(tick.nets[i])
+		delta := outputDeltas[i] * tick.scales[i] * derivative
+		gradient.Bias += delta
+
+		for j, source := range plannedNeuron.sources {
+			switch {
+			case source.sensor != -1:
+				for k, input := range tick.inputs[source.sensor] {
+					gradient.Weights[j][k] += delta * input
+				}
+			case source.recurrent:
+				// this input was the sender's output on the previous tick
+				gradient.Weights[j][0] += delta * tick.previous[source.neuron]
+				previousDeltas[source.neuron] += delta * source.inbound.Weights[0]
+			default:
+				gradient.Weights[j][0] += delta * tick.outputs[source.neuron]
+				outputDeltas[source.neuron] += delta * source.inbound.Weights[0]
+			}
+		}
+
+	}
+
+	return previousDeltas
+
+}
+
+// Feed samples through the plan in order, continuing from its current
+// recurrent state, and add the gradient of the summed sum of squares
+// error to gradients, backpropagated through all of the samples.
+// Returns the summed sum of squares error.
+func (plan *EvaluationPlan) sumOfSquaresGradients(samples []*TrainingSample, sensorIndexes, actuatorIndexes []int, gradients Gradients) (float64, error) {
+	return plan.objectiveGradients(samples, sensorIndexes, actuatorIndexes, SSE{}, gradients)
+}
+
+// the same as sumOfSquaresGradients, but for the score given by any
+// differentiable objective.  Returns the score.
+func (plan *EvaluationPlan) objectiveGradients(samples []*TrainingSample, sensorIndexes, actuatorIndexes []int, objective DifferentiableObjective, gradients Gradients) (float64, error) {
+
+	ticks := make([]*planTick, len(samples))
+	expected := make([][][]float64, len(samples))
+	actual := make([][][]float64, len(samples))
+
+	for t, sample := range samples {
+
+		inputs := make([][]float64, len(plan.sensors))
+		for i, input := range sample.SampleInputs {
+			inputs[sensorIndexes[i]] = input
+		}
+
+		tick, outputs, err := plan.trace(inputs)
+		if err != nil {
+			return 0, err
+		}
+		ticks[t] = tick
+
+		expected[t] = sample.ExpectedOutputs
+		actual[t] = make([][]float64, len(actuatorIndexes))
+		for j, index := range actuatorIndexes {
+			actual[t][j] = outputs[index]
+		}
+
+	}
+
+	// spread the gradient of the score over every actuator, leaving
+	// the ones which aren't in the samples at 0
+	sampleGradients := objective.Gradient(expected, actual)
+	outputGradients := make([][][]float64, len(samples))
+	for t := range samples {
+		outputGradients[t] = make([][]float64, len(plan.actuators))
+		for i, plannedActuator := range plan.actuators {
+			outputGradients[t][i] = make([]float64, len(plannedActuator.sources))
+		}
+		for j, index := range actuatorIndexes {
+			copy(outputGradients[t][index], sampleGradients[t][j])
+		}
+	}
+
+	carry := make([]float64, len(plan.neurons))
+	for t := len(ticks) - 1; t >= 0; t-- {
+		carry = plan.backpropagate(ticks[t], outputGradients[t], carry, gradients)
+	}
+
+	return objective.Score(expected, actual), nil
+
+}
+
+// the state of a gradient based trainer, shared by all of its epochs
+type gradientDescent struct {
+	cortex          *Cortex
+	examples        []*TrainingSample
+	mapping         *SampleMapping
+	plan            *EvaluationPlan
+	sensorIndexes   []int
+	actuatorIndexes []int
+	gradients       Gradients
+	optimizer       Optimizer
+	regularization  *Regularization
+
+	// plain gradient descent, used when there is no optimizer
+	sgd *SGD
+}
+
+// prepare to train cortex in place on examples.  If optimizer is nil,
+// plain gradient descent with learningRate is used.  regularization may
+// be nil.
+func newGradientDescent(cortex *Cortex, examples []*TrainingSample, mapping *SampleMapping, optimizer Optimizer, learningRate float64, regularization *Regularization) (*gradientDescent, error) {
+
+	if err := mapping.validate(cortex, examples); err != nil {
+		return nil, err
+	}
+	sensorIndexes, actuatorIndexes, err := mapping.resolve(cortex)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := cortex.Compile()
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.checkDifferentiable(); err != nil {
+		return nil, err
+	}
+
+	descent := &gradientDescent{
+		cortex:          cortex,
+		examples:        examples,
+		mapping:         mapping,
+		plan:            plan,
+		sensorIndexes:   sensorIndexes,
+		actuatorIndexes: actuatorIndexes,
+		gradients:       NewGradients(cortex),
+	}
+	descent.configure(optimizer, learningRate, regularization)
+	return descent, nil
+
+}
+
+// reuse the gradient descent for another epoch, picking up any change to
+// the optimizer, learning rate or regularization since the last one.
+// Returns false if it was prepared for a different cortex, examples or
+// mapping, in which case it has to be rebuilt.
+func (descent *gradientDescent) reuse(cortex *Cortex, examples []*TrainingSample, mapping *SampleMapping, optimizer Optimizer, learningRate float64, regularization *Regularization) bool {
+	if descent == nil || descent.cortex != cortex || descent.mapping != mapping {
+		return false
+	}
+	if len(descent.examples) != len(examples) {
+		return false
+	}
+	for i, sample := range examples {
+		if descent.examples[i] != sample {
+			return false
+		}
+	}
+	descent.configure(optimizer, learningRate, regularization)
+	return true
+}
+
+func (descent *gradientDescent) configure(optimizer Optimizer, learningRate float64, regularization *Regularization) {
+	if optimizer == nil {
+		if descent.sgd == nil {
+			descent.sgd = NewSGD(learningRate)
+		}
+		descent.sgd.LearningRate = learningRate
+		optimizer = descent.sgd
+	}
+	descent.optimizer = optimizer
+	descent.regularization = regularization
+}
+
+// backpropagate through samples in training mode, continuing from the
+// current recurrent state of the plan, and update the cortex.  Returns the summed sum of
+// squares error of the samples, without the regularization penalty,
+// which is added once per epoch by penalty.
+func (descent *gradientDescent) step(samples []*TrainingSample) (float64, error) {
+	descent.gradients.Zero()
+	training := descent.cortex.Training
+	descent.cortex.Training = true
+	loss, err := descent.plan.sumOfSquaresGradients(samples, descent.sensorIndexes, descent.actuatorIndexes, descent.gradients)
+	descent.cortex.Training = training
+	if err != nil {
+		return 0, err
+	}
+	regularization := descent.regularization
+	if regularization != nil {
+		regularization.addGradients(descent.cortex, descent.gradients)
+	}
+	descent.optimizer.Step(descent.cortex, descent.gradients)
+	if regularization != nil {
+		regularization.update(descent.cortex)
+	}
+	return loss, nil
+}
+
+// the regularization penalty of the cortex as it is now, or 0 if there is
+// no regularization
+func (descent *gradientDescent) penalty() float64 {
+	if descent.regularization == nil {
+		return 0
+	}
+	return descent.regularization.Penalty(descent.cortex)
+}
+
+// the state of a gradient based trainer saved in a checkpoint
+type gradientCheckpoint struct {
+	LearningRate float64
+	Optimizer    *EncodableOptimizer `json:",omitempty"`
+}
+
+func marshalGradientCheckpoint(learningRate float64, optimizer Optimizer) ([]byte, error) {
+	checkpoint := gradientCheckpoint{LearningRate: learningRate}
+	if optimizer != nil {
+		checkpoint.Optimizer = &EncodableOptimizer{optimizer}
+	}
+	return json.Marshal(checkpoint)
+}
+
+func unmarshalGradientCheckpoint(data []byte, learningRate *float64, optimizer *Optimizer) error {
+	checkpoint := gradientCheckpoint{}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return err
+	}
+	*learningRate = checkpoint.LearningRate
+	*optimizer = nil
+	if checkpoint.Optimizer != nil {
+		*optimizer = checkpoint.Optimizer.Optimizer
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package neurgo
+
+import (
+	"fmt"
+	"math"
+)
+
+// the step used for the central finite differences in GradientCheck
+const gradientCheckStep = 1e-6
+
+// GradientCheckReport maps the UUID of every neuron in a cortex to the
+// worst relative error found in the gradient of its bias and inbound
+// weights
+type GradientCheckReport map[string]float64
+
+// Worst returns the neuron with the largest relative error, and the error
+func (report GradientCheckReport) Worst() (string, float64) {
+	worstUUID := ""
+	worst := float64(0)
+	for uuid, relativeError := range report {
+		if worstUUID == "" || relativeError > worst || (relativeError == worst && uuid < worstUUID) {
+			worstUUID = uuid
+			worst = relativeError
+		}
+	}
+	return worstUUID, worst
+}
+
+// GradientCheck verifies the gradients which the gradient based trainers
+// would compute for cortex, by comparing the gradient of the objective's
+// score on the samples with respect to every bias and weight against a
+// central finite difference of the score.  The samples are fed through
+// the cortex in order as a single sequence, starting with every
+// recurrent connection primed to 0, and are mapped to sensors and
+// actuators by index.  Each parameter is restored once its difference
+// has been taken, and any Noise is ignored.
+//
+// The relative error of a parameter is the difference between the two
+// gradients divided by the sum of their magnitudes, or by 1 if that is
+// smaller, so that gradients close to 0 are compared absolutely.
+// Returns an error wrapping ErrNotDifferentiable if the objective or
+// any activation function has no derivative.
+func GradientCheck(cortex *Cortex, samples []*TrainingSample, objective Objective) (GradientCheckReport, error) {
+
+	differentiable, ok := objective.(DifferentiableObjective)
+	if !ok {
+		return nil, fmt.Errorf("%w: objective has no gradient", ErrNotDifferentiable)
+	}
+
+	training := cortex.Training
+	cortex.Training = false
+	defer func() {
+		cortex.Training = training
+	}()
+
+	var mapping *SampleMapping
+	if err := mapping.validate(cortex, samples); err != nil {
+		return nil, err
+	}
+	sensorIndexes, actuatorIndexes, err := mapping.resolve(cortex)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := cortex.Compile()
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.checkDifferentiable(); err != nil {
+		return nil, err
+	}
+
+	gradients := NewGradients(cortex)
+	_, err = plan.objectiveGradients(samples, sensorIndexes, actuatorIndexes, differentiable, gradients)
+	if err != nil {
+		return nil, err
+	}
+
+	score := func() (float64, error) {
+		plan.Reset()
+		return plan.score(samples, sensorIndexes, actuatorIndexes, objective)
+	}
+
+	report := make(GradientCheckReport)
+	pointers := cortex.parameterPointers()
+	for i, parameter := range cortex.ParameterLayout() {
+
+		pointer := pointers[i]
+		original := *pointer
+		*pointer = original + gradientCheckStep
+		above, err := score()
+		if err != nil {
+			return nil, err
+		}
+		*pointer = original - gradientCheckStep
+		below, err := score()
+		if err != nil {
+			return nil, err
+		}
+		*pointer = original
+		numeric := (above - below) / (2 * gradientCheckStep)
+
+		gradient := gradients[parameter.NeuronUUID]
+		analytic := gradient.Bias
+		if !parameter.IsBias() {
+			analytic = gradient.Weights[parameter.Inbound][parameter.Weight]
+		}
+
+		scale := math.Max(1, math.Abs(analytic)+math.Abs(numeric))
+		relativeError := math.Abs(analytic-numeric) / scale
+		report[parameter.NeuronUUID] = math.Max(report[parameter.NeuronUUID], relativeError)
+
+	}
+
+	return report, nil
+
+}
@@ -0,0 +1,161 @@
+package neurgo
+
+import (
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"math/rand"
+	"testing"
+)
+
+// sensor -> n1 -> n2 -> n3 -> actuator at fractional layers, with skip
+// connections from the sensor and n1 straight to n3
+func skipConnectionCortex() *Cortex {
+
+	sensor := &Sensor{
+		NodeId:       NewSensorId("sensor", 0.0),
+		VectorLength: 2,
+	}
+	sensor.Init()
+
+	neuron1 := &Neuron{
+		ActivationFunction: EncodableTanh(),
+		NodeId:             NewNeuronId("neuron1", 0.3),
+		Bias:               0.2,
+	}
+	neuron1.Init()
+
+	neuron2 := &Neuron{
+		ActivationFunction: EncodableGaussian(),
+		NodeId:             NewNeuronId("neuron2", 0.45),
+		Bias:               -0.4,
+	}
+	neuron2.Init()
+
+	neuron3 := &Neuron{
+		ActivationFunction: EncodableSigmoid(),
+		NodeId:             NewNeuronId("neuron3", 0.9),
+		Bias:               0.1,
+	}
+	neuron3.Init()
+
+	actuator := &Actuator{
+		NodeId:       NewActuatorId("actuator", 1.0),
+		VectorLength: 1,
+	}
+	actuator.Init()
+
+	sensor.ConnectOutbound(neuron1)
+	neuron1.ConnectInboundWeighted(sensor, []float64{0.8, -1.2})
+
+	neuron1.ConnectOutbound(neuron2)
+	neuron2.ConnectInboundWeighted(neuron1, []float64{1.5})
+
+	neuron2.ConnectOutbound(neuron3)
+	neuron3.ConnectInboundWeighted(neuron2, []float64{-0.7})
+
+	sensor.ConnectOutbound(neuron3)
+	neuron3.ConnectInboundWeighted(sensor, []float64{0.3, 0.9})
+
+	neuron1.ConnectOutbound(neuron3)
+	neuron3.ConnectInboundWeighted(neuron1, []float64{1.1})
+
+	neuron3.ConnectOutbound(actuator)
+	actuator.ConnectInbound(neuron3)
+
+	cortex := &Cortex{
+		NodeId: NewCortexId("cortex"),
+	}
+	cortex.SetSensors([]*Sensor{sensor})
+	cortex.SetNeurons([]*Neuron{neuron3, neuron1, neuron2})
+	cortex.SetActuators([]*Actuator{actuator})
+
+	return cortex
+
+}
+
+func skipConnectionSamples() []*TrainingSample {
+	return []*TrainingSample{
+		{SampleInputs: [][]float64{{0, 1}}, ExpectedOutputs: [][]float64{{1}}},
+		{SampleInputs: [][]float64{{1, 1}}, ExpectedOutputs: [][]float64{{0}}},
+		{SampleInputs: [][]float64{{-0.5, 0.25}}, ExpectedOutputs: [][]float64{{1}}},
+		{SampleInputs: [][]float64{{0.75, -1}}, ExpectedOutputs: [][]float64{{0}}},
+	}
+}
+
+func assertGradientCheckPasses(t *testing.T, cortex *Cortex, samples []*TrainingSample, objective Objective) {
+	report, err := GradientCheck(cortex, samples, objective)
+	assert.True(t, err == nil)
+	assert.Equals(t, len(report), len(cortex.Neurons))
+	_, worst := report.Worst()
+	assert.True(t, worst < 1e-5)
+}
+
+func TestGradientCheckObjectives(t *testing.T) {
+	objectives := []Objective{
+		SSE{},
+		MSE{},
+		MAE{},
+		Huber{Delta: 0.1},
+		BinaryCrossEntropy{},
+		CategoricalCrossEntropy{},
+	}
+	for _, objective := range objectives {
+		assertGradientCheckPasses(t, SeededXnorCortexUntrained(rand.New(rand.NewSource(3))), XnorTrainingSamples(), objective)
+		assertGradientCheckPasses(t, skipConnectionCortex(), skipConnectionSamples(), objective)
+	}
+}
+
+func TestGradientCheckRecurrent(t *testing.T) {
+	samples := backEdgeRecurrentSamples(t, 0.5)
+	assertGradientCheckPasses(t, backEdgeRecurrentCortex(), samples, SSE{})
+	assertGradientCheckPasses(t, backEdgeRecurrentCortex(), samples, Huber{Delta: 0.3})
+}
+
+func TestGradientCheckIgnoresNoise(t *testing.T) {
+	cortex := skipConnectionCortex()
+	cortex.Training = true
+	cortex.Noise = &Noise{Dropout: 0.5, InputStdDev: 1}
+	parameters := cortex.Parameters()
+	assertGradientCheckPasses(t, cortex, skipConnectionSamples(), MSE{})
+	assert.True(t, cortex.Training)
+	for i, parameter := range cortex.Parameters() {
+		assert.True(t, parameter == parameters[i])
+	}
+}
+
+// SSE with a gradient that's off by a factor
+type scaledGradientSSE struct {
+	SSE
+}
+
+func (objective scaledGradientSSE) Gradient(expected, actual [][][]float64) [][][]float64 {
+	gradient := objective.SSE.Gradient(expected, actual)
+	for _, sample := range gradient {
+		for _, vector := range sample {
+			for i := range vector {
+				vector[i] *= 1.5
+			}
+		}
+	}
+	return gradient
+}
+
+func TestGradientCheckFindsErrors(t *testing.T) {
+
+	report, err := GradientCheck(skipConnectionCortex(), skipConnectionSamples(), scaledGradientSSE{})
+	assert.True(t, err == nil)
+	_, worst := report.Worst()
+	assert.True(t, worst > 0.01)
+
+	_, err = GradientCheck(skipConnectionCortex(), skipConnectionSamples(), Accuracy{Threshold: 0.5})
+	assert.True(t, errors.Is(err, ErrNotDifferentiable))
+
+	cortex := skipConnectionCortex()
+	cortex.Neurons[0].ActivationFunction = &EncodableActivation{
+		Name:               "custom",
+		ActivationFunction: EncodableSigmoid().ActivationFunction,
+	}
+	_, err = GradientCheck(cortex, skipConnectionSamples(), SSE{})
+	assert.True(t, errors.Is(err, ErrNotDifferentiable))
+
+}
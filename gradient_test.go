@@ -0,0 +1,86 @@
+package neurgo
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func sumOfSquaresLoss(t *testing.T, cortex *Cortex, samples []*TrainingSample) float64 {
+	fitness, err := cortex.Fitness(samples)
+	assert.True(t, err == nil)
+	return 1 / fitness
+}
+
+// Compare the gradients computed by backpropagating through the entire
+// sequence of samples against central finite differences of the loss.
+func assertGradientsMatchFiniteDifferences(t *testing.T, cortex *Cortex, samples []*TrainingSample) {
+
+	plan, err := cortex.Compile()
+	assert.True(t, err == nil)
+
+	sensorIndexes, actuatorIndexes, err := (*SampleMapping)(nil).resolve(cortex)
+	assert.True(t, err == nil)
+
+	gradients := NewGradients(cortex)
+	loss, err := plan.sumOfSquaresGradients(samples, sensorIndexes, actuatorIndexes, gradients)
+	assert.True(t, err == nil)
+	assert.True(t, EqualsWithMaxDelta(loss, sumOfSquaresLoss(t, cortex, samples), 1e-9))
+
+	h := 1e-6
+	centralDifference := func(parameter *float64) float64 {
+		original := *parameter
+		*parameter = original + h
+		lossPlus := sumOfSquaresLoss(t, cortex, samples)
+		*parameter = original - h
+		lossMinus := sumOfSquaresLoss(t, cortex, samples)
+		*parameter = original
+		return (lossPlus - lossMinus) / (2 * h)
+	}
+	assertClose := func(analytic, numeric float64) {
+		scale := math.Max(1, math.Abs(analytic)+math.Abs(numeric))
+		assert.True(t, math.Abs(analytic-numeric)/scale < 1e-5)
+	}
+
+	for _, neuron := range cortex.Neurons {
+		gradient := gradients[neuron.NodeId.UUID]
+		assertClose(gradient.Bias, centralDifference(&neuron.Bias))
+		for i, inbound := range neuron.Inbound {
+			for j := range inbound.Weights {
+				assertClose(gradient.Weights[i][j], centralDifference(&inbound.Weights[j]))
+			}
+		}
+	}
+
+}
+
+func TestGradientsFeedForward(t *testing.T) {
+	cortex := SeededXnorCortexUntrained(rand.New(rand.NewSource(3)))
+	assertGradientsMatchFiniteDifferences(t, cortex, XnorTrainingSamples())
+}
+
+func TestGradientsThroughTimeSelfLoop(t *testing.T) {
+	cortex, err := NewCortexFromJSONString(exampleRecurrentCortexJson())
+	assert.True(t, err == nil)
+
+	// scale the weights down so the sigmoids aren't saturated
+	for _, neuron := range cortex.Neurons {
+		neuron.Bias /= 10
+		for _, inbound := range neuron.Inbound {
+			for i := range inbound.Weights {
+				inbound.Weights[i] /= 10
+			}
+		}
+	}
+	cortex.Neurons[2].Inbound[2].Weights[0] = 1.5
+
+	samples := append(XnorTrainingSamples(), XnorTrainingSamples()...)
+	assertGradientsMatchFiniteDifferences(t, cortex, samples)
+}
+
+func TestGradientsThroughTimeBackEdge(t *testing.T) {
+	cortex := backEdgeRecurrentCortex()
+	samples := backEdgeRecurrentSamples(t, 0.5)
+	assertGradientsMatchFiniteDifferences(t, cortex, samples)
+}
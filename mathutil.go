@@ -38,11 +38,11 @@ func NormalizeInRange(params NormalizeParams, value float64) float64 {
 	return value
 }
 
+// SafeScalarInverse is 1 / x, with x bounded below by 1e-9 so that it is
+// never infinite.  Every x at or below 1e-9, including 0, gives 1e9, so a
+// smaller error never has a lower inverse.
 func SafeScalarInverse(x float64) float64 {
-	if x == 0 {
-		x += 0.000000001
-	}
-	return 1.0 / x
+	return 1.0 / math.Max(x, 0.000000001)
 }
 
 // http://en.wikipedia.org/wiki/Residual_sum_of_squares
@@ -158,3 +158,75 @@ func Average(xs []float64) float64 {
 	}
 	return total / float64(len(xs))
 }
+
+// Eigen decomposition of the symmetric matrix a with the cyclic Jacobi
+// method.  Returns the eigenvalues, and the corresponding eigenvectors
+// as the columns of a matrix.  a is left untouched.
+func symmetricEigen(a [][]float64) ([]float64, [][]float64) {
+
+	n := len(a)
+	m := make([][]float64, n)
+	vectors := make([][]float64, n)
+	for i := range a {
+		m[i] = make([]float64, n)
+		copy(m[i], a[i])
+		vectors[i] = make([]float64, n)
+		vectors[i][i] = 1
+	}
+
+	for sweep := 0; sweep < 100; sweep++ {
+
+		offDiagonal := float64(0)
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				offDiagonal += m[i][j] * m[i][j]
+			}
+		}
+		if offDiagonal < 1e-30 {
+			break
+		}
+
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+
+				if m[p][q] == 0 {
+					continue
+				}
+
+				// rotate so that m[p][q] becomes 0
+				theta := (m[q][q] - m[p][p]) / (2 * m[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+
+				for k := 0; k < n; k++ {
+					mkp, mkq := m[k][p], m[k][q]
+					m[k][p] = c*mkp - s*mkq
+					m[k][q] = s*mkp + c*mkq
+				}
+				for k := 0; k < n; k++ {
+					mpk, mqk := m[p][k], m[q][k]
+					m[p][k] = c*mpk - s*mqk
+					m[q][k] = s*mpk + c*mqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := vectors[k][p], vectors[k][q]
+					vectors[k][p] = c*vkp - s*vkq
+					vectors[k][q] = s*vkp + c*vkq
+				}
+
+			}
+		}
+
+	}
+
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = m[i][i]
+	}
+	return values, vectors
+
+}
@@ -43,6 +43,16 @@ func TestSafeScalarInverse(t *testing.T) {
 	value := SafeScalarInverse(0)
 	assert.True(t, value > 1000000)
 	assert.True(t, EqualsWithMaxDelta(SafeScalarInverse(1), 1.0, .0001))
+
+	// the inverse never falls as x falls, and is capped at 1e9
+	sses := []float64{1e-6, 1e-9, 1e-12, 0}
+	for i := 1; i < len(sses); i++ {
+		assert.True(t, SafeScalarInverse(sses[i]) >= SafeScalarInverse(sses[i-1]))
+	}
+	assert.True(t, SafeScalarInverse(1e-6) < SafeScalarInverse(1e-9))
+	assert.Equals(t, SafeScalarInverse(1e-12), SafeScalarInverse(1e-9))
+	assert.Equals(t, SafeScalarInverse(0), SafeScalarInverse(1e-9))
+	assert.True(t, EqualsWithMaxDelta(SafeScalarInverse(0), 1e9, 1e-3))
 }
 
 func TestRandomInRange(t *testing.T) {
@@ -124,3 +134,29 @@ func TestNormalizeInRange(t *testing.T) {
 	}
 
 }
+
+func TestSymmetricEigen(t *testing.T) {
+
+	a := [][]float64{
+		{4, 1, -2},
+		{1, 2, 0},
+		{-2, 0, 3},
+	}
+
+	values, vectors := symmetricEigen(a)
+
+	// a v = lambda v for every eigenvector
+	for k, value := range values {
+		for i := range a {
+			av := float64(0)
+			for j := range a {
+				av += a[i][j] * vectors[j][k]
+			}
+			assert.True(t, EqualsWithMaxDelta(av, value*vectors[i][k], 1e-9))
+		}
+	}
+
+	// the trace is the sum of the eigenvalues
+	assert.True(t, EqualsWithMaxDelta(values[0]+values[1]+values[2], 9, 1e-9))
+
+}
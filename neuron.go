@@ -1,6 +1,7 @@
 package neurgo
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,7 +9,6 @@ import (
 	"github.com/proxypoke/vector"
 	"log"
 	"sync"
-	"time"
 )
 
 type Neuron struct {
@@ -20,6 +20,8 @@ type Neuron struct {
 	DataChan           chan *DataMessage
 	ActivationFunction *EncodableActivation
 	wg                 *sync.WaitGroup
+	closing            chan chan bool
+	stopped            chan struct{}
 	Cortex             *Cortex
 	weightedInputs     []*weightedInput
 }
@@ -36,19 +38,26 @@ func (neuron *Neuron) Init() {
 	if neuron.wg == nil {
 		neuron.wg = &sync.WaitGroup{}
 		neuron.wg.Add(1)
+		neuron.closing = neuron.Closing
+		neuron.stopped = make(chan struct{})
 	}
 }
 
-func (neuron *Neuron) Run() {
+func (neuron *Neuron) Run(ctx context.Context) {
 
 	defer neuron.wg.Done()
+	defer close(neuron.stopped)
+	defer neuron.Cortex.recoverNode(neuron.NodeId)
 
 	closed := false
 
-	neuron.checkRunnable()
+	if err := neuron.checkRunnable(); err != nil {
+		neuron.Cortex.fail(newNodeError(neuron.NodeId, err))
+		return
+	}
 	neuron.createEmptyWeightedInputs()
 
-	closed = neuron.primeAllRecurrentOutbound()
+	closed = neuron.primeAllRecurrentOutbound(ctx)
 	if closed {
 		neuron.closeChannels()
 		return
@@ -56,6 +65,8 @@ func (neuron *Neuron) Run() {
 
 	for {
 		select {
+		case <-ctx.Done():
+			closed = true
 		case responseChan := <-neuron.Closing:
 			closed = true
 			responseChan <- true
@@ -64,7 +75,7 @@ func (neuron *Neuron) Run() {
 			neuron.receiveDataMessage(dataMessage)
 			neuron.logPostReceivedDataMessage(dataMessage)
 			if neuron.receiveBarrierSatisfied() {
-				closed = neuron.feedForward()
+				closed = neuron.feedForward(ctx)
 			}
 		}
 
@@ -77,19 +88,19 @@ func (neuron *Neuron) Run() {
 
 }
 
-func (neuron *Neuron) Shutdown() {
+// Shutdown stops the goroutine started by Run and waits for it to
+// return.  Returns ErrNodeStopped if it has already stopped, for
+// instance because the cortex was stopped or its context cancelled.
+func (neuron *Neuron) Shutdown() error {
 
-	closingResponse := make(chan bool)
-	neuron.Closing <- closingResponse
-	response := <-closingResponse
-	if response != true {
-		log.Panicf("Got unexpected response on closing channel")
+	if err := shutdownNode(neuron.NodeId, neuron.closing, neuron.stopped); err != nil {
+		return err
 	}
 
 	neuron.shutdownOutboundConnections()
 
-	neuron.wg.Wait()
-	neuron.wg = nil
+	neuron.wait()
+	return nil
 }
 
 func (neuron *Neuron) Copy() *Neuron {
@@ -165,6 +176,16 @@ func (neuron *Neuron) IsInboundConnectionRecurrent(connection *InboundConnection
 	return false
 }
 
+// whether any of the neuron's outbound connections go to an actuator
+func (neuron *Neuron) feedsActuator() bool {
+	for _, connection := range neuron.Outbound {
+		if connection.NodeId.NodeType == ACTUATOR {
+			return true
+		}
+	}
+	return false
+}
+
 func (neuron *Neuron) InboundUUIDMap() UUIDToInboundConnection {
 	inboundUUIDMap := make(UUIDToInboundConnection)
 	for _, connection := range neuron.Inbound {
@@ -194,9 +215,18 @@ func (neuron *Neuron) MarshalJSON() ([]byte, error) {
 		})
 }
 
-func (neuron *Neuron) feedForward() (closed bool) {
+// compute the output of the neuron and send it on.  If the output can't
+// be computed, the neuron fails and closes.
+func (neuron *Neuron) feedForward(ctx context.Context) (closed bool) {
 
-	scalarOutput := neuron.computeScalarOutput(neuron.weightedInputs)
+	scalarOutput, err := neuron.computeScalarOutput(neuron.weightedInputs)
+	if err != nil {
+		neuron.Cortex.fail(newNodeError(neuron.NodeId, err))
+		return true
+	}
+	if noise := neuron.Cortex.noise(); noise != nil {
+		scalarOutput, _ = noise.perturbOutput(neuron, scalarOutput)
+	}
 
 	neuron.weightedInputs = createEmptyWeightedInputs(neuron.Inbound)
 
@@ -205,11 +235,11 @@ func (neuron *Neuron) feedForward() (closed bool) {
 		Inputs:   []float64{scalarOutput},
 	}
 
-	closed = neuron.scatterOutput(dataMessage)
+	closed = neuron.scatterOutput(ctx, dataMessage)
 	return
 }
 
-func (neuron *Neuron) scatterOutput(dataMessage *DataMessage) (closed bool) {
+func (neuron *Neuron) scatterOutput(ctx context.Context, dataMessage *DataMessage) (closed bool) {
 
 	closed = false
 
@@ -221,7 +251,7 @@ func (neuron *Neuron) scatterOutput(dataMessage *DataMessage) (closed bool) {
 
 			neuron.receiveRecurrentDataMessage(dataMessage)
 			if neuron.receiveBarrierSatisfied() {
-				closed = neuron.feedForward()
+				closed = neuron.feedForward(ctx)
 			}
 
 		} else {
@@ -229,6 +259,8 @@ func (neuron *Neuron) scatterOutput(dataMessage *DataMessage) (closed bool) {
 				outboundConnection.NodeId, dataMessage)
 
 			select {
+			case <-ctx.Done():
+				return true
 			case responseChan := <-neuron.Closing:
 				closed = true
 				responseChan <- true
@@ -262,7 +294,7 @@ func (neuron *Neuron) setInbound(newInbound []*InboundConnection) {
 	neuron.Inbound = newInbound
 }
 
-func (neuron *Neuron) primeRecurrentOutbound(cxn *OutboundConnection) (closed bool) {
+func (neuron *Neuron) primeRecurrentOutbound(ctx context.Context, cxn *OutboundConnection) (closed bool) {
 
 	inputs := []float64{0}
 	dataMessage := &DataMessage{
@@ -275,8 +307,9 @@ func (neuron *Neuron) primeRecurrentOutbound(cxn *OutboundConnection) (closed bo
 		// channel based messaging so we can use unbuffered channels
 		neuron.receiveRecurrentDataMessage(dataMessage)
 		if neuron.receiveBarrierSatisfied() {
-			msg := "Receive Barrier not expected to be satisfied yet"
-			logg.LogPanic(msg)
+			err := errors.New("receive barrier not expected to be satisfied yet")
+			neuron.Cortex.fail(newNodeError(neuron.NodeId, err))
+			return true
 		}
 
 	} else {
@@ -284,13 +317,15 @@ func (neuron *Neuron) primeRecurrentOutbound(cxn *OutboundConnection) (closed bo
 		logPreSend(neuron.NodeId, cxn.NodeId, dataMessage)
 
 		if cxn.DataChan == nil {
-			log.Panicf("DataChan is nil for connection: %v", cxn)
+			err := fmt.Errorf("DataChan is nil for connection: %v", cxn)
+			neuron.Cortex.fail(newNodeError(neuron.NodeId, err))
+			return true
 		}
 
 		select {
 		case cxn.DataChan <- dataMessage:
-		case <-time.After(time.Second):
-			log.Panicf("Timeout sending to %v", cxn)
+		case <-ctx.Done():
+			return true
 		case responseChan := <-neuron.Closing:
 			closed = true
 			responseChan <- true
@@ -309,11 +344,11 @@ func (neuron *Neuron) primeRecurrentOutbound(cxn *OutboundConnection) (closed bo
 // to a neuron in a previous (eg, to the left) layer.  If we didn't do this,
 // that previous neuron would be waiting forever for a signal that will
 // never come, because this neuron wouldn't fire until it got a signal.
-func (neuron *Neuron) primeAllRecurrentOutbound() (closed bool) {
+func (neuron *Neuron) primeAllRecurrentOutbound(ctx context.Context) (closed bool) {
 	closed = false
 	recurrentConnections := neuron.RecurrentOutboundConnections()
 	for _, recurrentConnection := range recurrentConnections {
-		closed := neuron.primeRecurrentOutbound(recurrentConnection)
+		closed = neuron.primeRecurrentOutbound(ctx, recurrentConnection)
 		if closed {
 			break
 		}
@@ -321,38 +356,42 @@ func (neuron *Neuron) primeAllRecurrentOutbound() (closed bool) {
 	return
 }
 
-func (neuron *Neuron) checkRunnable() {
+// wait blocks until the goroutine started by Run has returned
+func (neuron *Neuron) wait() {
+	if neuron.wg != nil {
+		neuron.wg.Wait()
+		neuron.wg = nil
+	}
+}
+
+func (neuron *Neuron) checkRunnable() error {
 
 	if neuron.NodeId == nil {
-		msg := fmt.Sprintf("not expecting neuron.NodeId to be nil")
-		panic(msg)
+		return errors.New("not expecting neuron.NodeId to be nil")
 	}
 
 	if neuron.Inbound == nil {
-		msg := fmt.Sprintf("not expecting neuron.Inbound to be nil.  neuron: %v", neuron)
-		panic(msg)
+		return fmt.Errorf("not expecting neuron.Inbound to be nil.  neuron: %v", neuron)
 	}
 
 	if neuron.Closing == nil {
-		msg := fmt.Sprintf("not expecting neuron.Closing to be nil")
-		panic(msg)
+		return errors.New("not expecting neuron.Closing to be nil")
 	}
 
 	if neuron.DataChan == nil {
-		msg := fmt.Sprintf("not expecting neuron.DataChan to be nil")
-		panic(msg)
+		return errors.New("not expecting neuron.DataChan to be nil")
 	}
 
 	if neuron.ActivationFunction == nil {
-		msg := fmt.Sprintf("not expecting neuron.ActivationFunction to be nil")
-		panic(msg)
+		return errors.New("not expecting neuron.ActivationFunction to be nil")
 	}
 
 	if err := neuron.validateOutbound(); err != nil {
-		msg := fmt.Sprintf("invalid outbound connection(s): %v", err.Error())
-		panic(msg)
+		return fmt.Errorf("invalid outbound connection(s): %v", err.Error())
 	}
 
+	return nil
+
 }
 
 func (neuron *Neuron) validateOutbound() error {
@@ -365,8 +404,11 @@ func (neuron *Neuron) validateOutbound() error {
 	return nil
 }
 
-func (neuron *Neuron) computeScalarOutput(weightedInputs []*weightedInput) float64 {
-	output := neuron.weightedInputDotProductSum(weightedInputs)
+func (neuron *Neuron) computeScalarOutput(weightedInputs []*weightedInput) (float64, error) {
+	output, err := neuron.weightedInputDotProductSum(weightedInputs)
+	if err != nil {
+		return 0, err
+	}
 	logmsg := fmt.Sprintf("%v raw output: %v", neuron.NodeId.UUID, output)
 	logg.LogTo("NODE_STATE", logmsg)
 	output += neuron.Bias
@@ -375,12 +417,26 @@ func (neuron *Neuron) computeScalarOutput(weightedInputs []*weightedInput) float
 	output = neuron.ActivationFunction.ActivationFunction(output)
 	logmsg = fmt.Sprintf("%v after activation: %v", neuron.NodeId.UUID, output)
 	logg.LogTo("NODE_STATE", logmsg)
-	return output
+	return output, nil
+}
+
+// the input to the activation function, computed the same way as in
+// computeScalarOutput but without the logging, for use by the
+// EvaluationPlan where formatting log messages would dominate the cost
+func (neuron *Neuron) netInput(weightedInputs []*weightedInput) (float64, error) {
+	output, err := neuron.weightedInputDotProductSum(weightedInputs)
+	if err != nil {
+		return 0, err
+	}
+	output += neuron.Bias
+	return output, nil
 }
 
 // for each weighted input vector, calculate the (inputs * weights) dot product
-// and sum all of these dot products together to produce a sum
-func (neuron *Neuron) weightedInputDotProductSum(weightedInputs []*weightedInput) float64 {
+// and sum all of these dot products together to produce a sum.  Returns
+// an error wrapping ErrVectorLength if any of the input vectors is not
+// the same length as its weights.
+func (neuron *Neuron) weightedInputDotProductSum(weightedInputs []*weightedInput) (float64, error) {
 
 	var dotProductSummation float64
 	dotProductSummation = 0
@@ -390,16 +446,18 @@ func (neuron *Neuron) weightedInputDotProductSum(weightedInputs []*weightedInput
 		weights := weightedInput.weights
 		inputVector := vector.NewFrom(inputs)
 		weightVector := vector.NewFrom(weights)
-		dotProduct, error := vector.DotProduct(inputVector, weightVector)
-		if error != nil {
-			t := "%T error performing dot product between %v and %v"
-			message := fmt.Sprintf(t, neuron, inputVector, weightVector)
-			panic(message)
+		dotProduct, err := vector.DotProduct(inputVector, weightVector)
+		if err != nil {
+			return 0, fmt.Errorf("%w: got %d values from %v, expected %d",
+				ErrVectorLength,
+				len(inputs),
+				weightedInput.senderNodeUUID,
+				len(weights))
 		}
 		dotProductSummation += dotProduct
 	}
 
-	return dotProductSummation
+	return dotProductSummation, nil
 
 }
 
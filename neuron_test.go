@@ -1,7 +1,9 @@
 package neurgo
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/couchbaselabs/go.assert"
 	"log"
@@ -146,8 +148,8 @@ func TestRecurrentNeuron(t *testing.T) {
 	neuronN1.Init()
 	neuronN2.Init()
 
-	go neuronN1.Run()
-	go neuronN2.Run()
+	go neuronN1.Run(context.Background())
+	go neuronN2.Run(context.Background())
 
 	// send one input
 	inputs_1 := []float64{20, 20, 20, 20, 20}
@@ -261,7 +263,7 @@ func TestRunningNeuron(t *testing.T) {
 	}
 
 	neuron.Init()
-	go neuron.Run()
+	go neuron.Run(context.Background())
 
 	// send one input
 	inputs_1 := []float64{20, 20, 20, 20, 20}
@@ -335,10 +337,14 @@ func TestComputeScalarOutput(t *testing.T) {
 		weightedInput3,
 	}
 
-	result := neuron.computeScalarOutput(weightedInputs)
-
+	result, err := neuron.computeScalarOutput(weightedInputs)
+	assert.True(t, err == nil)
 	assert.Equals(t, result, float64(120))
 
+	weightedInput1.inputs = inputs_2
+	_, err = neuron.computeScalarOutput(weightedInputs)
+	assert.True(t, errors.Is(err, ErrVectorLength))
+
 }
 
 func TestNeuronShutdown(t *testing.T) {
@@ -359,8 +365,8 @@ func TestNeuronShutdown(t *testing.T) {
 	sensor.ConnectOutbound(neuron)
 	neuron.ConnectInboundWeighted(sensor, []float64{20, 20})
 
-	go sensor.Run()
-	go neuron.Run()
+	go sensor.Run(context.Background())
+	go neuron.Run(context.Background())
 
 	sensor.Shutdown()
 	neuron.Shutdown()
@@ -412,3 +418,35 @@ func TestRecurrentOutboundConnections(t *testing.T) {
 	assert.Equals(t, len(recurrentConnections), 1)
 
 }
+
+func TestNeuronRunContextCancel(t *testing.T) {
+
+	sensor := &Sensor{
+		NodeId:       NewSensorId("sensor", 0.0),
+		VectorLength: 2,
+	}
+	sensor.Init()
+
+	neuron := &Neuron{
+		ActivationFunction: EncodableSigmoid(),
+		NodeId:             NewNeuronId("neuron", 0.35),
+		Bias:               -10,
+	}
+	neuron.Init()
+
+	sensor.ConnectOutbound(neuron)
+	neuron.ConnectInboundWeighted(sensor, []float64{20, 20})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go neuron.Run(ctx)
+
+	cancel()
+	neuron.wait()
+
+	assert.True(t, neuron.DataChan == nil)
+	assert.True(t, neuron.Closing == nil)
+
+	// shutting down a neuron which has already stopped doesn't block
+	assert.True(t, errors.Is(neuron.Shutdown(), ErrNodeStopped))
+
+}
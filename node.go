@@ -1,5 +1,9 @@
 package neurgo
 
+import (
+	"errors"
+)
+
 type NodeType string
 
 const (
@@ -56,3 +60,18 @@ func NewCortexId(UUID string) *NodeId {
 		NodeType: CORTEX,
 	}
 }
+
+// ask the goroutine started by a node's Run to stop via its closing
+// channel, unless it has already stopped
+func shutdownNode(nodeId *NodeId, closing chan chan bool, stopped chan struct{}) error {
+	closingResponse := make(chan bool)
+	select {
+	case closing <- closingResponse:
+	case <-stopped:
+		return newNodeError(nodeId, ErrNodeStopped)
+	}
+	if response := <-closingResponse; response != true {
+		return newNodeError(nodeId, errors.New("unexpected response on closing channel"))
+	}
+	return nil
+}
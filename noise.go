@@ -0,0 +1,89 @@
+package neurgo
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Noise is stochastic regularization applied to a cortex while it is in
+// training mode (see cortex.Training), by both the channel based runtime
+// and the EvaluationPlan.  In inference mode it has no effect.
+//
+// The gradient based trainers switch the cortex into training mode
+// while they compute gradients, and back again afterwards, so only
+// Noise needs to be set to train with it.
+type Noise struct {
+
+	// probability that the output of a neuron is replaced with 0 on any
+	// one tick.  Outputs which are kept are divided by 1 - Dropout, so
+	// that their expected value is the same as in inference mode.
+	// Neurons which feed an actuator are never dropped.
+	Dropout float64
+
+	// standard deviation of the gaussian noise added to every sensor
+	// input
+	InputStdDev float64
+
+	// standard deviation of the gaussian noise added to the output of
+	// every neuron, after dropout
+	OutputStdDev float64
+
+	// source of all random numbers, or nil to seed one from the clock.
+	// Shared by every goroutine of the cortex, and by its copies.
+	Rand *rand.Rand
+
+	mutex sync.Mutex
+}
+
+// noise returns the Noise to apply to the cortex, or nil if there is
+// none or the cortex is in inference mode
+func (cortex *Cortex) noise() *Noise {
+	if cortex == nil || !cortex.Training {
+		return nil
+	}
+	return cortex.Noise
+}
+
+// perturbInput returns a copy of the input of a sensor with noise added
+func (noise *Noise) perturbInput(input []float64) []float64 {
+	if noise.InputStdDev <= 0 {
+		return input
+	}
+	noise.mutex.Lock()
+	defer noise.mutex.Unlock()
+	perturbed := make([]float64, len(input))
+	for i, value := range input {
+		perturbed[i] = value + noise.InputStdDev*noise.rng().NormFloat64()
+	}
+	return perturbed
+}
+
+// perturbOutput applies dropout and noise to the output of neuron.  Also
+// returns the factor the output was scaled by, which is 0 if it was
+// dropped.
+func (noise *Noise) perturbOutput(neuron *Neuron, output float64) (float64, float64) {
+	noise.mutex.Lock()
+	defer noise.mutex.Unlock()
+	scale := float64(1)
+	if noise.Dropout > 0 && !neuron.feedsActuator() {
+		if noise.rng().Float64() < noise.Dropout {
+			scale = 0
+		} else {
+			scale = 1 / (1 - noise.Dropout)
+		}
+	}
+	output *= scale
+	if noise.OutputStdDev > 0 {
+		output += noise.OutputStdDev * noise.rng().NormFloat64()
+	}
+	return output, scale
+}
+
+// must be called with the mutex held
+func (noise *Noise) rng() *rand.Rand {
+	if noise.Rand == nil {
+		noise.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return noise.Rand
+}
@@ -0,0 +1,154 @@
+package neurgo
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func noisyXnorCortex(training bool) *Cortex {
+	cortex := SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+	cortex.Training = training
+	cortex.Noise = &Noise{
+		Dropout:      0.5,
+		InputStdDev:  0.1,
+		OutputStdDev: 0.1,
+		Rand:         rand.New(rand.NewSource(1)),
+	}
+	return cortex
+}
+
+func TestNoiseInferenceMode(t *testing.T) {
+
+	expected := runEvaluationPlan(t, SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), xnorInputs())
+
+	cortex := noisyXnorCortex(false)
+	cortex.LinkNodesToCortex()
+	assertIdenticalOutputs(t, expected, runChannelRuntime(t, cortex, xnorInputs()))
+	assertIdenticalOutputs(t, expected, runEvaluationPlan(t, cortex, xnorInputs()))
+
+	// copies keep the mode and the noise
+	copied := cortex.Copy()
+	assert.False(t, copied.Training)
+	assert.True(t, copied.Noise == cortex.Noise)
+
+}
+
+func TestNoiseTrainingMode(t *testing.T) {
+
+	expected := runEvaluationPlan(t, SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), xnorInputs())
+
+	differs := func(actual [][][]float64) bool {
+		for tick := range expected {
+			if actual[tick][0][0] != expected[tick][0][0] {
+				return true
+			}
+		}
+		return false
+	}
+
+	cortex := noisyXnorCortex(true)
+	cortex.LinkNodesToCortex()
+	assert.True(t, differs(runChannelRuntime(t, cortex, xnorInputs())))
+	assert.True(t, differs(runEvaluationPlan(t, cortex, xnorInputs())))
+
+}
+
+func TestDropoutScaling(t *testing.T) {
+
+	cortex := SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+	cortex.Training = true
+	cortex.Noise = &Noise{Dropout: 0.5, Rand: rand.New(rand.NewSource(1))}
+
+	plan, err := cortex.Compile()
+	assert.True(t, err == nil)
+
+	dropped := 0
+	for i := 0; i < 100; i++ {
+		_, err := plan.Evaluate([][]float64{{1, 0}})
+		assert.True(t, err == nil)
+		for j, plannedNeuron := range plan.neurons {
+			scale := plan.scales[j]
+			if plannedNeuron.neuron.feedsActuator() {
+				assert.Equals(t, scale, 1.0)
+				continue
+			}
+			assert.True(t, scale == 0 || scale == 2)
+			if scale == 0 {
+				dropped += 1
+				assert.Equals(t, plan.outputs[j][0], 0.0)
+			}
+		}
+	}
+
+	// about half of the 200 hidden neuron outputs were dropped
+	assert.True(t, dropped > 70 && dropped < 130)
+
+}
+
+func TestGradientsWithNoise(t *testing.T) {
+
+	cortex := SeededXnorCortexUntrained(rand.New(rand.NewSource(3)))
+	cortex.Training = true
+	cortex.Noise = &Noise{Dropout: 0.3, InputStdDev: 0.1, OutputStdDev: 0.1}
+	samples := XnorTrainingSamples()
+
+	// the same seed gives the same noise on every evaluation, so the
+	// loss is a smooth function of the parameters
+	reseed := func() {
+		cortex.Noise.Rand = rand.New(rand.NewSource(7))
+	}
+	loss := func() float64 {
+		reseed()
+		return sumOfSquaresLoss(t, cortex, samples)
+	}
+
+	plan, err := cortex.Compile()
+	assert.True(t, err == nil)
+	sensorIndexes, actuatorIndexes, err := (*SampleMapping)(nil).resolve(cortex)
+	assert.True(t, err == nil)
+	gradients := NewGradients(cortex)
+	reseed()
+	_, err = plan.sumOfSquaresGradients(samples, sensorIndexes, actuatorIndexes, gradients)
+	assert.True(t, err == nil)
+
+	h := 1e-6
+	for _, neuron := range cortex.Neurons {
+		gradient := gradients[neuron.NodeId.UUID]
+		for i, inbound := range neuron.Inbound {
+			for j := range inbound.Weights {
+				original := inbound.Weights[j]
+				inbound.Weights[j] = original + h
+				lossPlus := loss()
+				inbound.Weights[j] = original - h
+				lossMinus := loss()
+				inbound.Weights[j] = original
+				numeric := (lossPlus - lossMinus) / (2 * h)
+				analytic := gradient.Weights[i][j]
+				scale := math.Max(1, math.Abs(analytic)+math.Abs(numeric))
+				assert.True(t, math.Abs(analytic-numeric)/scale < 1e-5)
+			}
+		}
+	}
+
+}
+
+func TestBackpropTrainerNoise(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	untrained := SeededXnorCortexUntrained(rand.New(rand.NewSource(2)))
+	untrained.Noise = &Noise{InputStdDev: 0.05, Rand: rand.New(rand.NewSource(1))}
+
+	trainer := NewBackpropTrainer()
+	trainer.TargetFitness = 10
+	trained, err := trainer.Fit(untrained, examples)
+	assert.True(t, err == nil)
+
+	// training mode is only switched on while computing gradients
+	assert.False(t, trained.Training)
+	fitness, err := trained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= 10)
+
+}
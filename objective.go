@@ -0,0 +1,271 @@
+package neurgo
+
+import (
+	"math"
+)
+
+// An Objective scores the outputs of a cortex against the outputs
+// expected by a set of training samples.  expected[n][j] is the vector
+// that sample n expects from actuator j, and actual[n][j] is the vector
+// that actuator actually produced.  The built in objectives score an
+// empty set of samples as 0.
+type Objective interface {
+	Score(expected, actual [][][]float64) float64
+
+	// Maximize is true if higher scores are better, as with Accuracy,
+	// and false if lower scores are better, as with the error measures.
+	Maximize() bool
+}
+
+// A DifferentiableObjective can also give the gradient of its score with
+// respect to each of the actual outputs, shaped like actual.  All of the
+// built in error measures are differentiable, but Accuracy is not.
+type DifferentiableObjective interface {
+	Objective
+	Gradient(expected, actual [][][]float64) [][][]float64
+}
+
+// the smallest probability passed to math.Log by the cross-entropy
+// objectives, so that a confidently wrong output costs a lot rather
+// than infinitely much
+const crossEntropyEpsilon = 1e-12
+
+// SSE is the sum of squared errors over every output of every sample
+type SSE struct{}
+
+func (SSE) Score(expected, actual [][][]float64) float64 {
+	return sumOverOutputs(expected, actual, func(e, a float64) float64 {
+		return (a - e) * (a - e)
+	})
+}
+
+func (SSE) Maximize() bool { return false }
+
+func (SSE) Gradient(expected, actual [][][]float64) [][][]float64 {
+	return gradientOverOutputs(expected, actual, func(e, a float64) float64 {
+		return 2 * (a - e)
+	})
+}
+
+// MSE is the mean squared error over every output of every sample
+type MSE struct{}
+
+func (MSE) Score(expected, actual [][][]float64) float64 {
+	return mean(SSE{}.Score(expected, actual), countOutputs(expected))
+}
+
+func (MSE) Maximize() bool { return false }
+
+func (MSE) Gradient(expected, actual [][][]float64) [][][]float64 {
+	scale := mean(1, countOutputs(expected))
+	return gradientOverOutputs(expected, actual, func(e, a float64) float64 {
+		return 2 * (a - e) * scale
+	})
+}
+
+// MAE is the mean absolute error over every output of every sample
+type MAE struct{}
+
+func (MAE) Score(expected, actual [][][]float64) float64 {
+	total := sumOverOutputs(expected, actual, func(e, a float64) float64 {
+		return math.Abs(a - e)
+	})
+	return mean(total, countOutputs(expected))
+}
+
+func (MAE) Maximize() bool { return false }
+
+func (MAE) Gradient(expected, actual [][][]float64) [][][]float64 {
+	scale := mean(1, countOutputs(expected))
+	return gradientOverOutputs(expected, actual, func(e, a float64) float64 {
+		return sign(a-e) * scale
+	})
+}
+
+// Huber is the mean Huber loss over every output of every sample, which
+// is quadratic for errors up to Delta and linear beyond, so that it is
+// less sensitive to outliers than MSE.  See
+// http://en.wikipedia.org/wiki/Huber_loss
+type Huber struct {
+	Delta float64
+}
+
+func (huber Huber) Score(expected, actual [][][]float64) float64 {
+	total := sumOverOutputs(expected, actual, func(e, a float64) float64 {
+		delta := math.Abs(a - e)
+		if delta <= huber.Delta {
+			return 0.5 * delta * delta
+		}
+		return huber.Delta * (delta - 0.5*huber.Delta)
+	})
+	return mean(total, countOutputs(expected))
+}
+
+func (Huber) Maximize() bool { return false }
+
+func (huber Huber) Gradient(expected, actual [][][]float64) [][][]float64 {
+	scale := mean(1, countOutputs(expected))
+	return gradientOverOutputs(expected, actual, func(e, a float64) float64 {
+		if math.Abs(a-e) <= huber.Delta {
+			return (a - e) * scale
+		}
+		return huber.Delta * sign(a-e) * scale
+	})
+}
+
+// BinaryCrossEntropy is the mean cross-entropy over every output of every
+// sample, treating each output as the probability that its expected
+// output is 1.  Expected outputs should be 0 or 1 and actual outputs in
+// (0, 1), as produced by a sigmoid neuron.
+type BinaryCrossEntropy struct{}
+
+func (BinaryCrossEntropy) Score(expected, actual [][][]float64) float64 {
+	total := sumOverOutputs(expected, actual, func(e, a float64) float64 {
+		a = Saturate(a, crossEntropyEpsilon, 1-crossEntropyEpsilon)
+		return -(e*math.Log(a) + (1-e)*math.Log(1-a))
+	})
+	return mean(total, countOutputs(expected))
+}
+
+func (BinaryCrossEntropy) Maximize() bool { return false }
+
+func (BinaryCrossEntropy) Gradient(expected, actual [][][]float64) [][][]float64 {
+	scale := mean(1, countOutputs(expected))
+	return gradientOverOutputs(expected, actual, func(e, a float64) float64 {
+		if a < crossEntropyEpsilon || a > 1-crossEntropyEpsilon {
+			return 0
+		}
+		return (-e/a + (1-e)/(1-a)) * scale
+	})
+}
+
+// CategoricalCrossEntropy is the mean cross-entropy of each actuator
+// output vector, treating the expected vector as a one-hot (or otherwise
+// normalized) distribution over classes and the actual vector as the
+// predicted probability of each class.
+type CategoricalCrossEntropy struct{}
+
+func (CategoricalCrossEntropy) Score(expected, actual [][][]float64) float64 {
+	total := sumOverOutputs(expected, actual, func(e, a float64) float64 {
+		a = Saturate(a, crossEntropyEpsilon, 1)
+		return -e * math.Log(a)
+	})
+	return mean(total, countVectors(expected))
+}
+
+func (CategoricalCrossEntropy) Maximize() bool { return false }
+
+func (CategoricalCrossEntropy) Gradient(expected, actual [][][]float64) [][][]float64 {
+	scale := mean(1, countVectors(expected))
+	return gradientOverOutputs(expected, actual, func(e, a float64) float64 {
+		if a < crossEntropyEpsilon || a > 1 {
+			return 0
+		}
+		return -e / a * scale
+	})
+}
+
+// Accuracy is the fraction of actuator output vectors which are
+// classified correctly.  An output vector of length 1 is a binary
+// classification, which is correct if the expected and actual values
+// fall on the same side of Threshold (eg 0.5 for sigmoid outputs, or 0
+// for tanh outputs).  A longer output vector is a one-hot
+// classification, which is correct if the largest actual value is in
+// the same position as the largest expected value.
+type Accuracy struct {
+	Threshold float64
+}
+
+func (accuracy Accuracy) Score(expected, actual [][][]float64) float64 {
+	correct := 0
+	for n, sample := range expected {
+		for j, expectedVector := range sample {
+			actualVector := actual[n][j]
+			if len(expectedVector) == 1 {
+				if (expectedVector[0] > accuracy.Threshold) == (actualVector[0] > accuracy.Threshold) {
+					correct += 1
+				}
+			} else if argmax(expectedVector) == argmax(actualVector) {
+				correct += 1
+			}
+		}
+	}
+	return mean(float64(correct), countVectors(expected))
+}
+
+func (Accuracy) Maximize() bool { return true }
+
+func sumOverOutputs(expected, actual [][][]float64, loss func(e, a float64) float64) float64 {
+	total := float64(0)
+	for n, sample := range expected {
+		for j, expectedVector := range sample {
+			for i, e := range expectedVector {
+				total += loss(e, actual[n][j][i])
+			}
+		}
+	}
+	return total
+}
+
+// the gradient of a loss summed over outputs, given its derivative with
+// respect to a single actual output
+func gradientOverOutputs(expected, actual [][][]float64, derivative func(e, a float64) float64) [][][]float64 {
+	gradient := make([][][]float64, len(actual))
+	for n, sample := range actual {
+		gradient[n] = make([][]float64, len(sample))
+		for j, actualVector := range sample {
+			gradient[n][j] = make([]float64, len(actualVector))
+			for i, e := range expected[n][j] {
+				gradient[n][j][i] = derivative(e, actualVector[i])
+			}
+		}
+	}
+	return gradient
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+// total divided by count, or 0 if there is nothing to count, so that the
+// mean objectives score an empty set of samples as 0 rather than NaN
+func mean(total float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+func countOutputs(expected [][][]float64) int {
+	count := 0
+	for _, sample := range expected {
+		for _, expectedVector := range sample {
+			count += len(expectedVector)
+		}
+	}
+	return count
+}
+
+func countVectors(expected [][][]float64) int {
+	count := 0
+	for _, sample := range expected {
+		count += len(sample)
+	}
+	return count
+}
+
+func argmax(vector []float64) int {
+	index := 0
+	for i, x := range vector {
+		if x > vector[index] {
+			index = i
+		}
+	}
+	return index
+}
@@ -0,0 +1,121 @@
+package neurgo
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"testing"
+)
+
+func TestObjectives(t *testing.T) {
+
+	// two samples, each with a single actuator of length 2
+	expected := [][][]float64{
+		{{1, 0}},
+		{{0, 1}},
+	}
+	actual := [][][]float64{
+		{{0.5, 0}},
+		{{0.5, 3}},
+	}
+
+	assert.Equals(t, SSE{}.Score(expected, actual), 4.5)
+	assert.Equals(t, MSE{}.Score(expected, actual), 4.5/4)
+	assert.Equals(t, MAE{}.Score(expected, actual), 3.0/4)
+
+	// 0.5 is quadratic, 2 is linear
+	huber := Huber{Delta: 1}.Score(expected, actual)
+	assert.Equals(t, huber, (0.125+0.125+1.5)/4)
+
+	// the largest actual output is in the right place for both samples
+	assert.Equals(t, Accuracy{}.Score(expected, actual), 1.0)
+	assert.True(t, Accuracy{}.Maximize())
+	assert.False(t, SSE{}.Maximize())
+
+}
+
+func TestCrossEntropyObjectives(t *testing.T) {
+
+	expected := [][][]float64{{{1}}, {{0}}}
+	actual := [][][]float64{{{0.9}}, {{0.2}}}
+	binary := BinaryCrossEntropy{}.Score(expected, actual)
+	assert.True(t, EqualsWithMaxDelta(binary, -(math.Log(0.9)+math.Log(0.8))/2, 1e-12))
+
+	expected = [][][]float64{{{0, 1, 0}}}
+	actual = [][][]float64{{{0.2, 0.7, 0.1}}}
+	categorical := CategoricalCrossEntropy{}.Score(expected, actual)
+	assert.True(t, EqualsWithMaxDelta(categorical, -math.Log(0.7), 1e-12))
+
+	// a confidently wrong output is finite
+	actual = [][][]float64{{{1, 0, 0}}}
+	categorical = CategoricalCrossEntropy{}.Score(expected, actual)
+	assert.False(t, math.IsInf(categorical, 0))
+
+}
+
+func TestObjectivesEmpty(t *testing.T) {
+
+	objectives := []Objective{
+		SSE{},
+		MSE{},
+		MAE{},
+		Huber{Delta: 1},
+		BinaryCrossEntropy{},
+		CategoricalCrossEntropy{},
+		Accuracy{Threshold: 0.5},
+	}
+	for _, objective := range objectives {
+		assert.Equals(t, objective.Score(nil, nil), 0.0)
+		assert.Equals(t, objective.Score([][][]float64{{}}, [][][]float64{{}}), 0.0)
+		if differentiable, ok := objective.(DifferentiableObjective); ok {
+			assert.Equals(t, len(differentiable.Gradient(nil, nil)), 0)
+		}
+	}
+
+}
+
+func TestCortexFitnessBounded(t *testing.T) {
+
+	// a cortex which fits the samples exactly has a large but finite
+	// fitness
+	cortex := XnorCortex()
+	samples := make([]*TrainingSample, 0)
+	for _, sample := range XnorTrainingSamples() {
+		outputs, err := cortex.Step(map[string][]float64{"sensor": sample.SampleInputs[0]})
+		assert.True(t, err == nil)
+		samples = append(samples, &TrainingSample{
+			SampleInputs:    sample.SampleInputs,
+			ExpectedOutputs: [][]float64{outputs["actuator"]},
+		})
+	}
+
+	fitness, err := cortex.Fitness(samples)
+	assert.True(t, err == nil)
+	assert.False(t, math.IsInf(fitness, 0))
+	assert.Equals(t, fitness, SafeScalarInverse(0))
+
+}
+
+func TestCortexEvaluate(t *testing.T) {
+
+	cortex := XnorCortex()
+	examples := XnorTrainingSamples()
+
+	sse, err := cortex.Evaluate(examples, SSE{})
+	assert.True(t, err == nil)
+	fitness, err := cortex.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.Equals(t, fitness, 1/sse)
+
+	accuracy, err := cortex.Evaluate(examples, Accuracy{Threshold: 0.5})
+	assert.True(t, err == nil)
+	assert.Equals(t, accuracy, 1.0)
+
+	untrainedAccuracy, err := XnorCortexUntrained().Evaluate(examples, Accuracy{Threshold: 0.5})
+	assert.True(t, err == nil)
+	assert.True(t, untrainedAccuracy < 1.0)
+
+	objectiveFitness, err := ObjectiveFitness(examples, nil, SSE{})(cortex)
+	assert.True(t, err == nil)
+	assert.Equals(t, objectiveFitness, fitness)
+
+}
@@ -0,0 +1,344 @@
+package neurgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// An Optimizer updates the weights and biases of a cortex given the
+// gradients of a loss with respect to them, as computed by the gradient
+// based trainers.  Optimizers which keep per-parameter state, such as
+// momentum, store it as Gradients: keyed by neuron UUID, and indexed by
+// inbound position and weight just like the gradients themselves.  The
+// state of a neuron is created the first time the neuron is seen, and
+// reset if its inbound connections change shape, so an optimizer can
+// carry on across topology changes.
+//
+// Optimizers can be saved as JSON, and restored to resume training,
+// with EncodableOptimizer.
+type Optimizer interface {
+	Step(cortex *Cortex, gradients Gradients)
+}
+
+// SGD is plain stochastic gradient descent
+type SGD struct {
+	LearningRate float64
+}
+
+func NewSGD(learningRate float64) *SGD {
+	return &SGD{LearningRate: learningRate}
+}
+
+func (optimizer *SGD) Step(cortex *Cortex, gradients Gradients) {
+	eachParameter(cortex, gradients, nil, func(value *float64, gradient float64, state []*float64) {
+		*value -= optimizer.LearningRate * gradient
+	})
+}
+
+// Momentum is gradient descent with momentum, which accumulates a
+// velocity in directions the gradient keeps pointing in.  With Nesterov
+// set, the gradient is applied as if it had been evaluated after the
+// momentum step (Sutskever et al's formulation of Nesterov accelerated
+// gradient).
+type Momentum struct {
+	LearningRate float64
+	Momentum     float64
+	Nesterov     bool
+	Velocity     Gradients
+}
+
+func NewMomentum(learningRate, momentum float64) *Momentum {
+	return &Momentum{
+		LearningRate: learningRate,
+		Momentum:     momentum,
+		Velocity:     make(Gradients),
+	}
+}
+
+func (optimizer *Momentum) Step(cortex *Cortex, gradients Gradients) {
+	if optimizer.Velocity == nil {
+		optimizer.Velocity = make(Gradients)
+	}
+	states := []Gradients{optimizer.Velocity}
+	eachParameter(cortex, gradients, states, func(value *float64, gradient float64, state []*float64) {
+		velocity := state[0]
+		*velocity = optimizer.Momentum*(*velocity) - optimizer.LearningRate*gradient
+		if optimizer.Nesterov {
+			*value += optimizer.Momentum*(*velocity) - optimizer.LearningRate*gradient
+		} else {
+			*value += *velocity
+		}
+	})
+}
+
+// RMSProp divides the learning rate of each parameter by a running
+// average of the magnitude of its recent gradients
+type RMSProp struct {
+	LearningRate float64
+
+	// how much of the running average is kept at each step
+	Decay   float64
+	Epsilon float64
+
+	MeanSquare Gradients
+}
+
+func NewRMSProp(learningRate float64) *RMSProp {
+	return &RMSProp{
+		LearningRate: learningRate,
+		Decay:        0.9,
+		Epsilon:      1e-8,
+		MeanSquare:   make(Gradients),
+	}
+}
+
+func (optimizer *RMSProp) Step(cortex *Cortex, gradients Gradients) {
+	if optimizer.MeanSquare == nil {
+		optimizer.MeanSquare = make(Gradients)
+	}
+	states := []Gradients{optimizer.MeanSquare}
+	eachParameter(cortex, gradients, states, func(value *float64, gradient float64, state []*float64) {
+		meanSquare := state[0]
+		*meanSquare = optimizer.Decay*(*meanSquare) + (1-optimizer.Decay)*gradient*gradient
+		*value -= optimizer.LearningRate * gradient / (math.Sqrt(*meanSquare) + optimizer.Epsilon)
+	})
+}
+
+// Adagrad divides the learning rate of each parameter by the root of the
+// sum of all of its squared gradients so far
+type Adagrad struct {
+	LearningRate float64
+	Epsilon      float64
+	SumSquares   Gradients
+}
+
+func NewAdagrad(learningRate float64) *Adagrad {
+	return &Adagrad{
+		LearningRate: learningRate,
+		Epsilon:      1e-8,
+		SumSquares:   make(Gradients),
+	}
+}
+
+func (optimizer *Adagrad) Step(cortex *Cortex, gradients Gradients) {
+	if optimizer.SumSquares == nil {
+		optimizer.SumSquares = make(Gradients)
+	}
+	states := []Gradients{optimizer.SumSquares}
+	eachParameter(cortex, gradients, states, func(value *float64, gradient float64, state []*float64) {
+		sumSquares := state[0]
+		*sumSquares += gradient * gradient
+		*value -= optimizer.LearningRate * gradient / (math.Sqrt(*sumSquares) + optimizer.Epsilon)
+	})
+}
+
+// Adam keeps running averages of both the gradients and their squares,
+// corrected for their bias towards 0 early on.  See Kingma and Ba, "Adam:
+// A Method for Stochastic Optimization".
+type Adam struct {
+	LearningRate float64
+	Beta1        float64
+	Beta2        float64
+	Epsilon      float64
+
+	// number of steps taken so far
+	Steps int
+
+	FirstMoment  Gradients
+	SecondMoment Gradients
+}
+
+func NewAdam(learningRate float64) *Adam {
+	return &Adam{
+		LearningRate: learningRate,
+		Beta1:        0.9,
+		Beta2:        0.999,
+		Epsilon:      1e-8,
+		FirstMoment:  make(Gradients),
+		SecondMoment: make(Gradients),
+	}
+}
+
+func (optimizer *Adam) Step(cortex *Cortex, gradients Gradients) {
+	if optimizer.FirstMoment == nil || optimizer.SecondMoment == nil {
+		optimizer.FirstMoment = make(Gradients)
+		optimizer.SecondMoment = make(Gradients)
+	}
+	optimizer.Steps += 1
+	correction1 := 1 - math.Pow(optimizer.Beta1, float64(optimizer.Steps))
+	correction2 := 1 - math.Pow(optimizer.Beta2, float64(optimizer.Steps))
+	states := []Gradients{optimizer.FirstMoment, optimizer.SecondMoment}
+	eachParameter(cortex, gradients, states, func(value *float64, gradient float64, state []*float64) {
+		first, second := state[0], state[1]
+		*first = optimizer.Beta1*(*first) + (1-optimizer.Beta1)*gradient
+		*second = optimizer.Beta2*(*second) + (1-optimizer.Beta2)*gradient*gradient
+		corrected := *first / correction1
+		*value -= optimizer.LearningRate * corrected / (math.Sqrt(*second/correction2) + optimizer.Epsilon)
+	})
+}
+
+// withoutState returns a copy of optimizer with the same hyperparameters
+// but none of its per-parameter state, as if it had just been created.
+// A nil optimizer stays nil.
+func withoutState(optimizer Optimizer) (Optimizer, error) {
+	switch optimizer := optimizer.(type) {
+	case nil:
+		return nil, nil
+	case *SGD:
+		copied := *optimizer
+		return &copied, nil
+	case *Momentum:
+		copied := *optimizer
+		copied.Velocity = make(Gradients)
+		return &copied, nil
+	case *RMSProp:
+		copied := *optimizer
+		copied.MeanSquare = make(Gradients)
+		return &copied, nil
+	case *Adagrad:
+		copied := *optimizer
+		copied.SumSquares = make(Gradients)
+		return &copied, nil
+	case *Adam:
+		copied := *optimizer
+		copied.Steps = 0
+		copied.FirstMoment = make(Gradients)
+		copied.SecondMoment = make(Gradients)
+		return &copied, nil
+	default:
+		return nil, fmt.Errorf("cannot copy optimizer of type %T", optimizer)
+	}
+}
+
+// EncodableOptimizer wraps an Optimizer so that it can be saved as JSON,
+// hyperparameters and state included, along with which kind of
+// optimizer it is.  To save it alongside the cortex it is training,
+// marshal both in the same struct, eg
+//
+//	struct {
+//		Cortex    *Cortex
+//		Optimizer *EncodableOptimizer
+//	}
+//
+// and call LinkNodesToCortex on the cortex after unmarshalling it.
+type EncodableOptimizer struct {
+	Optimizer Optimizer
+}
+
+func (encodable *EncodableOptimizer) MarshalJSON() ([]byte, error) {
+
+	var name string
+	switch encodable.Optimizer.(type) {
+	case *SGD:
+		name = "sgd"
+	case *Momentum:
+		name = "momentum"
+	case *RMSProp:
+		name = "rmsprop"
+	case *Adagrad:
+		name = "adagrad"
+	case *Adam:
+		name = "adam"
+	default:
+		return nil, fmt.Errorf("cannot encode optimizer of type %T", encodable.Optimizer)
+	}
+
+	return json.Marshal(
+		struct {
+			Name  string
+			State Optimizer
+		}{
+			Name:  name,
+			State: encodable.Optimizer,
+		})
+
+}
+
+func (encodable *EncodableOptimizer) UnmarshalJSON(bytes []byte) error {
+
+	encoded := struct {
+		Name  string
+		State json.RawMessage
+	}{}
+	if err := json.Unmarshal(bytes, &encoded); err != nil {
+		return err
+	}
+
+	switch encoded.Name {
+	case "sgd":
+		encodable.Optimizer = &SGD{}
+	case "momentum":
+		encodable.Optimizer = &Momentum{}
+	case "rmsprop":
+		encodable.Optimizer = &RMSProp{}
+	case "adagrad":
+		encodable.Optimizer = &Adagrad{}
+	case "adam":
+		encodable.Optimizer = &Adam{}
+	default:
+		return fmt.Errorf("unknown optimizer: %v", encoded.Name)
+	}
+
+	return json.Unmarshal(encoded.State, encodable.Optimizer)
+
+}
+
+// call update on every weight and bias of cortex along with its
+// gradient, and its entry in each of states
+func eachParameter(cortex *Cortex, gradients Gradients, states []Gradients, update func(value *float64, gradient float64, state []*float64)) {
+
+	slots := make([]*float64, len(states))
+	neuronStates := make([]*NeuronGradient, len(states))
+
+	for _, neuron := range cortex.Neurons {
+
+		gradient := gradients[neuron.NodeId.UUID]
+		for i, state := range states {
+			neuronStates[i] = state.forNeuron(neuron)
+		}
+
+		for i, neuronState := range neuronStates {
+			slots[i] = &neuronState.Bias
+		}
+		update(&neuron.Bias, gradient.Bias, slots)
+
+		for i, inbound := range neuron.Inbound {
+			for j := range inbound.Weights {
+				for k, neuronState := range neuronStates {
+					slots[k] = &neuronState.Weights[i][j]
+				}
+				update(&inbound.Weights[j], gradient.Weights[i][j], slots)
+			}
+		}
+
+	}
+
+}
+
+// forNeuron returns the gradient of neuron, creating a zeroed one if
+// there isn't one yet or if it doesn't match the neuron's inbound
+// connections.
+func (gradients Gradients) forNeuron(neuron *Neuron) *NeuronGradient {
+
+	gradient, ok := gradients[neuron.NodeId.UUID]
+	if ok && len(gradient.Weights) == len(neuron.Inbound) {
+		for i, inbound := range neuron.Inbound {
+			if len(gradient.Weights[i]) != len(inbound.Weights) {
+				ok = false
+			}
+		}
+		if ok {
+			return gradient
+		}
+	}
+
+	weights := make([][]float64, len(neuron.Inbound))
+	for i, inbound := range neuron.Inbound {
+		weights[i] = make([]float64, len(inbound.Weights))
+	}
+	gradient = &NeuronGradient{Weights: weights}
+	gradients[neuron.NodeId.UUID] = gradient
+	return gradient
+
+}
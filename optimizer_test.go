@@ -0,0 +1,180 @@
+package neurgo
+
+import (
+	"encoding/json"
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// a cortex with a single neuron, whose bias is the only parameter with
+// a non-zero gradient
+func singleParameterStep(optimizer Optimizer, cortex *Cortex, gradient float64) float64 {
+	gradients := NewGradients(cortex)
+	gradients[cortex.Neurons[0].NodeId.UUID].Bias = gradient
+	optimizer.Step(cortex, gradients)
+	return cortex.Neurons[0].Bias
+}
+
+func TestOptimizerSteps(t *testing.T) {
+
+	cortex := BasicCortex()
+	cortex.Neurons[0].Bias = 0
+
+	// sgd moves against the gradient
+	assert.Equals(t, singleParameterStep(NewSGD(0.1), cortex, 2), -0.2)
+
+	// momentum accumulates
+	cortex.Neurons[0].Bias = 0
+	momentum := NewMomentum(0.1, 0.5)
+	assert.Equals(t, singleParameterStep(momentum, cortex, 1), -0.1)
+	assert.True(t, EqualsWithMaxDelta(singleParameterStep(momentum, cortex, 1), -0.25, 1e-12))
+
+	// nesterov looks ahead by one momentum step
+	cortex.Neurons[0].Bias = 0
+	nesterov := NewMomentum(0.1, 0.5)
+	nesterov.Nesterov = true
+	assert.True(t, EqualsWithMaxDelta(singleParameterStep(nesterov, cortex, 1), -0.15, 1e-12))
+
+	// adam's first step is the learning rate, whatever the gradient
+	cortex.Neurons[0].Bias = 0
+	adam := NewAdam(0.01)
+	assert.True(t, EqualsWithMaxDelta(singleParameterStep(adam, cortex, 1000), -0.01, 1e-9))
+	assert.Equals(t, adam.Steps, 1)
+
+	// so is adagrad's
+	cortex.Neurons[0].Bias = 0
+	adagrad := NewAdagrad(0.01)
+	assert.True(t, EqualsWithMaxDelta(singleParameterStep(adagrad, cortex, 1000), -0.01, 1e-9))
+
+	// rmsprop's is larger, since the mean square starts at 0
+	cortex.Neurons[0].Bias = 0
+	rmsprop := NewRMSProp(0.01)
+	assert.True(t, EqualsWithMaxDelta(singleParameterStep(rmsprop, cortex, 1000), -0.01/math.Sqrt(0.1), 1e-9))
+
+}
+
+func TestOptimizersTrainXnor(t *testing.T) {
+
+	nesterov := NewMomentum(0.5, 0.9)
+	nesterov.Nesterov = true
+
+	optimizers := []Optimizer{
+		NewSGD(0.5),
+		NewMomentum(0.5, 0.9),
+		nesterov,
+		NewRMSProp(0.05),
+		NewAdagrad(0.5),
+		NewAdam(0.05),
+	}
+
+	examples := XnorTrainingSamples()
+	for _, optimizer := range optimizers {
+		trainer := NewBackpropTrainer()
+		trainer.Optimizer = optimizer
+		trained, err := trainer.Fit(SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+		assert.True(t, err == nil)
+		fitness, err := trained.Fitness(examples)
+		assert.True(t, err == nil)
+		if fitness < trainer.TargetFitness {
+			t.Errorf("%T only reached fitness %v", optimizer, fitness)
+		}
+	}
+
+}
+
+func TestOptimizerStateReshaped(t *testing.T) {
+
+	cortex := XnorCortex()
+	state := make(Gradients)
+	neuron := cortex.Neurons[0]
+
+	gradient := state.forNeuron(neuron)
+	gradient.Bias = 1
+	assert.True(t, state.forNeuron(neuron) == gradient)
+
+	// a new inbound connection invalidates the state
+	neuron.Inbound = append(neuron.Inbound, &InboundConnection{
+		NodeId:  cortex.Neurons[1].NodeId,
+		Weights: []float64{1},
+	})
+	reshaped := state.forNeuron(neuron)
+	assert.Equals(t, reshaped.Bias, 0.0)
+	assert.Equals(t, len(reshaped.Weights), 2)
+
+}
+
+func TestEncodableOptimizerResume(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+
+	trainer := NewBackpropTrainer()
+	trainer.TargetFitness = 0
+	trainer.MaxEpochs = 20
+
+	// train for 40 epochs in one go
+	trainer.Optimizer = NewAdam(0.05)
+	twice, err := trainer.Fit(SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, err == nil)
+	twice, err = trainer.Fit(twice, examples)
+	assert.True(t, err == nil)
+
+	// train for 20 epochs, save the optimizer, then train for another 20
+	trainer.Optimizer = NewAdam(0.05)
+	once, err := trainer.Fit(SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, err == nil)
+
+	jsonBytes, err := json.Marshal(&EncodableOptimizer{trainer.Optimizer})
+	assert.True(t, err == nil)
+	decoded := &EncodableOptimizer{}
+	assert.True(t, json.Unmarshal(jsonBytes, decoded) == nil)
+	adam, ok := decoded.Optimizer.(*Adam)
+	assert.True(t, ok)
+	assert.Equals(t, adam.Steps, 20*len(examples))
+
+	trainer.Optimizer = adam
+	resumed, err := trainer.Fit(once, examples)
+	assert.True(t, err == nil)
+
+	expected := twice.Parameters()
+	for i, parameter := range resumed.Parameters() {
+		assert.Equals(t, parameter, expected[i])
+	}
+
+	err = json.Unmarshal([]byte(`{"Name": "nonsense"}`), decoded)
+	assert.True(t, err != nil)
+
+}
+
+func TestOptimizerWithoutState(t *testing.T) {
+
+	constructors := []func() Optimizer{
+		func() Optimizer { return NewSGD(0.1) },
+		func() Optimizer { return NewMomentum(0.1, 0.5) },
+		func() Optimizer { return NewRMSProp(0.1) },
+		func() Optimizer { return NewAdagrad(0.1) },
+		func() Optimizer { return NewAdam(0.1) },
+	}
+
+	for _, newOptimizer := range constructors {
+
+		used := newOptimizer()
+		for i := 0; i < 3; i++ {
+			singleParameterStep(used, BasicCortex(), 1)
+		}
+
+		// a copy without state steps exactly like a new optimizer
+		fresh, err := withoutState(used)
+		assert.True(t, err == nil)
+		assert.True(t, fresh != used)
+		expected := singleParameterStep(newOptimizer(), BasicCortex(), 1)
+		assert.Equals(t, singleParameterStep(fresh, BasicCortex(), 1), expected)
+
+	}
+
+	fresh, err := withoutState(nil)
+	assert.True(t, err == nil)
+	assert.True(t, fresh == nil)
+
+}
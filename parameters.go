@@ -0,0 +1,194 @@
+package neurgo
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// A Parameter identifies one trainable value in a cortex: either the
+// bias of a neuron, or one of the weights of one of its inbound
+// connections.
+type Parameter struct {
+	NeuronUUID string
+
+	// position of the inbound connection in neuron.Inbound, or -1 if
+	// this is the bias
+	Inbound int
+
+	// UUID of the sender of the inbound connection, or empty if this is
+	// the bias
+	SenderUUID string
+
+	// index into the Weights of the inbound connection
+	Weight int
+}
+
+func (parameter Parameter) IsBias() bool {
+	return parameter.Inbound == -1
+}
+
+func (parameter Parameter) String() string {
+	if parameter.IsBias() {
+		return fmt.Sprintf("%v bias", parameter.NeuronUUID)
+	}
+	return fmt.Sprintf("%v inbound %d (%v) weight %d",
+		parameter.NeuronUUID,
+		parameter.Inbound,
+		parameter.SenderUUID,
+		parameter.Weight)
+}
+
+// A ParameterLayout says which Parameter each element of the vector
+// returned by cortex.Parameters corresponds to.  Neurons are laid out in
+// order of UUID, each one as its bias followed by the weights of its
+// inbound connections in order, so the layout depends only on the
+// topology of the cortex and not on the order of cortex.Neurons.
+type ParameterLayout []Parameter
+
+// ParameterLayout describes the vector returned by Parameters
+func (cortex *Cortex) ParameterLayout() ParameterLayout {
+	layout := make(ParameterLayout, 0)
+	for _, neuron := range cortex.neuronsByUUID() {
+		uuid := neuron.NodeId.UUID
+		layout = append(layout, Parameter{NeuronUUID: uuid, Inbound: -1})
+		for i, inbound := range neuron.Inbound {
+			for j := range inbound.Weights {
+				layout = append(layout, Parameter{
+					NeuronUUID: uuid,
+					Inbound:    i,
+					SenderUUID: inbound.NodeId.UUID,
+					Weight:     j,
+				})
+			}
+		}
+	}
+	return layout
+}
+
+// Parameters returns the biases and weights of every neuron as a single
+// vector, laid out as described by ParameterLayout.
+func (cortex *Cortex) Parameters() []float64 {
+	pointers := cortex.parameterPointers()
+	parameters := make([]float64, len(pointers))
+	for i, pointer := range pointers {
+		parameters[i] = *pointer
+	}
+	return parameters
+}
+
+// SetParameters is the inverse of Parameters.  Returns an error wrapping
+// ErrParameterCount, leaving the cortex untouched, if parameters is not
+// the same length as ParameterLayout.
+func (cortex *Cortex) SetParameters(parameters []float64) error {
+	pointers := cortex.parameterPointers()
+	if len(parameters) != len(pointers) {
+		return fmt.Errorf("%w: expected %d, got %d",
+			ErrParameterCount,
+			len(pointers),
+			len(parameters))
+	}
+	for i, pointer := range pointers {
+		*pointer = parameters[i]
+	}
+	return nil
+}
+
+// pointers to the values of the parameters, in ParameterLayout order
+func (cortex *Cortex) parameterPointers() []*float64 {
+	pointers := make([]*float64, 0)
+	for _, neuron := range cortex.neuronsByUUID() {
+		pointers = append(pointers, &neuron.Bias)
+		for _, inbound := range neuron.Inbound {
+			for j := range inbound.Weights {
+				pointers = append(pointers, &inbound.Weights[j])
+			}
+		}
+	}
+	return pointers
+}
+
+func (cortex *Cortex) neuronsByUUID() []*Neuron {
+	neurons := make([]*Neuron, len(cortex.Neurons))
+	copy(neurons, cortex.Neurons)
+	sort.Sort(neuronsByUUID(neurons))
+	return neurons
+}
+
+type neuronsByUUID []*Neuron
+
+func (n neuronsByUUID) Len() int      { return len(n) }
+func (n neuronsByUUID) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+func (n neuronsByUUID) Less(i, j int) bool {
+	return n[i].NodeId.UUID < n[j].NodeId.UUID
+}
+
+// parameterEvaluator scores candidate parameter vectors for a cortex
+// concurrently, giving each goroutine its own copy of the cortex.
+type parameterEvaluator struct {
+	copies  []*Cortex
+	fitness FitnessFunc
+}
+
+// concurrency of 0 means runtime.NumCPU()
+func newParameterEvaluator(cortex *Cortex, fitness FitnessFunc, concurrency int) *parameterEvaluator {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	evaluator := &parameterEvaluator{
+		copies:  make([]*Cortex, concurrency),
+		fitness: fitness,
+	}
+	for i := range evaluator.copies {
+		evaluator.copies[i] = cortex.Copy()
+	}
+	return evaluator
+}
+
+// evaluate returns the fitness of each of the candidates.  A NaN fitness
+// is replaced with -Inf, so that candidates can always be compared.
+func (evaluator *parameterEvaluator) evaluate(candidates [][]float64) ([]float64, error) {
+
+	fitnesses := make([]float64, len(candidates))
+	errs := make([]error, len(evaluator.copies))
+	indexes := make(chan int)
+	wg := &sync.WaitGroup{}
+
+	for worker, cortex := range evaluator.copies {
+		wg.Add(1)
+		go func(worker int, cortex *Cortex) {
+			defer wg.Done()
+			for i := range indexes {
+				if errs[worker] != nil {
+					continue
+				}
+				if err := cortex.SetParameters(candidates[i]); err != nil {
+					errs[worker] = err
+					continue
+				}
+				fitness, err := evaluator.fitness(cortex)
+				if math.IsNaN(fitness) {
+					fitness = math.Inf(-1)
+				}
+				fitnesses[i] = fitness
+				errs[worker] = err
+			}
+		}(worker, cortex)
+	}
+
+	for i := range candidates {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fitnesses, nil
+
+}
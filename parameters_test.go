@@ -0,0 +1,89 @@
+package neurgo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"testing"
+)
+
+func TestParameterLayout(t *testing.T) {
+
+	cortex := XnorCortex()
+
+	layout := cortex.ParameterLayout()
+	parameters := cortex.Parameters()
+
+	// 3 biases, 2 + 2 sensor weights and 2 hidden neuron weights
+	assert.Equals(t, len(layout), 9)
+	assert.Equals(t, len(parameters), 9)
+
+	assert.Equals(t, layout[0].NeuronUUID, "hidden-neuron1")
+	assert.True(t, layout[0].IsBias())
+	assert.Equals(t, parameters[0], -30.0)
+
+	assert.Equals(t, layout[1].NeuronUUID, "hidden-neuron1")
+	assert.Equals(t, layout[1].SenderUUID, "sensor")
+	assert.Equals(t, layout[1].Inbound, 0)
+	assert.Equals(t, layout[1].Weight, 0)
+	assert.Equals(t, parameters[1], 20.0)
+
+	assert.Equals(t, layout[7].NeuronUUID, "output-neuron")
+	assert.Equals(t, layout[7].SenderUUID, "hidden-neuron1")
+	assert.Equals(t, layout[8].SenderUUID, "hidden-neuron2")
+
+	// the layout doesn't depend on the order of the neurons
+	reordered := XnorCortex()
+	neurons := reordered.Neurons
+	reordered.Neurons = []*Neuron{neurons[2], neurons[0], neurons[1]}
+	assert.Equals(t, len(reordered.ParameterLayout()), len(layout))
+	for i, parameter := range reordered.ParameterLayout() {
+		assert.Equals(t, parameter, layout[i])
+	}
+
+}
+
+func TestSetParameters(t *testing.T) {
+
+	cortex := XnorCortexUntrained()
+	trained := XnorCortex()
+
+	err := cortex.SetParameters(trained.Parameters())
+	assert.True(t, err == nil)
+
+	fitness, err := cortex.Fitness(XnorTrainingSamples())
+	assert.True(t, err == nil)
+	trainedFitness, err := trained.Fitness(XnorTrainingSamples())
+	assert.True(t, err == nil)
+	assert.Equals(t, fitness, trainedFitness)
+
+	err = cortex.SetParameters([]float64{1, 2, 3})
+	assert.True(t, errors.Is(err, ErrParameterCount))
+	assert.Equals(t, cortex.Neurons[0].Bias, -30.0)
+
+}
+
+func TestParametersJSONRoundTrip(t *testing.T) {
+
+	cortex := XnorCortexUntrained()
+	parameters := cortex.Parameters()
+
+	jsonBytes, err := json.Marshal(cortex)
+	assert.True(t, err == nil)
+
+	loaded, err := NewCortexFromJSONSBytes(jsonBytes)
+	assert.True(t, err == nil)
+
+	loadedParameters := loaded.Parameters()
+	assert.Equals(t, len(loadedParameters), len(parameters))
+	for i := range parameters {
+		assert.True(t, loadedParameters[i] == parameters[i])
+	}
+
+	// setting the parameters that were read back changes nothing
+	assert.True(t, loaded.SetParameters(loadedParameters) == nil)
+	reencoded, err := json.Marshal(loaded)
+	assert.True(t, err == nil)
+	assert.Equals(t, string(reencoded), string(jsonBytes))
+
+}
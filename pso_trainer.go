@@ -0,0 +1,388 @@
+package neurgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/couchbaselabs/logg"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// PSOTrainer tunes the weights and biases of a cortex with a fixed
+// topology using Particle Swarm Optimization (see
+// http://en.wikipedia.org/wiki/Particle_swarm_optimization).  Each
+// particle is a full parameter vector for the cortex, as returned by
+// cortex.Parameters, which is pulled towards both the best position it
+// has found itself and the best position found by the whole swarm.
+type PSOTrainer struct {
+
+	// number of particles in the swarm
+	NumParticles int
+
+	// how much of its velocity a particle keeps from one iteration to
+	// the next
+	Inertia float64
+
+	// how strongly a particle is pulled towards its own best position
+	Cognitive float64
+
+	// how strongly a particle is pulled towards the swarm's best position
+	Social float64
+
+	// each component of a particle's velocity is clamped to
+	// [-MaxVelocity, MaxVelocity]
+	MaxVelocity float64
+
+	// particles other than the first start at the parameters of the
+	// cortex plus uniform noise in [-InitialSpread, InitialSpread].  The
+	// first particle starts at the parameters of the cortex.
+	InitialSpread float64
+
+	// maximum number of times the swarm moves
+	MaxIterations int
+
+	// stop training as soon as a particle reaches this fitness, or 0 to
+	// always run for MaxIterations
+	TargetFitness float64
+
+	// function to maximize, or nil to use the fitness of the cortex on
+	// the training samples.  Called concurrently on different cortexes.
+	Fitness FitnessFunc
+
+	// number of particles evaluated at once, or 0 for runtime.NumCPU()
+	Concurrency int
+
+	// source of all random numbers, or nil to seed one from the clock
+	Rand *rand.Rand
+
+	// which sensors and actuators the samples correspond to, or nil
+	// to map them by index.  See SampleMapping.
+	Mapping *SampleMapping
+
+	// the search begun by Start, which StepGeneration carries on
+	search *swarmSearch
+}
+
+// NewPSOTrainer returns a trainer using the constriction coefficients
+// recommended by Clerc and Kennedy.
+func NewPSOTrainer() *PSOTrainer {
+	return &PSOTrainer{
+		NumParticles:  30,
+		Inertia:       0.729,
+		Cognitive:     1.49445,
+		Social:        1.49445,
+		MaxVelocity:   1.0,
+		InitialSpread: 1.0,
+		MaxIterations: 1000,
+		TargetFitness: 100,
+	}
+}
+
+// Train returns a trained copy of cortex, or nil if the cortex could
+// not be trained.  Use Fit to find out why.
+func (trainer *PSOTrainer) Train(cortex *Cortex, examples []*TrainingSample) *Cortex {
+	trained, err := trainer.Fit(cortex, examples)
+	if err != nil {
+		logg.LogWarn("PSOTrainer unable to train cortex: %v", err)
+		return nil
+	}
+	return trained
+}
+
+// Fit is the same as Train, but returns an error rather than nil.  The
+// examples are ignored if the trainer has its own Fitness function.
+// Returns ErrPopulationSize if there are no particles, or
+// ErrParameterCount if the cortex has no weights or biases to tune.
+func (trainer *PSOTrainer) Fit(cortex *Cortex, examples []*TrainingSample) (*Cortex, error) {
+
+	if err := trainer.validate(cortex); err != nil {
+		return nil, err
+	}
+
+	search, err := trainer.newSearch(cortex, examples)
+	if err != nil {
+		return nil, err
+	}
+
+	for iteration := 0; iteration < trainer.MaxIterations; iteration++ {
+
+		if _, _, err := search.step(); err != nil {
+			return nil, err
+		}
+
+		if trainer.TargetFitness > 0 && search.bestFitness >= trainer.TargetFitness {
+			logg.LogTo("DEBUG", "PSOTrainer reached fitness %v after %d iterations", search.bestFitness, iteration+1)
+			break
+		}
+
+	}
+
+	if err := search.cortex.SetParameters(search.best); err != nil {
+		return nil, err
+	}
+	return search.cortex, nil
+
+}
+
+// Start prepares to train a copy of cortex one iteration at a time with
+// StepGeneration, so that the trainer can be run by a TrainingSession
+// instead of Fit.  MaxIterations and TargetFitness are then up to the
+// session.  Fails the same way as Fit if the trainer can't tune cortex.
+func (trainer *PSOTrainer) Start(cortex *Cortex, examples []*TrainingSample) error {
+	if err := trainer.validate(cortex); err != nil {
+		return err
+	}
+	search, err := trainer.newSearch(cortex, examples)
+	if err != nil {
+		return err
+	}
+	trainer.search = search
+	return nil
+}
+
+// StepGeneration moves the swarm (unless it has only just started) and
+// evaluates every particle, and returns a cortex with the position of
+// the fittest particle along with its fitness.  This makes the trainer a
+// Generational.  Returns ErrNotStarted if Start hasn't been called.
+func (trainer *PSOTrainer) StepGeneration() (*Cortex, float64, error) {
+	search := trainer.search
+	if search == nil {
+		return nil, 0, ErrNotStarted
+	}
+	position, fitness, err := search.step()
+	if err != nil {
+		return nil, 0, err
+	}
+	cortex := search.cortex.Copy()
+	if err := cortex.SetParameters(position); err != nil {
+		return nil, 0, err
+	}
+	return cortex, fitness, nil
+}
+
+// MarshalCheckpoint saves the state of the search begun by Start: the
+// position and velocity of every particle, and the best positions found
+// by each particle and by the swarm, so that training can be resumed by
+// a TrainingSession.  The random number generator is not saved: for
+// training to be resumed deterministically, Rand must wrap the session's
+// RandSource.
+func (trainer *PSOTrainer) MarshalCheckpoint() ([]byte, error) {
+	search := trainer.search
+	if search == nil {
+		return nil, ErrNotStarted
+	}
+	particles := search.swarm
+	bestFitnesses := make([]EncodableFloat, len(particles.bestFitnesses))
+	for i, fitness := range particles.bestFitnesses {
+		bestFitnesses[i] = EncodableFloat(fitness)
+	}
+	return json.Marshal(swarmCheckpoint{
+		Iterations:    search.iterations,
+		Positions:     particles.positions,
+		Velocities:    particles.velocities,
+		BestPositions: particles.bestPositions,
+		BestFitnesses: bestFitnesses,
+		Best:          search.best,
+		BestFitness:   EncodableFloat(search.bestFitness),
+	})
+}
+
+// UnmarshalCheckpoint restores what MarshalCheckpoint saved, into a
+// search begun by Start on the same cortex with the same NumParticles,
+// which must be called first (and before the session is resumed, since
+// it draws random numbers).
+func (trainer *PSOTrainer) UnmarshalCheckpoint(data []byte) error {
+
+	search := trainer.search
+	if search == nil {
+		return ErrNotStarted
+	}
+	checkpoint := swarmCheckpoint{}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return err
+	}
+
+	n := len(search.best)
+	particles := search.swarm
+	if err := checkpointLength("parameters", len(checkpoint.Best), n); err != nil {
+		return err
+	}
+	if err := checkpointLength("particle fitnesses", len(checkpoint.BestFitnesses), len(particles.positions)); err != nil {
+		return err
+	}
+	for _, vectors := range [][][]float64{checkpoint.Positions, checkpoint.Velocities, checkpoint.BestPositions} {
+		if err := checkpointLength("particles", len(vectors), len(particles.positions)); err != nil {
+			return err
+		}
+		for _, vector := range vectors {
+			if err := checkpointLength("parameters", len(vector), n); err != nil {
+				return err
+			}
+		}
+	}
+
+	search.iterations = checkpoint.Iterations
+	particles.positions = checkpoint.Positions
+	particles.velocities = checkpoint.Velocities
+	particles.bestPositions = checkpoint.BestPositions
+	for i, fitness := range checkpoint.BestFitnesses {
+		particles.bestFitnesses[i] = float64(fitness)
+	}
+	search.best = checkpoint.Best
+	search.bestFitness = float64(checkpoint.BestFitness)
+	return nil
+
+}
+
+// the state of a particle swarm search saved in a checkpoint
+type swarmCheckpoint struct {
+	Iterations    int
+	Positions     [][]float64
+	Velocities    [][]float64
+	BestPositions [][]float64
+	BestFitnesses []EncodableFloat
+	Best          []float64
+	BestFitness   EncodableFloat
+}
+
+func (trainer *PSOTrainer) validate(cortex *Cortex) error {
+	if trainer.NumParticles <= 0 {
+		return fmt.Errorf("%w: the swarm needs at least 1 particle, got %d",
+			ErrPopulationSize,
+			trainer.NumParticles)
+	}
+	if len(cortex.Parameters()) == 0 {
+		return fmt.Errorf("%w: cortex has no weights or biases", ErrParameterCount)
+	}
+	return nil
+}
+
+// start a search from a copy of cortex, which must have parameters
+func (trainer *PSOTrainer) newSearch(cortex *Cortex, examples []*TrainingSample) (*swarmSearch, error) {
+
+	cortex = cortex.Copy()
+
+	fitness := trainer.Fitness
+	if fitness == nil {
+		if err := trainer.Mapping.validate(cortex, examples); err != nil {
+			return nil, err
+		}
+		fitness = SampleFitness(examples, trainer.Mapping)
+	}
+
+	rng := trainer.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	start := cortex.Parameters()
+	return &swarmSearch{
+		trainer:     trainer,
+		cortex:      cortex,
+		evaluator:   newParameterEvaluator(cortex, fitness, trainer.Concurrency),
+		swarm:       trainer.newSwarm(start, rng),
+		rng:         rng,
+		best:        append([]float64{}, start...),
+		bestFitness: math.Inf(-1),
+	}, nil
+
+}
+
+// a particle swarm search in progress, tuning the parameters of cortex
+type swarmSearch struct {
+	trainer     *PSOTrainer
+	cortex      *Cortex
+	evaluator   *parameterEvaluator
+	swarm       *swarm
+	rng         *rand.Rand
+	iterations  int
+	best        []float64
+	bestFitness float64
+}
+
+// move the swarm, unless it hasn't been evaluated yet, and evaluate it.
+// Returns the position of the fittest particle along with its fitness.
+func (search *swarmSearch) step() ([]float64, float64, error) {
+
+	particles := search.swarm
+	if search.iterations > 0 {
+		search.trainer.move(particles, search.best, search.rng)
+	}
+
+	fitnesses, err := search.evaluator.evaluate(particles.positions)
+	if err != nil {
+		return nil, 0, err
+	}
+	search.iterations += 1
+
+	fittest := 0
+	for i, particleFitness := range fitnesses {
+		if particleFitness > particles.bestFitnesses[i] {
+			particles.bestFitnesses[i] = particleFitness
+			copy(particles.bestPositions[i], particles.positions[i])
+		}
+		if particleFitness > search.bestFitness {
+			search.bestFitness = particleFitness
+			copy(search.best, particles.positions[i])
+		}
+		if particleFitness > fitnesses[fittest] {
+			fittest = i
+		}
+	}
+
+	return particles.positions[fittest], fitnesses[fittest], nil
+
+}
+
+type swarm struct {
+	positions     [][]float64
+	velocities    [][]float64
+	bestPositions [][]float64
+	bestFitnesses []float64
+}
+
+func (trainer *PSOTrainer) newSwarm(start []float64, rng *rand.Rand) *swarm {
+
+	particles := &swarm{
+		positions:     make([][]float64, trainer.NumParticles),
+		velocities:    make([][]float64, trainer.NumParticles),
+		bestPositions: make([][]float64, trainer.NumParticles),
+		bestFitnesses: make([]float64, trainer.NumParticles),
+	}
+
+	for i := range particles.positions {
+		position := make([]float64, len(start))
+		velocity := make([]float64, len(start))
+		for j, parameter := range start {
+			position[j] = parameter
+			if i > 0 {
+				position[j] += trainer.InitialSpread * (2*rng.Float64() - 1)
+			}
+			velocity[j] = trainer.MaxVelocity * (2*rng.Float64() - 1)
+		}
+		particles.positions[i] = position
+		particles.velocities[i] = velocity
+		particles.bestPositions[i] = append([]float64{}, position...)
+		particles.bestFitnesses[i] = math.Inf(-1)
+	}
+
+	return particles
+
+}
+
+// move every particle according to its velocity, after updating the
+// velocity towards the particle's best and the swarm's best positions
+func (trainer *PSOTrainer) move(particles *swarm, best []float64, rng *rand.Rand) {
+	for i, position := range particles.positions {
+		velocity := particles.velocities[i]
+		ownBest := particles.bestPositions[i]
+		for j := range position {
+			cognitive := trainer.Cognitive * rng.Float64() * (ownBest[j] - position[j])
+			social := trainer.Social * rng.Float64() * (best[j] - position[j])
+			velocity[j] = trainer.Inertia*velocity[j] + cognitive + social
+			velocity[j] = Saturate(velocity[j], -trainer.MaxVelocity, trainer.MaxVelocity)
+			position[j] += velocity[j]
+		}
+	}
+}
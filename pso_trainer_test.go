@@ -0,0 +1,234 @@
+package neurgo
+
+import (
+	"context"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// a swarm with a single particle at position, moving at velocity, whose
+// own best position is ownBest
+func singleParticleSwarm(position, velocity, ownBest []float64) *swarm {
+	return &swarm{
+		positions:     [][]float64{position},
+		velocities:    [][]float64{velocity},
+		bestPositions: [][]float64{ownBest},
+		bestFitnesses: []float64{0},
+	}
+}
+
+func TestPSONewSwarm(t *testing.T) {
+
+	trainer := NewPSOTrainer()
+	trainer.NumParticles = 10
+	trainer.InitialSpread = 0.5
+	trainer.MaxVelocity = 0.25
+
+	start := []float64{1, -2, 3}
+	particles := trainer.newSwarm(start, rand.New(rand.NewSource(1)))
+	assert.Equals(t, len(particles.positions), 10)
+
+	for i, position := range particles.positions {
+		for j, x := range position {
+			if i == 0 {
+				assert.Equals(t, x, start[j])
+			}
+			assert.True(t, math.Abs(x-start[j]) <= trainer.InitialSpread)
+			assert.True(t, math.Abs(particles.velocities[i][j]) <= trainer.MaxVelocity)
+			assert.Equals(t, particles.bestPositions[i][j], x)
+		}
+		assert.True(t, math.IsInf(particles.bestFitnesses[i], -1))
+	}
+
+	// the best positions are copies, so moving doesn't change them
+	particles.positions[1][0] += 10
+	assert.True(t, particles.bestPositions[1][0] != particles.positions[1][0])
+
+}
+
+func TestPSOVelocityClamping(t *testing.T) {
+
+	trainer := NewPSOTrainer()
+	trainer.Inertia = 0
+	trainer.Cognitive = 0
+	trainer.Social = 1000
+	trainer.MaxVelocity = 0.1
+	rng := rand.New(rand.NewSource(1))
+
+	// the swarm's best is far away in both directions, but no component
+	// of the velocity can exceed MaxVelocity
+	particles := singleParticleSwarm([]float64{0, 0}, []float64{0, 0}, []float64{0, 0})
+	best := []float64{100, -100}
+	for i := 0; i < 5; i++ {
+		trainer.move(particles, best, rng)
+		assert.Equals(t, particles.velocities[0][0], 0.1)
+		assert.Equals(t, particles.velocities[0][1], -0.1)
+	}
+	assert.True(t, EqualsWithMaxDelta(particles.positions[0][0], 0.5, 1e-9))
+	assert.True(t, EqualsWithMaxDelta(particles.positions[0][1], -0.5, 1e-9))
+
+	// momentum alone is clamped too
+	trainer.Inertia = 1
+	trainer.Social = 0
+	particles = singleParticleSwarm([]float64{0}, []float64{5}, []float64{0})
+	trainer.move(particles, []float64{0}, rng)
+	assert.Equals(t, particles.velocities[0][0], 0.1)
+
+}
+
+func TestPSOInertia(t *testing.T) {
+
+	trainer := NewPSOTrainer()
+	trainer.Cognitive = 0
+	trainer.Social = 0
+	trainer.MaxVelocity = 10
+	rng := rand.New(rand.NewSource(1))
+
+	// with no pull from the best positions, the particle coasts with a
+	// velocity that decays by Inertia every iteration
+	trainer.Inertia = 0.5
+	particles := singleParticleSwarm([]float64{0}, []float64{4}, []float64{0})
+	trainer.move(particles, []float64{0}, rng)
+	assert.Equals(t, particles.velocities[0][0], 2.0)
+	assert.Equals(t, particles.positions[0][0], 2.0)
+	trainer.move(particles, []float64{0}, rng)
+	assert.Equals(t, particles.velocities[0][0], 1.0)
+	assert.Equals(t, particles.positions[0][0], 3.0)
+
+	// and without inertia it stops dead
+	trainer.Inertia = 0
+	trainer.move(particles, []float64{0}, rng)
+	assert.Equals(t, particles.velocities[0][0], 0.0)
+	assert.Equals(t, particles.positions[0][0], 3.0)
+
+}
+
+func TestPSOCognitiveAndSocial(t *testing.T) {
+
+	trainer := NewPSOTrainer()
+	trainer.Inertia = 0
+	trainer.MaxVelocity = 100
+	rng := rand.New(rand.NewSource(1))
+
+	ownBest := []float64{1}
+	swarmBest := []float64{-1}
+
+	// only the particle's own best pulls it, and with a coefficient of
+	// 1 it moves part of the way there without overshooting
+	trainer.Cognitive = 1
+	trainer.Social = 0
+	for i := 0; i < 20; i++ {
+		particles := singleParticleSwarm([]float64{0}, []float64{0}, ownBest)
+		trainer.move(particles, swarmBest, rng)
+		x := particles.positions[0][0]
+		assert.True(t, x >= 0 && x < 1)
+	}
+
+	// only the swarm's best pulls it
+	trainer.Cognitive = 0
+	trainer.Social = 1
+	for i := 0; i < 20; i++ {
+		particles := singleParticleSwarm([]float64{0}, []float64{0}, ownBest)
+		trainer.move(particles, swarmBest, rng)
+		x := particles.positions[0][0]
+		assert.True(t, x <= 0 && x > -1)
+	}
+
+	// the pull is proportional to the coefficient: with a coefficient
+	// of 2 it can overshoot, but never by more than the distance
+	trainer.Social = 2
+	overshot := false
+	for i := 0; i < 20; i++ {
+		particles := singleParticleSwarm([]float64{0}, []float64{0}, ownBest)
+		trainer.move(particles, swarmBest, rng)
+		x := particles.positions[0][0]
+		assert.True(t, x <= 0 && x > -2)
+		overshot = overshot || x < -1
+	}
+	assert.True(t, overshot)
+
+}
+
+func TestPSOTrainerXnor(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	untrained := SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+
+	trainer := NewPSOTrainer()
+	trainer.Rand = rand.New(rand.NewSource(1))
+	trainer.Concurrency = 4
+
+	trained, err := trainer.Fit(untrained, examples)
+	assert.True(t, err == nil)
+
+	fitness, err := trained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= trainer.TargetFitness)
+
+}
+
+func TestPSOTrainerInvalid(t *testing.T) {
+
+	// Fit and Start reject a swarm without particles, or a cortex with
+	// nothing to tune, the same way
+	trainer := NewPSOTrainer()
+	_, err := trainer.Fit(&Cortex{}, nil)
+	assert.True(t, errors.Is(err, ErrParameterCount))
+	assert.True(t, errors.Is(trainer.Start(&Cortex{}, nil), ErrParameterCount))
+
+	trainer.NumParticles = 0
+	cortex := SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+	_, err = trainer.Fit(cortex, XnorTrainingSamples())
+	assert.True(t, errors.Is(err, ErrPopulationSize))
+	assert.True(t, errors.Is(trainer.Start(cortex, XnorTrainingSamples()), ErrPopulationSize))
+
+}
+
+func TestPSOTrainerSession(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+
+	trainer := NewPSOTrainer()
+	trainer.Rand = rand.New(rand.NewSource(1))
+	trainer.Concurrency = 4
+
+	assert.True(t, trainer.Start(SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), examples) == nil)
+
+	session := NewTrainingSession()
+	best, err := session.Evolve(context.Background(), trainer, trainer.MaxIterations, trainer.TargetFitness)
+	assert.True(t, err == nil)
+	fitness, err := best.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness >= trainer.TargetFitness)
+	assert.Equals(t, len(session.History), trainer.search.iterations)
+
+}
+
+func TestPSOTrainerResume(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	newTrainer := func(session *TrainingSession) Generational {
+		session.RandSource = NewRandSource(1)
+		trainer := NewPSOTrainer()
+		trainer.Rand = rand.New(session.RandSource)
+		trainer.Concurrency = 4
+		assert.True(t, trainer.Start(SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), examples) == nil)
+		return trainer
+	}
+	assertResumesExactly(t, newTrainer, 20, 5)
+
+	// a checkpoint can only be restored into a started trainer, with
+	// as many particles
+	trainer := newTrainer(NewTrainingSession()).(*PSOTrainer)
+	data, err := trainer.MarshalCheckpoint()
+	assert.True(t, err == nil)
+	assert.True(t, errors.Is(NewPSOTrainer().UnmarshalCheckpoint(data), ErrNotStarted))
+	other := NewPSOTrainer()
+	other.NumParticles = 10
+	assert.True(t, other.Start(SeededXnorCortexUntrained(rand.New(rand.NewSource(1))), examples) == nil)
+	assert.True(t, errors.Is(other.UnmarshalCheckpoint(data), ErrParameterCount))
+
+}
@@ -0,0 +1,52 @@
+package neurgo
+
+// RandSource is a rand.Source64 whose state can be saved as JSON, so
+// that a training run which was checkpointed can be resumed with the
+// same random numbers it would have drawn had it carried on.  Wrap it
+// with rand.New to use it as the Rand of a trainer or population.  The
+// generator is xoshiro256** (see http://prng.di.unimi.it/), seeded with
+// splitmix64.
+//
+// Like the sources in math/rand, it must not be used from more than one
+// goroutine at a time.
+type RandSource struct {
+	State [4]uint64
+}
+
+func NewRandSource(seed int64) *RandSource {
+	source := &RandSource{}
+	source.Seed(seed)
+	return source
+}
+
+func (source *RandSource) Seed(seed int64) {
+	x := uint64(seed)
+	for i := range source.State {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		source.State[i] = z ^ (z >> 31)
+	}
+}
+
+func (source *RandSource) Uint64() uint64 {
+	s := &source.State
+	result := rotateLeft(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = rotateLeft(s[3], 45)
+	return result
+}
+
+func (source *RandSource) Int63() int64 {
+	return int64(source.Uint64() >> 1)
+}
+
+func rotateLeft(x uint64, k uint) uint64 {
+	return (x << k) | (x >> (64 - k))
+}
@@ -0,0 +1,45 @@
+package neurgo
+
+import (
+	"encoding/json"
+	"github.com/couchbaselabs/go.assert"
+	"math/rand"
+	"testing"
+)
+
+func TestRandSource(t *testing.T) {
+
+	a := rand.New(NewRandSource(1))
+	b := rand.New(NewRandSource(1))
+	c := rand.New(NewRandSource(2))
+	different := 0
+	for i := 0; i < 100; i++ {
+		x := a.Float64()
+		assert.Equals(t, b.Float64(), x)
+		assert.True(t, x >= 0 && x < 1)
+		if c.Float64() != x {
+			different += 1
+		}
+	}
+	assert.Equals(t, different, 100)
+
+}
+
+func TestRandSourceJSON(t *testing.T) {
+
+	source := NewRandSource(1)
+	rng := rand.New(source)
+	rng.NormFloat64()
+
+	jsonBytes, err := json.Marshal(source)
+	assert.True(t, err == nil)
+	restored := &RandSource{}
+	assert.True(t, json.Unmarshal(jsonBytes, restored) == nil)
+
+	// the restored source carries on with the same numbers
+	restoredRng := rand.New(restored)
+	for i := 0; i < 10; i++ {
+		assert.Equals(t, restoredRng.Intn(1000), rng.Intn(1000))
+	}
+
+}
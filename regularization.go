@@ -0,0 +1,140 @@
+package neurgo
+
+import (
+	"math"
+)
+
+// Regularization keeps the weights of a cortex small while it is being
+// trained.  The L1 and L2 terms are added to the loss minimized by the
+// gradient based trainers (set both for an elastic net, see ElasticNet),
+// WeightDecay shrinks the weights after every update independently of
+// the loss, and MaxNorm and Clip are hard limits enforced after every
+// update.  Any field left at 0 is not applied.
+type Regularization struct {
+
+	// coefficient of the sum of the absolute values of the weights
+	L1 float64
+
+	// coefficient of half the sum of the squares of the weights
+	L2 float64
+
+	// fraction of every weight removed after each update.  Unlike L2,
+	// this is not scaled by the optimizer, so adaptive optimizers such
+	// as Adam decay every weight at the same rate (see Loshchilov and
+	// Hutter, "Decoupled Weight Decay Regularization").
+	WeightDecay float64
+
+	// maximum euclidean norm of the inbound weights of each neuron.
+	// Neurons whose weights are longer are scaled back down to it.
+	MaxNorm float64
+
+	// every weight is clipped to lie between -Clip and Clip
+	Clip float64
+
+	// whether L1, L2, WeightDecay and Clip apply to the biases too.
+	// MaxNorm never includes the bias.
+	Biases bool
+}
+
+// ElasticNet combines L1 and L2 with a total strength, of which ratio
+// goes to L1 and the rest to L2
+func ElasticNet(strength, ratio float64) *Regularization {
+	return &Regularization{
+		L1: strength * ratio,
+		L2: strength * (1 - ratio),
+	}
+}
+
+// Penalty returns the L1 and L2 terms for the current weights of cortex
+func (regularization *Regularization) Penalty(cortex *Cortex) float64 {
+	penalty := float64(0)
+	regularization.eachValue(cortex, func(value *float64) {
+		penalty += regularization.L1 * math.Abs(*value)
+		penalty += regularization.L2 * (*value) * (*value) / 2
+	})
+	return penalty
+}
+
+// Constrain enforces MaxNorm and Clip on the weights of cortex
+func (regularization *Regularization) Constrain(cortex *Cortex) {
+	if regularization.MaxNorm > 0 {
+		for _, neuron := range cortex.Neurons {
+			sumOfSquares := float64(0)
+			for _, inbound := range neuron.Inbound {
+				for _, weight := range inbound.Weights {
+					sumOfSquares += weight * weight
+				}
+			}
+			norm := math.Sqrt(sumOfSquares)
+			if norm <= regularization.MaxNorm {
+				continue
+			}
+			scale := regularization.MaxNorm / norm
+			for _, inbound := range neuron.Inbound {
+				for j := range inbound.Weights {
+					inbound.Weights[j] *= scale
+				}
+			}
+		}
+	}
+	if regularization.Clip > 0 {
+		regularization.eachValue(cortex, func(value *float64) {
+			*value = Saturate(*value, -regularization.Clip, regularization.Clip)
+		})
+	}
+}
+
+// RegularizedFitness subtracts the Penalty from fitness, so that the
+// black box trainers prefer cortexes with smaller weights
+func RegularizedFitness(fitness FitnessFunc, regularization *Regularization) FitnessFunc {
+	return func(cortex *Cortex) (float64, error) {
+		value, err := fitness(cortex)
+		if err != nil {
+			return value, err
+		}
+		return value - regularization.Penalty(cortex), nil
+	}
+}
+
+// addGradients adds the gradient of the Penalty to gradients
+func (regularization *Regularization) addGradients(cortex *Cortex, gradients Gradients) {
+	for _, neuron := range cortex.Neurons {
+		gradient := gradients[neuron.NodeId.UUID]
+		if regularization.Biases {
+			gradient.Bias += regularization.penaltyGradient(neuron.Bias)
+		}
+		for i, inbound := range neuron.Inbound {
+			for j, weight := range inbound.Weights {
+				gradient.Weights[i][j] += regularization.penaltyGradient(weight)
+			}
+		}
+	}
+}
+
+func (regularization *Regularization) penaltyGradient(value float64) float64 {
+	return regularization.L2*value + regularization.L1*sign(value)
+}
+
+// update applies WeightDecay and the constraints after an optimizer step
+func (regularization *Regularization) update(cortex *Cortex) {
+	if regularization.WeightDecay > 0 {
+		regularization.eachValue(cortex, func(value *float64) {
+			*value *= 1 - regularization.WeightDecay
+		})
+	}
+	regularization.Constrain(cortex)
+}
+
+// call fn with every weight, and every bias if Biases is set
+func (regularization *Regularization) eachValue(cortex *Cortex, fn func(value *float64)) {
+	for _, neuron := range cortex.Neurons {
+		if regularization.Biases {
+			fn(&neuron.Bias)
+		}
+		for _, inbound := range neuron.Inbound {
+			for j := range inbound.Weights {
+				fn(&inbound.Weights[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package neurgo
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRegularizationPenalty(t *testing.T) {
+
+	cortex := XnorCortex()
+
+	// 6 weights of magnitude 20, and biases of -30, 10 and -10
+	assert.Equals(t, (&Regularization{L1: 1}).Penalty(cortex), 120.0)
+	assert.Equals(t, (&Regularization{L2: 1}).Penalty(cortex), 1200.0)
+	assert.Equals(t, (&Regularization{L1: 1, Biases: true}).Penalty(cortex), 170.0)
+	assert.Equals(t, ElasticNet(2, 0.25).Penalty(cortex), 0.5*120+1.5*1200)
+	assert.Equals(t, (&Regularization{}).Penalty(cortex), 0.0)
+
+}
+
+func TestRegularizationGradients(t *testing.T) {
+
+	cortex := SeededXnorCortexUntrained(rand.New(rand.NewSource(1)))
+	regularization := &Regularization{L1: 0.3, L2: 0.7, Biases: true}
+
+	gradients := NewGradients(cortex)
+	regularization.addGradients(cortex, gradients)
+
+	// compare with central differences of the penalty
+	layout := cortex.ParameterLayout()
+	parameters := cortex.Parameters()
+	epsilon := 1e-6
+	for i, parameter := range layout {
+		shifted := append([]float64{}, parameters...)
+		shifted[i] = parameters[i] + epsilon
+		assert.True(t, cortex.SetParameters(shifted) == nil)
+		above := regularization.Penalty(cortex)
+		shifted[i] = parameters[i] - epsilon
+		assert.True(t, cortex.SetParameters(shifted) == nil)
+		below := regularization.Penalty(cortex)
+
+		gradient := gradients[parameter.NeuronUUID]
+		analytic := gradient.Bias
+		if !parameter.IsBias() {
+			analytic = gradient.Weights[parameter.Inbound][parameter.Weight]
+		}
+		assert.True(t, EqualsWithMaxDelta((above-below)/(2*epsilon), analytic, 1e-6))
+	}
+
+}
+
+func TestRegularizationConstrain(t *testing.T) {
+
+	cortex := XnorCortex()
+	(&Regularization{MaxNorm: 1}).Constrain(cortex)
+	for _, neuron := range cortex.Neurons {
+		for _, inbound := range neuron.Inbound {
+			assert.True(t, EqualsWithMaxDelta(math.Abs(inbound.Weights[0]), math.Sqrt(0.5), 1e-12))
+		}
+	}
+	assert.Equals(t, cortex.Neurons[0].Bias, -30.0)
+
+	cortex = XnorCortex()
+	(&Regularization{Clip: 5}).Constrain(cortex)
+	for _, neuron := range cortex.Neurons {
+		for _, inbound := range neuron.Inbound {
+			assert.Equals(t, math.Abs(inbound.Weights[0]), 5.0)
+		}
+	}
+	assert.Equals(t, cortex.Neurons[0].Bias, -30.0)
+
+	(&Regularization{Clip: 5, Biases: true}).Constrain(cortex)
+	assert.Equals(t, cortex.Neurons[0].Bias, -5.0)
+
+}
+
+func TestBackpropTrainerRegularization(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+
+	largestWeight := func(cortex *Cortex) float64 {
+		largest := float64(0)
+		for _, neuron := range cortex.Neurons {
+			for _, inbound := range neuron.Inbound {
+				for _, weight := range inbound.Weights {
+					largest = math.Max(largest, math.Abs(weight))
+				}
+			}
+		}
+		return largest
+	}
+
+	train := func(regularization *Regularization, optimizer Optimizer) *Cortex {
+		trainer := NewBackpropTrainer()
+		trainer.MaxEpochs = 200
+		trainer.TargetFitness = 0
+		trainer.Regularization = regularization
+		trainer.Optimizer = optimizer
+		trained, err := trainer.Fit(XnorCortex(), examples)
+		assert.True(t, err == nil)
+		return trained
+	}
+
+	unregularized := largestWeight(train(nil, nil))
+	assert.True(t, largestWeight(train(&Regularization{L2: 0.01}, nil)) < unregularized)
+	assert.True(t, largestWeight(train(&Regularization{L1: 0.01}, nil)) < unregularized)
+
+	// decoupled decay shrinks the weights even though Adam normalizes
+	// the size of its steps
+	decayed := train(&Regularization{WeightDecay: 0.01}, NewAdam(0.001))
+	assert.True(t, largestWeight(decayed) < largestWeight(train(nil, NewAdam(0.001))))
+
+	constrained := train(&Regularization{MaxNorm: 10}, nil)
+	for _, neuron := range constrained.Neurons {
+		norm := float64(0)
+		for _, inbound := range neuron.Inbound {
+			norm += inbound.Weights[0] * inbound.Weights[0]
+		}
+		assert.True(t, math.Sqrt(norm) <= 10+1e-9)
+	}
+
+}
+
+func TestRegularizationEpochLoss(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	cortex := XnorCortex()
+	regularization := &Regularization{L2: 0.001}
+	penalty := regularization.Penalty(cortex)
+	sse, err := cortex.Evaluate(examples, SSE{})
+	assert.True(t, err == nil)
+
+	// with a learning rate of 0 the cortex doesn't change, so the loss
+	// of an epoch is the SSE plus the penalty counted once, however many
+	// samples there are
+	backprop := NewBackpropTrainer()
+	backprop.LearningRate = 0
+	backprop.Regularization = regularization
+	bptt := NewBPTTTrainer()
+	bptt.LearningRate = 0
+	bptt.TruncationLength = 1
+	bptt.Regularization = regularization
+	doubled := append(append([]*TrainingSample{}, examples...), examples...)
+
+	for _, trainer := range []EpochTrainer{backprop, bptt} {
+		loss, err := trainer.TrainEpoch(cortex, examples)
+		assert.True(t, err == nil)
+		assert.True(t, EqualsWithMaxDelta(loss, sse+penalty, 1e-12))
+		loss, err = trainer.TrainEpoch(cortex, doubled)
+		assert.True(t, err == nil)
+		assert.True(t, EqualsWithMaxDelta(loss, 2*sse+penalty, 1e-12))
+	}
+
+}
+
+func TestRegularizedFitness(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	cortex := XnorCortex()
+	regularization := &Regularization{L2: 0.001}
+
+	fitness, err := SampleFitness(examples, nil)(cortex)
+	assert.True(t, err == nil)
+	regularized, err := RegularizedFitness(SampleFitness(examples, nil), regularization)(cortex)
+	assert.True(t, err == nil)
+	assert.Equals(t, regularized, fitness-regularization.Penalty(cortex))
+
+}
@@ -0,0 +1,148 @@
+package neurgo
+
+import (
+	"fmt"
+)
+
+// A SampleMapping says which sensor each of the TrainingSample.SampleInputs
+// vectors is fed into, and which actuator each of the
+// TrainingSample.ExpectedOutputs vectors is compared against.
+//
+// A nil SampleMapping (or one with empty UUID lists) maps by index:
+// SampleInputs[i] goes to cortex.Sensors[i] and ExpectedOutputs[j] is
+// compared against cortex.Actuators[j].  Otherwise SampleInputs[i] goes
+// to the sensor with UUID SensorUUIDs[i], and so on.  Every sensor must
+// be given an input, but ActuatorUUIDs may name a subset of the
+// actuators, in which case the others are ignored.
+type SampleMapping struct {
+	SensorUUIDs   []string
+	ActuatorUUIDs []string
+}
+
+// resolve the mapping into indexes into cortex.Sensors and cortex.Actuators
+func (mapping *SampleMapping) resolve(cortex *Cortex) (sensorIndexes, actuatorIndexes []int, err error) {
+
+	var sensorUUIDs, actuatorUUIDs []string
+	if mapping != nil {
+		sensorUUIDs = mapping.SensorUUIDs
+		actuatorUUIDs = mapping.ActuatorUUIDs
+	}
+
+	if len(sensorUUIDs) == 0 {
+		sensorIndexes = identityIndexes(len(cortex.Sensors))
+	} else {
+		sensorIndexes = make([]int, len(sensorUUIDs))
+		for i, uuid := range sensorUUIDs {
+			sensorIndexes[i] = -1
+			for j, sensor := range cortex.Sensors {
+				if sensor.NodeId.UUID == uuid {
+					sensorIndexes[i] = j
+				}
+			}
+			if sensorIndexes[i] == -1 {
+				return nil, nil, fmt.Errorf("%w: no sensor with uuid %v", ErrSampleShape, uuid)
+			}
+		}
+		if err := checkCoversAll(sensorIndexes, len(cortex.Sensors)); err != nil {
+			return nil, nil, fmt.Errorf("%w: SensorUUIDs %v", err, sensorUUIDs)
+		}
+	}
+
+	if len(actuatorUUIDs) == 0 {
+		actuatorIndexes = identityIndexes(len(cortex.Actuators))
+	} else {
+		actuatorIndexes = make([]int, len(actuatorUUIDs))
+		for i, uuid := range actuatorUUIDs {
+			actuatorIndexes[i] = -1
+			for j, actuator := range cortex.Actuators {
+				if actuator.NodeId.UUID == uuid {
+					actuatorIndexes[i] = j
+				}
+			}
+			if actuatorIndexes[i] == -1 {
+				return nil, nil, fmt.Errorf("%w: no actuator with uuid %v", ErrSampleShape, uuid)
+			}
+		}
+	}
+
+	return sensorIndexes, actuatorIndexes, nil
+
+}
+
+// validate makes sure every sample has the shape expected by the cortex,
+// so that problems are reported up front rather than halfway through
+// an evaluation.
+func (mapping *SampleMapping) validate(cortex *Cortex, samples []*TrainingSample) error {
+
+	sensorIndexes, actuatorIndexes, err := mapping.resolve(cortex)
+	if err != nil {
+		return err
+	}
+
+	for n, sample := range samples {
+		if len(sample.SampleInputs) != len(sensorIndexes) {
+			return fmt.Errorf("%w: sample %d has %d input vectors, expected %d",
+				ErrSampleShape,
+				n,
+				len(sample.SampleInputs),
+				len(sensorIndexes))
+		}
+		for i, input := range sample.SampleInputs {
+			sensor := cortex.Sensors[sensorIndexes[i]]
+			if len(input) != sensor.VectorLength {
+				err := fmt.Errorf("%w: sample %d input %d has length %d, expected %d",
+					ErrSampleShape,
+					n,
+					i,
+					len(input),
+					sensor.VectorLength)
+				return newNodeError(sensor.NodeId, err)
+			}
+		}
+		if len(sample.ExpectedOutputs) != len(actuatorIndexes) {
+			return fmt.Errorf("%w: sample %d has %d expected output vectors, expected %d",
+				ErrSampleShape,
+				n,
+				len(sample.ExpectedOutputs),
+				len(actuatorIndexes))
+		}
+		for j, expected := range sample.ExpectedOutputs {
+			actuator := cortex.Actuators[actuatorIndexes[j]]
+			if len(expected) != actuator.VectorLength {
+				err := fmt.Errorf("%w: sample %d expected output %d has length %d, expected %d",
+					ErrSampleShape,
+					n,
+					j,
+					len(expected),
+					actuator.VectorLength)
+				return newNodeError(actuator.NodeId, err)
+			}
+		}
+	}
+
+	return nil
+
+}
+
+func identityIndexes(length int) []int {
+	indexes := make([]int, length)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// make sure that indexes contains each of 0 .. length-1 exactly once
+func checkCoversAll(indexes []int, length int) error {
+	if len(indexes) != length {
+		return fmt.Errorf("%w: expected %d entries, got %d", ErrSampleShape, length, len(indexes))
+	}
+	seen := make([]bool, length)
+	for _, index := range indexes {
+		if seen[index] {
+			return fmt.Errorf("%w: duplicate entry", ErrSampleShape)
+		}
+		seen[index] = true
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package neurgo
+
+import (
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"testing"
+)
+
+// Build samples for the back edge cortex whose expected outputs are off
+// from the real outputs by delta in every element.
+func backEdgeRecurrentSamples(t *testing.T, delta float64) []*TrainingSample {
+	cortex := backEdgeRecurrentCortex()
+	ticks := backEdgeRecurrentInputs()
+	outputs := runEvaluationPlan(t, cortex, ticks)
+	samples := make([]*TrainingSample, len(ticks))
+	for i, inputs := range ticks {
+		expected := make([][]float64, len(outputs[i]))
+		for j, output := range outputs[i] {
+			expected[j] = make([]float64, len(output))
+			for k, value := range output {
+				expected[j][k] = value + delta
+			}
+		}
+		samples[i] = &TrainingSample{
+			SampleInputs:    inputs,
+			ExpectedOutputs: expected,
+		}
+	}
+	return samples
+}
+
+func TestFitnessMultipleSensorsActuators(t *testing.T) {
+
+	samples := backEdgeRecurrentSamples(t, 0.5)
+
+	// 6 samples, each with 3 output values off by 0.5
+	expectedFitness := 1 / (6 * 3 * 0.25)
+
+	fitness, err := backEdgeRecurrentCortex().Fitness(samples)
+	assert.True(t, err == nil)
+	assert.True(t, EqualsWithMaxDelta(fitness, expectedFitness, 1e-9))
+
+}
+
+func TestFitnessMappedByUUID(t *testing.T) {
+
+	samples := backEdgeRecurrentSamples(t, 0.5)
+
+	// swap the order of the vectors in each sample, and then use a
+	// mapping which swaps them back
+	swapped := make([]*TrainingSample, len(samples))
+	for i, sample := range samples {
+		swapped[i] = &TrainingSample{
+			SampleInputs: [][]float64{
+				sample.SampleInputs[1],
+				sample.SampleInputs[0],
+			},
+			ExpectedOutputs: [][]float64{
+				sample.ExpectedOutputs[1],
+				sample.ExpectedOutputs[0],
+			},
+		}
+	}
+	mapping := &SampleMapping{
+		SensorUUIDs:   []string{"sensor2", "sensor1"},
+		ActuatorUUIDs: []string{"actuator2", "actuator1"},
+	}
+
+	expectedFitness, err := backEdgeRecurrentCortex().Fitness(samples)
+	assert.True(t, err == nil)
+
+	fitness, err := backEdgeRecurrentCortex().FitnessMapped(swapped, mapping)
+	assert.True(t, err == nil)
+	assert.Equals(t, fitness, expectedFitness)
+
+	// only compare against a subset of the actuators
+	subset := make([]*TrainingSample, len(samples))
+	for i, sample := range samples {
+		subset[i] = &TrainingSample{
+			SampleInputs:    sample.SampleInputs,
+			ExpectedOutputs: sample.ExpectedOutputs[1:],
+		}
+	}
+	mapping = &SampleMapping{
+		ActuatorUUIDs: []string{"actuator2"},
+	}
+	fitness, err = backEdgeRecurrentCortex().FitnessMapped(subset, mapping)
+	assert.True(t, err == nil)
+	assert.True(t, EqualsWithMaxDelta(fitness, 1/(6*2*0.25), 1e-9))
+	assert.False(t, math.IsInf(fitness, 0))
+
+}
+
+func TestFitnessSampleShapeErrors(t *testing.T) {
+
+	cortex := backEdgeRecurrentCortex()
+	samples := backEdgeRecurrentSamples(t, 0.5)
+
+	// missing an input vector
+	samples[2].SampleInputs = samples[2].SampleInputs[:1]
+	_, err := cortex.Fitness(samples)
+	assert.True(t, errors.Is(err, ErrSampleShape))
+
+	// input vector of the wrong length
+	samples = backEdgeRecurrentSamples(t, 0.5)
+	samples[0].SampleInputs[1] = []float64{1, 2}
+	_, err = cortex.Fitness(samples)
+	assert.True(t, errors.Is(err, ErrSampleShape))
+	var nodeErr *NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, "sensor2")
+
+	// expected output of the wrong length
+	samples = backEdgeRecurrentSamples(t, 0.5)
+	samples[0].ExpectedOutputs[1] = []float64{1}
+	_, err = cortex.Fitness(samples)
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, "actuator2")
+
+	// mapping which names an unknown sensor
+	samples = backEdgeRecurrentSamples(t, 0.5)
+	mapping := &SampleMapping{SensorUUIDs: []string{"sensor1", "bogus"}}
+	_, err = cortex.FitnessMapped(samples, mapping)
+	assert.True(t, errors.Is(err, ErrSampleShape))
+
+	// mapping which doesn't give every sensor an input
+	mapping = &SampleMapping{SensorUUIDs: []string{"sensor1", "sensor1"}}
+	_, err = cortex.FitnessMapped(samples, mapping)
+	assert.True(t, errors.Is(err, ErrSampleShape))
+
+}
@@ -0,0 +1,73 @@
+package neurgo
+
+import (
+	"math"
+)
+
+// A LearningRateSetter has a learning rate which can be changed between
+// epochs, such as the gradient based trainers and their optimizers
+type LearningRateSetter interface {
+	SetLearningRate(rate float64)
+}
+
+// A LearningRateSchedule gives the learning rate to use for each epoch,
+// starting from 0
+type LearningRateSchedule func(epoch int) float64
+
+// StepDecay starts at initial, and multiplies the learning rate by factor
+// every so many epochs.  If every is 0 or less the learning rate never
+// decays.
+func StepDecay(initial, factor float64, every int) LearningRateSchedule {
+	return func(epoch int) float64 {
+		if every <= 0 {
+			return initial
+		}
+		return initial * math.Pow(factor, float64(epoch/every))
+	}
+}
+
+// ExponentialDecay starts at initial, and multiplies the learning rate by
+// rate every epoch
+func ExponentialDecay(initial, rate float64) LearningRateSchedule {
+	return func(epoch int) float64 {
+		return initial * math.Pow(rate, float64(epoch))
+	}
+}
+
+// CosineAnnealing decreases the learning rate from initial to minimum
+// over the given number of epochs, following half a cosine wave, and
+// stays at minimum afterwards
+func CosineAnnealing(initial, minimum float64, epochs int) LearningRateSchedule {
+	return func(epoch int) float64 {
+		if epoch >= epochs {
+			return minimum
+		}
+		return cosineBetween(initial, minimum, epoch, epochs)
+	}
+}
+
+// WarmRestarts is cosine annealing from initial to minimum which restarts
+// from initial every period epochs, with the period multiplied by
+// multiplier after each restart.  See Loshchilov and Hutter, "SGDR:
+// Stochastic Gradient Descent with Warm Restarts".
+func WarmRestarts(initial, minimum float64, period int, multiplier float64) LearningRateSchedule {
+	return func(epoch int) float64 {
+		length := period
+		for epoch >= length {
+			epoch -= length
+			length = int(math.Max(1, math.Round(float64(length)*multiplier)))
+		}
+		return cosineBetween(initial, minimum, epoch, length)
+	}
+}
+
+func cosineBetween(initial, minimum float64, epoch, epochs int) float64 {
+	progress := float64(epoch) / float64(epochs)
+	return minimum + (initial-minimum)*(1+math.Cos(math.Pi*progress))/2
+}
+
+func (optimizer *SGD) SetLearningRate(rate float64)      { optimizer.LearningRate = rate }
+func (optimizer *Momentum) SetLearningRate(rate float64) { optimizer.LearningRate = rate }
+func (optimizer *RMSProp) SetLearningRate(rate float64)  { optimizer.LearningRate = rate }
+func (optimizer *Adagrad) SetLearningRate(rate float64)  { optimizer.LearningRate = rate }
+func (optimizer *Adam) SetLearningRate(rate float64)     { optimizer.LearningRate = rate }
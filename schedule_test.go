@@ -0,0 +1,55 @@
+package neurgo
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	"testing"
+)
+
+func TestStepDecay(t *testing.T) {
+	schedule := StepDecay(1, 0.5, 10)
+	assert.Equals(t, schedule(0), 1.0)
+	assert.Equals(t, schedule(9), 1.0)
+	assert.Equals(t, schedule(10), 0.5)
+	assert.Equals(t, schedule(25), 0.25)
+
+	// a step of 0 epochs never decays
+	schedule = StepDecay(1, 0.5, 0)
+	assert.Equals(t, schedule(0), 1.0)
+	assert.Equals(t, schedule(100), 1.0)
+}
+
+func TestExponentialDecay(t *testing.T) {
+	schedule := ExponentialDecay(2, 0.5)
+	assert.Equals(t, schedule(0), 2.0)
+	assert.Equals(t, schedule(1), 1.0)
+	assert.Equals(t, schedule(3), 0.25)
+}
+
+func TestCosineAnnealing(t *testing.T) {
+	schedule := CosineAnnealing(1, 0.1, 10)
+	assert.Equals(t, schedule(0), 1.0)
+	assert.True(t, EqualsWithMaxDelta(schedule(5), 0.55, 1e-12))
+	assert.True(t, schedule(9) > 0.1)
+	assert.Equals(t, schedule(10), 0.1)
+	assert.Equals(t, schedule(100), 0.1)
+}
+
+func TestWarmRestarts(t *testing.T) {
+
+	schedule := WarmRestarts(1, 0, 4, 2)
+
+	// the first cycle is 4 epochs, the next 8, then 16
+	for _, restart := range []int{0, 4, 12, 28} {
+		assert.Equals(t, schedule(restart), 1.0)
+		assert.True(t, schedule(restart+1) < 1.0)
+	}
+	assert.True(t, EqualsWithMaxDelta(schedule(2), 0.5, 1e-12))
+	assert.True(t, EqualsWithMaxDelta(schedule(8), 0.5, 1e-12))
+
+	// without a multiplier every cycle is the same
+	constant := WarmRestarts(1, 0, 3, 1)
+	for epoch := 0; epoch < 3; epoch++ {
+		assert.Equals(t, constant(epoch+30), constant(epoch))
+	}
+
+}
@@ -1,11 +1,11 @@
 package neurgo
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/couchbaselabs/logg"
-	"log"
 	"sync"
 )
 
@@ -19,6 +19,8 @@ type Sensor struct {
 	SyncChan       chan bool
 	SensorFunction SensorFunction
 	wg             *sync.WaitGroup
+	closing        chan chan bool
+	stopped        chan struct{}
 	Cortex         *Cortex
 }
 
@@ -43,21 +45,30 @@ func (sensor *Sensor) Init() {
 	if sensor.wg == nil {
 		sensor.wg = &sync.WaitGroup{}
 		sensor.wg.Add(1)
+		sensor.closing = sensor.Closing
+		sensor.stopped = make(chan struct{})
 	}
 
 }
 
-func (sensor *Sensor) Run() {
+func (sensor *Sensor) Run(ctx context.Context) {
 
 	defer sensor.wg.Done()
+	defer close(sensor.stopped)
+	defer sensor.Cortex.recoverNode(sensor.NodeId)
 
-	sensor.checkRunnable()
+	if err := sensor.checkRunnable(); err != nil {
+		sensor.Cortex.fail(newNodeError(sensor.NodeId, err))
+		return
+	}
 
 	closed := false
 	syncCounter := 0
 
 	for {
 		select {
+		case <-ctx.Done():
+			closed = true
 		case responseChan := <-sensor.Closing:
 			closed = true
 			responseChan <- true
@@ -67,11 +78,14 @@ func (sensor *Sensor) Run() {
 			logg.LogTo("SENSOR_SYNC", logmsg)
 			input := sensor.SensorFunction(syncCounter)
 			syncCounter += 1
+			if noise := sensor.Cortex.noise(); noise != nil {
+				input = noise.perturbInput(input)
+			}
 			dataMessage := &DataMessage{
 				SenderId: sensor.NodeId,
 				Inputs:   input,
 			}
-			sensor.scatterOutput(dataMessage)
+			closed = sensor.scatterOutput(ctx, dataMessage)
 		}
 
 		if closed {
@@ -83,19 +97,19 @@ func (sensor *Sensor) Run() {
 
 }
 
-func (sensor *Sensor) Shutdown() {
+// Shutdown stops the goroutine started by Run and waits for it to
+// return.  Returns ErrNodeStopped if it has already stopped, for
+// instance because the cortex was stopped or its context cancelled.
+func (sensor *Sensor) Shutdown() error {
 
-	closingResponse := make(chan bool)
-	sensor.Closing <- closingResponse
-	response := <-closingResponse
-	if response != true {
-		log.Panicf("Got unexpected response on closing channel")
+	if err := shutdownNode(sensor.NodeId, sensor.closing, sensor.stopped); err != nil {
+		return err
 	}
 
 	sensor.shutdownOutboundConnections()
 
-	sensor.wg.Wait()
-	sensor.wg = nil
+	sensor.wait()
+	return nil
 }
 
 func (s *Sensor) ConnectOutbound(connectable OutboundConnectable) *OutboundConnection {
@@ -127,32 +141,37 @@ func (sensor *Sensor) setOutbound(newOutbound []*OutboundConnection) {
 	sensor.Outbound = newOutbound
 }
 
-func (sensor *Sensor) checkRunnable() {
+// wait blocks until the goroutine started by Run has returned
+func (sensor *Sensor) wait() {
+	if sensor.wg != nil {
+		sensor.wg.Wait()
+		sensor.wg = nil
+	}
+}
+
+func (sensor *Sensor) checkRunnable() error {
 	if sensor.NodeId == nil {
-		msg := fmt.Sprintf("not expecting sensor.NodeId to be nil")
-		panic(msg)
+		return errors.New("not expecting sensor.NodeId to be nil")
 	}
 
 	if sensor.Closing == nil {
-		msg := fmt.Sprintf("not expecting sensor.Closing to be nil")
-		panic(msg)
+		return errors.New("not expecting sensor.Closing to be nil")
 	}
 
 	if sensor.SyncChan == nil {
-		msg := fmt.Sprintf("not expecting sensor.SyncChan to be nil")
-		panic(msg)
+		return errors.New("not expecting sensor.SyncChan to be nil")
 	}
 
 	if sensor.SensorFunction == nil {
-		msg := fmt.Sprintf("not expecting sensor.SensorFunction to be nil")
-		panic(msg)
+		return errors.New("not expecting sensor.SensorFunction to be nil")
 	}
 
 	if err := sensor.validateOutbound(); err != nil {
-		msg := fmt.Sprintf("invalid outbound connection(s): %v", err.Error())
-		panic(msg)
+		return fmt.Errorf("invalid outbound connection(s): %v", err.Error())
 	}
 
+	return nil
+
 }
 
 func (sensor *Sensor) validateOutbound() error {
@@ -165,7 +184,7 @@ func (sensor *Sensor) validateOutbound() error {
 	return nil
 }
 
-func (sensor *Sensor) scatterOutput(dataMessage *DataMessage) {
+func (sensor *Sensor) scatterOutput(ctx context.Context, dataMessage *DataMessage) (closed bool) {
 
 	if len(dataMessage.Inputs) == 0 {
 		logg.LogPanic("cannot scatter empty data message")
@@ -176,9 +195,14 @@ func (sensor *Sensor) scatterOutput(dataMessage *DataMessage) {
 			outboundConnection.NodeId.UUID, dataMessage)
 		logg.LogTo("NODE_PRE_SEND", logmsg)
 		dataChan := outboundConnection.DataChan
-		dataChan <- dataMessage
+		select {
+		case dataChan <- dataMessage:
+		case <-ctx.Done():
+			return true
+		}
 		logg.LogTo("NODE_POST_SEND", logmsg)
 	}
+	return false
 }
 
 func (sensor *Sensor) nodeId() *NodeId {
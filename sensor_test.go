@@ -1,6 +1,7 @@
 package neurgo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/couchbaselabs/go.assert"
@@ -63,7 +64,7 @@ func TestSensorRun(t *testing.T) {
 		Outbound:       []*OutboundConnection{outboundConnection},
 	}
 	sensor.Init()
-	go sensor.Run()
+	go sensor.Run(context.Background())
 
 	// send it a sync message
 	sensor.SyncChan <- true
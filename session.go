@@ -0,0 +1,512 @@
+package neurgo
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/couchbaselabs/logg"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// A Generational trainer improves a set of candidate cortexes one
+// generation at a time, such as evolve.Population
+type Generational interface {
+
+	// StepGeneration evolves and evaluates the next generation, and
+	// returns its fittest cortex along with the cortex's fitness
+	StepGeneration() (*Cortex, float64, error)
+}
+
+// TrainingSession follows the progress of training, whether epoch by
+// epoch with a TrainingRun or generation by generation with a
+// Generational trainer.  It records the metrics of every epoch or
+// generation in History, calls the callbacks which are set, stops early
+// when its context is cancelled, and writes checkpoints which it can be
+// resumed from.
+type TrainingSession struct {
+
+	// called before each epoch with the number of the epoch
+	OnEpochStart func(epoch int)
+
+	// called after each epoch
+	OnEpochEnd func(metrics *EpochMetrics)
+
+	// called after each generation
+	OnGeneration func(metrics *EpochMetrics)
+
+	// called after any epoch or generation in which the fitness is the
+	// best so far, with the cortex that reached it.  The cortex must
+	// be copied if it is going to be kept, since it can still change.
+	OnImprovement func(metrics *EpochMetrics, cortex *Cortex)
+
+	// if not nil, the (best) cortex is scored on these samples after
+	// every generation, and after every epoch of a TrainingRun without
+	// EarlyStopping
+	Validation []*TrainingSample
+
+	// which sensors and actuators the Validation samples correspond
+	// to, or nil to map them by index.  See SampleMapping.
+	ValidationMapping *SampleMapping
+
+	// how the cortex is scored on the Validation samples, or nil for SSE
+	ValidationObjective Objective
+
+	// if CheckpointInterval is not 0, a checkpoint is written to
+	// CheckpointDir after every CheckpointInterval epochs or generations,
+	// in which case the trainer must be a Checkpointer.  See Resume.
+	CheckpointDir      string
+	CheckpointInterval int
+
+	// number of the most recent checkpoints to keep, or 0 to keep all
+	KeepCheckpoints int
+
+	// the source of the trainer's random numbers, if it draws any.  Its
+	// state is saved in every checkpoint, so that a resumed session
+	// draws the same numbers as one which was never interrupted.
+	RandSource *RandSource
+
+	// the number of epochs or generations completed so far
+	Epoch int
+
+	// the metrics of every epoch or generation so far
+	History MetricsHistory
+
+	// the best fitness so far
+	BestFitness float64
+
+	start   time.Time
+	resumed *Checkpoint
+}
+
+func NewTrainingSession() *TrainingSession {
+	return &TrainingSession{
+		BestFitness: math.Inf(-1),
+	}
+}
+
+// Resume restores the session from the latest checkpoint in dir, so that
+// the next call to Train or Evolve carries on from where the checkpoint
+// was written: from the same epoch or generation, with the same History
+// and BestFitness, with the trainer restored from the checkpoint (as
+// well as the cortex, when training epoch by epoch, so the cortex passed
+// to Train only supplies its Training mode and Noise), and with
+// RandSource in the state it was in.
+// Returns ErrNoCheckpoint if there are no checkpoints in dir.
+func (session *TrainingSession) Resume(dir string) error {
+
+	checkpoint, err := LatestCheckpoint(dir)
+	if err != nil {
+		return err
+	}
+
+	session.Epoch = checkpoint.Epoch
+	session.History = checkpoint.History
+	session.BestFitness = checkpoint.BestFitness
+	if checkpoint.RandSource != nil {
+		if session.RandSource == nil {
+			session.RandSource = &RandSource{}
+		}
+		*session.RandSource = *checkpoint.RandSource
+	}
+	session.resumed = checkpoint
+
+	return nil
+
+}
+
+// Train returns a copy of cortex trained with trainer, as directed by
+// run.  MaxEpochs counts the epochs of the whole session, including any
+// before it was resumed.  If ctx is cancelled, training stops after the
+// current epoch, and the cortex trained so far is returned along with
+// ctx.Err().
+func (session *TrainingSession) Train(ctx context.Context, run *TrainingRun, trainer EpochTrainer, cortex *Cortex, examples []*TrainingSample) (*Cortex, error) {
+
+	checkpointer, err := session.checkpointer(trainer)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *Cortex
+	if resumed := session.takeResumed(); resumed != nil {
+		if resumed.Cortex == nil {
+			return nil, fmt.Errorf("%w: checkpoint has no cortex", ErrNoCheckpoint)
+		}
+		if err := checkpointer.UnmarshalCheckpoint(resumed.Trainer); err != nil {
+			return nil, err
+		}
+		if cortex != nil {
+			resumed.Cortex.Training = cortex.Training
+			resumed.Cortex.Noise = cortex.Noise
+		}
+		cortex = resumed.Cortex
+		best = resumed.Best
+	} else {
+		cortex = cortex.Copy()
+	}
+
+	var setter LearningRateSetter
+	if run.Schedule != nil {
+		var ok bool
+		if setter, ok = trainer.(LearningRateSetter); !ok {
+			return nil, ErrNoLearningRate
+		}
+	}
+
+	// carry on with the early stopping of the epochs before a resume
+	stopper := newEarlyStopper(run.EarlyStopping)
+	run.History = append([]*EpochMetrics{}, session.History...)
+	run.BestEpoch = -1
+	if stopper != nil {
+		for _, metrics := range run.History {
+			stopper.observe(metrics.Epoch, metrics.Validation)
+		}
+		run.BestEpoch = stopper.bestEpoch
+	}
+
+	session.begin()
+
+	for epoch := session.Epoch; run.MaxEpochs <= 0 || epoch < run.MaxEpochs; epoch++ {
+
+		if stopper != nil && stopper.stopped() {
+			break
+		}
+		if run.MaxTime > 0 && time.Since(session.start) >= run.MaxTime {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return cortex, err
+		}
+
+		if session.OnEpochStart != nil {
+			session.OnEpochStart(epoch)
+		}
+
+		metrics := &EpochMetrics{Epoch: epoch}
+		if setter != nil {
+			metrics.LearningRate = run.Schedule(epoch)
+			setter.SetLearningRate(metrics.LearningRate)
+		}
+
+		loss, err := trainer.TrainEpoch(cortex, examples)
+		if err != nil {
+			return nil, err
+		}
+		metrics.Loss = loss
+		metrics.Fitness = SafeScalarInverse(loss)
+
+		if stopper != nil {
+			stopping := stopper.EarlyStopping
+			validation, err := cortex.EvaluateMapped(stopping.Samples, stopping.Mapping, stopper.objective)
+			if err != nil {
+				return nil, err
+			}
+			metrics.Validation = validation
+			if stopper.observe(epoch, validation) && stopping.RestoreBest {
+				best = cortex.Copy()
+			}
+			run.BestEpoch = stopper.bestEpoch
+		} else if err := session.validate(metrics, cortex); err != nil {
+			return nil, err
+		}
+
+		session.record(metrics, cortex)
+		run.History = append(run.History, metrics)
+		if run.OnEpoch != nil {
+			run.OnEpoch(metrics)
+		}
+		if session.OnEpochEnd != nil {
+			session.OnEpochEnd(metrics)
+		}
+
+		if err := session.checkpoint(checkpointer, cortex, best); err != nil {
+			return nil, err
+		}
+
+	}
+
+	if best != nil {
+		return best, nil
+	}
+	return cortex, nil
+
+}
+
+// Evolve steps generational until maxGenerations have been evolved (or
+// forever, if it is 0) or a generation reaches targetFitness (if it is
+// not 0), and returns the fittest cortex found.  maxGenerations counts
+// the generations of the whole session, including any before it was
+// resumed.  If ctx is cancelled, evolution stops after the current
+// generation, and the fittest cortex so far is returned along with
+// ctx.Err().
+func (session *TrainingSession) Evolve(ctx context.Context, generational Generational, maxGenerations int, targetFitness float64) (*Cortex, error) {
+
+	checkpointer, err := session.checkpointer(generational)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *Cortex
+	if resumed := session.takeResumed(); resumed != nil {
+		if err := checkpointer.UnmarshalCheckpoint(resumed.Trainer); err != nil {
+			return nil, err
+		}
+		best = resumed.Best
+	}
+
+	session.begin()
+
+	for generation := session.Epoch; maxGenerations <= 0 || generation < maxGenerations; generation++ {
+
+		if targetFitness != 0 && session.BestFitness >= targetFitness {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return best, err
+		}
+
+		cortex, fitness, err := generational.StepGeneration()
+		if err != nil {
+			return nil, err
+		}
+		if best == nil || fitness > session.BestFitness {
+			best = cortex
+		}
+
+		metrics := &EpochMetrics{
+			Epoch:   generation,
+			Loss:    SafeScalarInverse(fitness),
+			Fitness: fitness,
+		}
+		if err := session.validate(metrics, cortex); err != nil {
+			return nil, err
+		}
+
+		session.record(metrics, cortex)
+		if session.OnGeneration != nil {
+			session.OnGeneration(metrics)
+		}
+
+		if err := session.checkpoint(checkpointer, nil, best); err != nil {
+			return nil, err
+		}
+
+	}
+
+	return best, nil
+
+}
+
+// start timing, carrying on from the time of the last recorded epoch
+func (session *TrainingSession) begin() {
+	session.start = time.Now()
+	if n := len(session.History); n > 0 {
+		session.start = session.start.Add(-session.History[n-1].Elapsed)
+	}
+}
+
+func (session *TrainingSession) takeResumed() *Checkpoint {
+	resumed := session.resumed
+	session.resumed = nil
+	return resumed
+}
+
+// the trainer as a Checkpointer, which it must be if the session writes
+// checkpoints or is being resumed
+func (session *TrainingSession) checkpointer(trainer interface{}) (Checkpointer, error) {
+	checkpointer, ok := trainer.(Checkpointer)
+	if !ok && (session.CheckpointInterval > 0 || session.resumed != nil) {
+		return nil, fmt.Errorf("%w: %T", ErrNotCheckpointable, trainer)
+	}
+	return checkpointer, nil
+}
+
+// write a checkpoint if one is due, and remove the oldest ones
+func (session *TrainingSession) checkpoint(checkpointer Checkpointer, cortex, best *Cortex) error {
+
+	if session.CheckpointInterval <= 0 || session.Epoch%session.CheckpointInterval != 0 {
+		return nil
+	}
+
+	state, err := checkpointer.MarshalCheckpoint()
+	if err != nil {
+		return err
+	}
+	path, err := WriteCheckpoint(session.CheckpointDir, &Checkpoint{
+		Epoch:       session.Epoch,
+		BestFitness: session.BestFitness,
+		History:     session.History,
+		RandSource:  session.RandSource,
+		Cortex:      cortex,
+		Best:        best,
+		Trainer:     state,
+	})
+	if err != nil {
+		return err
+	}
+	logg.LogTo("DEBUG", "Wrote checkpoint %v", path)
+
+	if session.KeepCheckpoints > 0 {
+		paths, err := Checkpoints(session.CheckpointDir)
+		if err != nil {
+			return err
+		}
+		for len(paths) > session.KeepCheckpoints {
+			if err := os.RemoveAll(paths[0]); err != nil {
+				return err
+			}
+			paths = paths[1:]
+		}
+	}
+
+	return nil
+
+}
+
+func (session *TrainingSession) validate(metrics *EpochMetrics, cortex *Cortex) error {
+	if session.Validation == nil {
+		return nil
+	}
+	objective := session.ValidationObjective
+	if objective == nil {
+		objective = SSE{}
+	}
+	validation, err := cortex.EvaluateMapped(session.Validation, session.ValidationMapping, objective)
+	metrics.Validation = validation
+	return err
+}
+
+// fill in the rest of metrics, add them to the History, and call
+// OnImprovement if the fitness improved
+func (session *TrainingSession) record(metrics *EpochMetrics, cortex *Cortex) {
+	metrics.Elapsed = time.Since(session.start)
+	metrics.Parameters = len(cortex.Parameters())
+	session.History = append(session.History, metrics)
+	session.Epoch = metrics.Epoch + 1
+	if len(session.History) == 1 || metrics.Fitness > session.BestFitness {
+		session.BestFitness = metrics.Fitness
+		if session.OnImprovement != nil {
+			session.OnImprovement(metrics, cortex)
+		}
+	}
+}
+
+// MetricsHistory is the metrics of a series of epochs or generations
+type MetricsHistory []*EpochMetrics
+
+var metricsColumns = []string{
+	"epoch",
+	"learning_rate",
+	"loss",
+	"fitness",
+	"validation",
+	"elapsed_seconds",
+	"parameters",
+}
+
+// WriteCSV writes the history as CSV, with a header row
+func (history MetricsHistory) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(metricsColumns); err != nil {
+		return err
+	}
+	formatFloat := func(x float64) string {
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	for _, metrics := range history {
+		record := []string{
+			strconv.Itoa(metrics.Epoch),
+			formatFloat(metrics.LearningRate),
+			formatFloat(metrics.Loss),
+			formatFloat(metrics.Fitness),
+			formatFloat(metrics.Validation),
+			formatFloat(metrics.Elapsed.Seconds()),
+			strconv.Itoa(metrics.Parameters),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes the history as a JSON array
+func (history MetricsHistory) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(history)
+}
+
+// EpochMetrics are encoded with the same field names as the struct,
+// except that infinite and NaN values (such as the fitness of a cortex
+// which fits its samples exactly) are encoded as the strings "+Inf",
+// "-Inf" and "NaN", since JSON has no numbers for them.
+func (metrics *EpochMetrics) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encodableMetrics{
+		Epoch:        metrics.Epoch,
+		LearningRate: EncodableFloat(metrics.LearningRate),
+		Loss:         EncodableFloat(metrics.Loss),
+		Fitness:      EncodableFloat(metrics.Fitness),
+		Validation:   EncodableFloat(metrics.Validation),
+		Elapsed:      metrics.Elapsed,
+		Parameters:   metrics.Parameters,
+	})
+}
+
+func (metrics *EpochMetrics) UnmarshalJSON(bytes []byte) error {
+	encodable := encodableMetrics{}
+	if err := json.Unmarshal(bytes, &encodable); err != nil {
+		return err
+	}
+	*metrics = EpochMetrics{
+		Epoch:        encodable.Epoch,
+		LearningRate: float64(encodable.LearningRate),
+		Loss:         float64(encodable.Loss),
+		Fitness:      float64(encodable.Fitness),
+		Validation:   float64(encodable.Validation),
+		Elapsed:      encodable.Elapsed,
+		Parameters:   encodable.Parameters,
+	}
+	return nil
+}
+
+type encodableMetrics struct {
+	Epoch        int
+	LearningRate EncodableFloat
+	Loss         EncodableFloat
+	Fitness      EncodableFloat
+	Validation   EncodableFloat
+	Elapsed      time.Duration
+	Parameters   int
+}
+
+// EncodableFloat is a float64 which can be saved as JSON even if it is
+// infinite or NaN, which are saved as the strings "+Inf", "-Inf" and
+// "NaN".
+type EncodableFloat float64
+
+func (x EncodableFloat) MarshalJSON() ([]byte, error) {
+	f := float64(x)
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return json.Marshal(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return json.Marshal(f)
+}
+
+func (x *EncodableFloat) UnmarshalJSON(bytes []byte) error {
+	var f float64
+	if err := json.Unmarshal(bytes, &f); err == nil {
+		*x = EncodableFloat(f)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return err
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	*x = EncodableFloat(f)
+	return err
+}
@@ -0,0 +1,140 @@
+package neurgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestTrainingSessionCallbacks(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	session := NewTrainingSession()
+	session.Validation = examples
+
+	started, ended := 0, 0
+	session.OnEpochStart = func(epoch int) {
+		assert.Equals(t, epoch, started)
+		started += 1
+	}
+	session.OnEpochEnd = func(metrics *EpochMetrics) {
+		assert.Equals(t, metrics.Epoch, ended)
+		ended += 1
+	}
+	improvements := make([]float64, 0)
+	session.OnImprovement = func(metrics *EpochMetrics, cortex *Cortex) {
+		improvements = append(improvements, metrics.Fitness)
+	}
+
+	run := NewTrainingRun(50)
+	_, err := session.Train(context.Background(), run, NewBackpropTrainer(), SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, err == nil)
+
+	assert.Equals(t, started, 50)
+	assert.Equals(t, ended, 50)
+	assert.Equals(t, len(session.History), 50)
+	assert.True(t, len(improvements) > 1)
+	for i := 1; i < len(improvements); i++ {
+		assert.True(t, improvements[i] > improvements[i-1])
+	}
+	assert.Equals(t, session.BestFitness, improvements[len(improvements)-1])
+
+	for _, metrics := range session.History {
+		assert.Equals(t, metrics.Parameters, 9)
+		assert.Equals(t, metrics.Fitness, 1/metrics.Loss)
+		assert.True(t, metrics.Validation > 0)
+	}
+
+}
+
+func TestTrainingSessionCancel(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session := NewTrainingSession()
+	session.OnEpochEnd = func(metrics *EpochMetrics) {
+		if metrics.Epoch == 4 {
+			cancel()
+		}
+	}
+
+	run := NewTrainingRun(0)
+	trained, err := session.Train(ctx, run, NewBackpropTrainer(), SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), XnorTrainingSamples())
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, trained != nil)
+	assert.Equals(t, len(session.History), 5)
+
+}
+
+// a Generational whose fitness goes up by one every generation
+type countingGenerations struct {
+	generation int
+}
+
+func (generations *countingGenerations) StepGeneration() (*Cortex, float64, error) {
+	generations.generation += 1
+	return XnorCortex(), float64(generations.generation), nil
+}
+
+func TestTrainingSessionEvolve(t *testing.T) {
+
+	session := NewTrainingSession()
+	generations := 0
+	session.OnGeneration = func(metrics *EpochMetrics) {
+		assert.Equals(t, metrics.Epoch, generations)
+		generations += 1
+	}
+	improvements := 0
+	session.OnImprovement = func(metrics *EpochMetrics, cortex *Cortex) {
+		improvements += 1
+	}
+
+	best, err := session.Evolve(context.Background(), &countingGenerations{}, 100, 10)
+	assert.True(t, err == nil)
+	assert.True(t, best != nil)
+	assert.Equals(t, generations, 10)
+	assert.Equals(t, improvements, 10)
+	assert.Equals(t, session.BestFitness, 10.0)
+	assert.Equals(t, session.History[9].Loss, 0.1)
+
+	// cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	best, err = NewTrainingSession().Evolve(ctx, &countingGenerations{}, 100, 0)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, best == nil)
+
+}
+
+func TestMetricsHistoryExport(t *testing.T) {
+
+	history := MetricsHistory{
+		{Epoch: 0, LearningRate: 0.1, Loss: 2, Fitness: 0.5, Elapsed: 1500000000, Parameters: 9},
+		{Epoch: 1, Loss: 0, Fitness: math.Inf(1), Validation: 0.25, Parameters: 9},
+	}
+
+	buffer := &bytes.Buffer{}
+	assert.True(t, history.WriteCSV(buffer) == nil)
+	records, err := csv.NewReader(strings.NewReader(buffer.String())).ReadAll()
+	assert.True(t, err == nil)
+	assert.Equals(t, len(records), 3)
+	assert.Equals(t, strings.Join(records[0], ","), "epoch,learning_rate,loss,fitness,validation,elapsed_seconds,parameters")
+	assert.Equals(t, strings.Join(records[1], ","), "0,0.1,2,0.5,0,1.5,9")
+	assert.Equals(t, records[2][3], "+Inf")
+
+	buffer.Reset()
+	assert.True(t, history.WriteJSON(buffer) == nil)
+	decoded := MetricsHistory{}
+	assert.True(t, json.Unmarshal(buffer.Bytes(), &decoded) == nil)
+	assert.Equals(t, len(decoded), len(history))
+	for i, metrics := range history {
+		assert.Equals(t, *decoded[i], *metrics)
+	}
+
+}
@@ -0,0 +1,101 @@
+package neurgo
+
+// Step feeds one set of inputs through the cortex and returns the
+// resulting actuator outputs.  Inputs are keyed by sensor UUID and
+// outputs by actuator UUID.  Unlike running the cortex with Start,
+// this does not touch the SensorFunction or ActuatorFunction of any
+// node, so the cortex can be called like an ordinary function.
+//
+// The cortex is compiled into an EvaluationPlan on the first call, and
+// recurrent connections carry their values from one Step to the next.
+// Call ResetStep to clear that state, and after changing the topology
+// of the cortex.  Step is not safe for concurrent use -- give each
+// goroutine its own Copy of the cortex.
+func (cortex *Cortex) Step(inputs map[string][]float64) (map[string][]float64, error) {
+
+	if cortex.stepPlan == nil {
+		plan, err := cortex.Compile()
+		if err != nil {
+			return nil, err
+		}
+		cortex.stepPlan = plan
+	}
+
+	return cortex.stepPlan.Step(inputs)
+
+}
+
+// StepBatch calls Step for each set of inputs in turn, so any recurrent
+// state flows from one element of the batch to the next.
+func (cortex *Cortex) StepBatch(batch []map[string][]float64) ([]map[string][]float64, error) {
+
+	results := make([]map[string][]float64, len(batch))
+	for i, inputs := range batch {
+		outputs, err := cortex.Step(inputs)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = outputs
+	}
+	return results, nil
+
+}
+
+// ResetStep discards the plan and recurrent state used by Step.
+func (cortex *Cortex) ResetStep() {
+	cortex.stepPlan = nil
+}
+
+// Step is the same as Evaluate, but with inputs keyed by sensor UUID
+// and outputs keyed by actuator UUID.
+func (plan *EvaluationPlan) Step(inputs map[string][]float64) (map[string][]float64, error) {
+
+	orderedInputs, err := plan.orderInputs(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	orderedOutputs, err := plan.Evaluate(orderedInputs)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make(map[string][]float64, len(orderedOutputs))
+	for i, plannedActuator := range plan.actuators {
+		outputs[plannedActuator.actuator.NodeId.UUID] = orderedOutputs[i]
+	}
+	return outputs, nil
+
+}
+
+func (plan *EvaluationPlan) orderInputs(inputs map[string][]float64) ([][]float64, error) {
+
+	orderedInputs := make([][]float64, len(plan.sensors))
+	for i, sensor := range plan.sensors {
+		input, ok := inputs[sensor.NodeId.UUID]
+		if !ok {
+			return nil, newNodeError(sensor.NodeId, ErrMissingSensorInput)
+		}
+		orderedInputs[i] = input
+	}
+
+	if len(inputs) != len(plan.sensors) {
+		for uuid := range inputs {
+			if !plan.hasSensor(uuid) {
+				return nil, newNodeError(&NodeId{UUID: uuid}, ErrUnknownSensor)
+			}
+		}
+	}
+
+	return orderedInputs, nil
+
+}
+
+func (plan *EvaluationPlan) hasSensor(uuid string) bool {
+	for _, sensor := range plan.sensors {
+		if sensor.NodeId.UUID == uuid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package neurgo
+
+import (
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"testing"
+)
+
+func TestStep(t *testing.T) {
+
+	xnorCortex := XnorCortex()
+	xnorCortex.Sensors[0].SensorFunction = nil
+	xnorCortex.Actuators[0].ActuatorFunction = nil
+
+	for _, sample := range XnorTrainingSamples() {
+		inputs := map[string][]float64{"sensor": sample.SampleInputs[0]}
+		outputs, err := xnorCortex.Step(inputs)
+		assert.True(t, err == nil)
+		assert.Equals(t, len(outputs), 1)
+		output := outputs["actuator"]
+		expected := sample.ExpectedOutputs[0]
+		assert.True(t, vectorEqualsWithMaxDelta(output, expected, 0.01))
+	}
+
+	// make sure the node callbacks were left alone
+	assert.True(t, xnorCortex.Sensors[0].SensorFunction == nil)
+	assert.True(t, xnorCortex.Actuators[0].ActuatorFunction == nil)
+
+}
+
+func TestStepBatch(t *testing.T) {
+
+	cortex := backEdgeRecurrentCortex()
+	ticks := backEdgeRecurrentInputs()
+
+	batch := make([]map[string][]float64, len(ticks))
+	for i, inputs := range ticks {
+		batch[i] = map[string][]float64{
+			"sensor1": inputs[0],
+			"sensor2": inputs[1],
+		}
+	}
+
+	results, err := cortex.StepBatch(batch)
+	assert.True(t, err == nil)
+
+	expected := runEvaluationPlan(t, cortex, ticks)
+	actual := make([][][]float64, len(results))
+	for i, outputs := range results {
+		actual[i] = [][]float64{outputs["actuator1"], outputs["actuator2"]}
+	}
+	assertIdenticalOutputs(t, expected, actual)
+
+	// recurrent state carries over between steps until reset
+	next, err := cortex.Step(batch[0])
+	assert.True(t, err == nil)
+	assert.True(t, next["actuator1"][0] != results[0]["actuator1"][0])
+
+	cortex.ResetStep()
+	next, err = cortex.Step(batch[0])
+	assert.True(t, err == nil)
+	assert.True(t, next["actuator1"][0] == results[0]["actuator1"][0])
+
+}
+
+func TestStepInvalidInputs(t *testing.T) {
+
+	xnorCortex := XnorCortex()
+
+	_, err := xnorCortex.Step(map[string][]float64{})
+	assert.True(t, errors.Is(err, ErrMissingSensorInput))
+
+	inputs := map[string][]float64{
+		"sensor": []float64{0, 1},
+		"bogus":  []float64{1},
+	}
+	_, err = xnorCortex.Step(inputs)
+	assert.True(t, errors.Is(err, ErrUnknownSensor))
+
+	var nodeErr *NodeError
+	assert.True(t, errors.As(err, &nodeErr))
+	assert.Equals(t, nodeErr.NodeId.UUID, "bogus")
+
+}
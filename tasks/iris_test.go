@@ -0,0 +1,35 @@
+package tasks
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"testing"
+)
+
+func TestIris(t *testing.T) {
+
+	task := Iris()
+	assert.Equals(t, len(task.Samples), 150)
+
+	counts := make([]int, 3)
+	for _, sample := range task.Samples {
+		for _, value := range sample.SampleInputs[0] {
+			assert.True(t, value >= 0 && value <= 1)
+		}
+		for class, value := range sample.ExpectedOutputs[0] {
+			if value == 1 {
+				counts[class] += 1
+			}
+		}
+	}
+	for _, count := range counts {
+		assert.Equals(t, count, 50)
+	}
+
+	// the first flower is a setosa with sepals 5.1cm long, and the
+	// shortest sepals in the dataset are 4.3cm, the longest 7.9cm
+	first := task.Samples[0]
+	assert.Equals(t, first.ExpectedOutputs[0][0], 1.0)
+	assert.True(t, ng.EqualsWithMaxDelta(first.SampleInputs[0][0], (5.1-4.3)/(7.9-4.3), 1e-12))
+
+}
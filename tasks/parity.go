@@ -0,0 +1,40 @@
+package tasks
+
+import (
+	"fmt"
+	ng "github.com/maxxk/neurgo"
+)
+
+// Parity is the N-bit parity task: given n inputs which are each 0 or 1,
+// output 1 if an odd number of them are 1, and 0 otherwise.  The inputs
+// are fed into a single sensor, in every one of the 2^n combinations.
+// Parity(2) is XOR, the negation of the XNOR example.  Solved when every
+// sample is classified correctly.
+func Parity(n int) *Task {
+
+	samples := []*ng.TrainingSample{}
+	for bits := 0; bits < 1<<uint(n); bits++ {
+		inputs := make([]float64, n)
+		ones := 0
+		for i := range inputs {
+			if bits&(1<<uint(i)) != 0 {
+				inputs[i] = 1
+				ones += 1
+			}
+		}
+		samples = append(samples, &ng.TrainingSample{
+			SampleInputs:    [][]float64{inputs},
+			ExpectedOutputs: [][]float64{{float64(ones % 2)}},
+		})
+	}
+
+	return &Task{
+		Name:            fmt.Sprintf("%d-bit parity", n),
+		SensorLengths:   []int{n},
+		ActuatorLengths: []int{1},
+		Samples:         samples,
+		Objective:       ng.Accuracy{Threshold: 0.5},
+		SolvedThreshold: 1.0,
+	}
+
+}
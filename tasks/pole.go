@@ -0,0 +1,196 @@
+package tasks
+
+import (
+	ng "github.com/maxxk/neurgo"
+	"math"
+)
+
+const (
+	gravity        = -9.8
+	cartMass       = 1.0
+	poleFriction   = 0.000002
+	forceMagnitude = 10.0
+	trackLimit     = 2.4
+	timeStep       = 0.01
+)
+
+// CartPole simulates poles hinged to a cart on a track, with the
+// equations of motion from Wieland, "Evolving Neural Network Controllers
+// for Unstable Systems", integrated with Runge-Kutta.  On every step the
+// cortex observes the scaled position and velocity of the cart and the
+// angle and angular velocity of each pole, and pushes the cart with a
+// force of 10N times (2 * output - 1).  The cortex earns a reward of 1
+// for every step on which the cart stays on the track and the poles stay
+// up.
+type CartPole struct {
+
+	// half the length, and the mass, of each pole
+	PoleLengths []float64
+	PoleMasses  []float64
+
+	// the angle of each pole at the start of an episode, in radians
+	InitialAngles []float64
+
+	// the episode fails once a pole leans further than this, in radians
+	FailureAngle float64
+
+	// the episode succeeds after this many steps
+	MaxSteps int
+
+	// [x, x', angle 1, angle 1', angle 2, angle 2', ...]
+	state  []float64
+	steps  int
+	failed bool
+}
+
+// SinglePole is the task of balancing a 1m pole on a cart for maxSteps
+// steps of 0.01s, without it leaning more than 12 degrees.
+func SinglePole(maxSteps int) *Task {
+	return poleBalancing("single pole balancing", maxSteps, func() *CartPole {
+		return &CartPole{
+			PoleLengths:   []float64{0.5},
+			PoleMasses:    []float64{0.1},
+			InitialAngles: []float64{0.07},
+			FailureAngle:  12 * math.Pi / 180,
+			MaxSteps:      maxSteps,
+		}
+	})
+}
+
+// DoublePole is the task of balancing a 1m pole and a 0.1m pole side by
+// side on the same cart for maxSteps steps of 0.01s, without either of
+// them leaning more than 36 degrees.
+func DoublePole(maxSteps int) *Task {
+	return poleBalancing("double pole balancing", maxSteps, func() *CartPole {
+		return &CartPole{
+			PoleLengths:   []float64{0.5, 0.05},
+			PoleMasses:    []float64{0.1, 0.01},
+			InitialAngles: []float64{0.07, 0},
+			FailureAngle:  36 * math.Pi / 180,
+			MaxSteps:      maxSteps,
+		}
+	})
+}
+
+func poleBalancing(name string, maxSteps int, newCartPole func() *CartPole) *Task {
+	runner := ng.NewEpisodeRunner()
+	runner.MaxSteps = maxSteps
+	runner.Compiled = true
+	numPoles := len(newCartPole().PoleLengths)
+	return &Task{
+		Name:            name,
+		SensorLengths:   []int{2 + 2*numPoles},
+		ActuatorLengths: []int{1},
+		NewEnvironment: func() ng.Environment {
+			return newCartPole()
+		},
+		Runner:          runner,
+		SolvedThreshold: float64(maxSteps),
+	}
+}
+
+func (cartPole *CartPole) Reset() {
+	cartPole.state = make([]float64, 2+2*len(cartPole.PoleLengths))
+	for i, angle := range cartPole.InitialAngles {
+		cartPole.state[2+2*i] = angle
+	}
+	cartPole.steps = 0
+	cartPole.failed = false
+}
+
+func (cartPole *CartPole) Observe(sensor int) []float64 {
+	observation := []float64{
+		cartPole.state[0] / (2 * trackLimit),
+		cartPole.state[1] / 2,
+	}
+	for i := range cartPole.PoleLengths {
+		observation = append(observation,
+			cartPole.state[2+2*i]/cartPole.FailureAngle,
+			cartPole.state[3+2*i]/2)
+	}
+	return observation
+}
+
+func (cartPole *CartPole) Act(actuator int, outputs []float64) {
+
+	force := (2*ng.Saturate(outputs[0], 0, 1) - 1) * forceMagnitude
+
+	// fourth order Runge-Kutta
+	state := cartPole.state
+	k1 := cartPole.derivatives(state, force)
+	k2 := cartPole.derivatives(addScaled(state, k1, timeStep/2), force)
+	k3 := cartPole.derivatives(addScaled(state, k2, timeStep/2), force)
+	k4 := cartPole.derivatives(addScaled(state, k3, timeStep), force)
+	for i := range state {
+		state[i] += timeStep / 6 * (k1[i] + 2*k2[i] + 2*k3[i] + k4[i])
+	}
+
+	cartPole.steps += 1
+	if math.Abs(state[0]) > trackLimit {
+		cartPole.failed = true
+	}
+	for i := range cartPole.PoleLengths {
+		if math.Abs(state[2+2*i]) > cartPole.FailureAngle {
+			cartPole.failed = true
+		}
+	}
+
+}
+
+func (cartPole *CartPole) Reward() float64 {
+	if cartPole.failed {
+		return 0
+	}
+	return 1
+}
+
+func (cartPole *CartPole) Done() bool {
+	return cartPole.failed || cartPole.steps >= cartPole.MaxSteps
+}
+
+// Failed is true if the cart has left the track or a pole has fallen
+func (cartPole *CartPole) Failed() bool {
+	return cartPole.failed
+}
+
+// the time derivative of state
+func (cartPole *CartPole) derivatives(state []float64, force float64) []float64 {
+
+	derivatives := make([]float64, len(state))
+
+	totalForce := force
+	totalMass := cartMass
+	for i, length := range cartPole.PoleLengths {
+		mass := cartPole.PoleMasses[i]
+		angle, angularVelocity := state[2+2*i], state[3+2*i]
+		cos, sin := math.Cos(angle), math.Sin(angle)
+		friction := poleFriction * angularVelocity / (mass * length)
+		totalForce += mass*length*angularVelocity*angularVelocity*sin +
+			0.75*mass*cos*(friction+gravity*sin)
+		totalMass += mass * (1 - 0.75*cos*cos)
+	}
+
+	acceleration := totalForce / totalMass
+	derivatives[0] = state[1]
+	derivatives[1] = acceleration
+
+	for i, length := range cartPole.PoleLengths {
+		mass := cartPole.PoleMasses[i]
+		angle, angularVelocity := state[2+2*i], state[3+2*i]
+		cos, sin := math.Cos(angle), math.Sin(angle)
+		friction := poleFriction * angularVelocity / (mass * length)
+		derivatives[2+2*i] = angularVelocity
+		derivatives[3+2*i] = -0.75 * (acceleration*cos + gravity*sin + friction) / length
+	}
+
+	return derivatives
+
+}
+
+func addScaled(x, y []float64, scale float64) []float64 {
+	sum := make([]float64, len(x))
+	for i := range x {
+		sum[i] = x[i] + scale*y[i]
+	}
+	return sum
+}
@@ -0,0 +1,66 @@
+package tasks
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	"math"
+	"testing"
+)
+
+// run the cart pole with a fixed output until the episode ends
+func pushUntilDone(cartPole *CartPole, output float64) int {
+	cartPole.Reset()
+	steps := 0
+	for !cartPole.Done() {
+		cartPole.Observe(0)
+		cartPole.Act(0, []float64{output})
+		steps += 1
+	}
+	return steps
+}
+
+func TestCartPoleFalls(t *testing.T) {
+
+	for _, task := range []*Task{SinglePole(1000), DoublePole(1000)} {
+
+		cartPole := task.NewEnvironment().(*CartPole)
+
+		// without any force the long pole falls over the way it leans
+		steps := pushUntilDone(cartPole, 0.5)
+		assert.True(t, cartPole.Failed())
+		assert.True(t, steps < 1000)
+		assert.True(t, cartPole.state[2] > 0)
+
+		// simulation is deterministic
+		assert.Equals(t, pushUntilDone(cartPole, 0.5), steps)
+
+		// pushing the cart the other way makes the pole fall sooner
+		assert.True(t, pushUntilDone(cartPole, 0) < steps)
+
+	}
+
+}
+
+func TestCartPoleObservation(t *testing.T) {
+
+	cartPole := DoublePole(1000).NewEnvironment().(*CartPole)
+	cartPole.Reset()
+
+	observation := cartPole.Observe(0)
+	assert.Equals(t, len(observation), 6)
+	assert.Equals(t, observation[0], 0.0)
+	assert.True(t, math.Abs(observation[2]-0.07/cartPole.FailureAngle) < 1e-12)
+
+}
+
+func TestCartPoleReward(t *testing.T) {
+
+	task := SinglePole(10)
+	cartPole := task.NewEnvironment().(*CartPole)
+
+	// with fewer steps than it takes the pole to fall, every step is
+	// rewarded
+	assert.Equals(t, pushUntilDone(cartPole, 0.5), 10)
+	assert.False(t, cartPole.Failed())
+	assert.Equals(t, cartPole.Reward(), 1.0)
+
+}
@@ -0,0 +1,154 @@
+// Package tasks contains self-contained benchmark problems for comparing
+// trainers: N-bit parity, single and double pole balancing, time series
+// prediction and the Iris dataset.  Every task is deterministic, so the
+// same cortex always gets the same score.
+package tasks
+
+import (
+	"fmt"
+	ng "github.com/maxxk/neurgo"
+	"math/rand"
+)
+
+// A Task is either a supervised task, with Samples to learn, or a
+// reinforcement learning task, with an Environment to earn reward from.
+type Task struct {
+	Name string
+
+	// the VectorLength of each sensor and actuator a cortex needs to
+	// attempt the task
+	SensorLengths   []int
+	ActuatorLengths []int
+
+	// the samples of a supervised task
+	Samples []*ng.TrainingSample
+
+	// how a cortex is judged on the Samples to decide whether it has
+	// solved a supervised task, eg Accuracy for classification
+	Objective ng.Objective
+
+	// creates an environment for a reinforcement learning task, which
+	// is run with Runner
+	NewEnvironment func() ng.Environment
+	Runner         *ng.EpisodeRunner
+
+	// the task is solved once the score of a cortex reaches this value,
+	// meaning at least this value if the Objective is maximized or the
+	// task has an environment, and at most this value otherwise
+	SolvedThreshold float64
+}
+
+// Fitness returns a function for trainers to maximize: the fitness of
+// the cortex on the Samples (see ng.SampleFitness) for a supervised
+// task, or the reward earned per episode for a reinforcement learning
+// task.
+func (task *Task) Fitness() ng.FitnessFunc {
+	if task.NewEnvironment != nil {
+		return ng.EnvironmentFitness(task.Runner, task.NewEnvironment)
+	}
+	return ng.SampleFitness(task.Samples, nil)
+}
+
+// Score returns the Objective measured on the Samples for a supervised
+// task, or the reward earned per episode for a reinforcement learning
+// task.
+func (task *Task) Score(cortex *ng.Cortex) (float64, error) {
+	if task.NewEnvironment != nil {
+		return task.Runner.Run(cortex, task.NewEnvironment())
+	}
+	return cortex.Evaluate(task.Samples, task.Objective)
+}
+
+// Solved is true if the Score of the cortex reaches the SolvedThreshold
+func (task *Task) Solved(cortex *ng.Cortex) (bool, error) {
+	score, err := task.Score(cortex)
+	if err != nil {
+		return false, err
+	}
+	if task.NewEnvironment == nil && !task.Objective.Maximize() {
+		return score <= task.SolvedThreshold, nil
+	}
+	return score >= task.SolvedThreshold, nil
+}
+
+// Cortex creates a cortex with the sensors and actuators the task needs,
+// a single layer of hiddenNeurons sigmoid neurons (or none, if
+// hiddenNeurons is 0) fully connected to every sensor, and a sigmoid
+// neuron for each actuator output fully connected to the hidden layer.
+// Weights and biases are drawn uniformly from [-1, 1) using rng.
+func (task *Task) Cortex(hiddenNeurons int, rng *rand.Rand) *ng.Cortex {
+
+	randomWeights := func(length int) []float64 {
+		weights := make([]float64, length)
+		for i := range weights {
+			weights[i] = 2*rng.Float64() - 1
+		}
+		return weights
+	}
+
+	newNeuron := func(uuid string, layerIndex float64) *ng.Neuron {
+		neuron := &ng.Neuron{
+			ActivationFunction: ng.EncodableSigmoid(),
+			NodeId:             ng.NewNeuronId(uuid, layerIndex),
+			Bias:               2*rng.Float64() - 1,
+		}
+		neuron.Init()
+		return neuron
+	}
+
+	sensors := []*ng.Sensor{}
+	for i, length := range task.SensorLengths {
+		sensor := &ng.Sensor{
+			NodeId:       ng.NewSensorId(fmt.Sprintf("sensor-%d", i), 0.0),
+			VectorLength: length,
+		}
+		sensor.Init()
+		sensors = append(sensors, sensor)
+	}
+
+	hidden := []*ng.Neuron{}
+	for i := 0; i < hiddenNeurons; i++ {
+		neuron := newNeuron(fmt.Sprintf("hidden-%d", i), 0.25)
+		for _, sensor := range sensors {
+			sensor.ConnectOutbound(neuron)
+			neuron.ConnectInboundWeighted(sensor, randomWeights(sensor.VectorLength))
+		}
+		hidden = append(hidden, neuron)
+	}
+
+	outputs := []*ng.Neuron{}
+	actuators := []*ng.Actuator{}
+	for i, length := range task.ActuatorLengths {
+		actuator := &ng.Actuator{
+			NodeId:       ng.NewActuatorId(fmt.Sprintf("actuator-%d", i), 1.0),
+			VectorLength: length,
+		}
+		actuator.Init()
+		for j := 0; j < length; j++ {
+			neuron := newNeuron(fmt.Sprintf("output-%d-%d", i, j), 0.5)
+			if len(hidden) == 0 {
+				for _, sensor := range sensors {
+					sensor.ConnectOutbound(neuron)
+					neuron.ConnectInboundWeighted(sensor, randomWeights(sensor.VectorLength))
+				}
+			}
+			for _, hiddenNeuron := range hidden {
+				hiddenNeuron.ConnectOutbound(neuron)
+				neuron.ConnectInboundWeighted(hiddenNeuron, randomWeights(1))
+			}
+			neuron.ConnectOutbound(actuator)
+			actuator.ConnectInbound(neuron)
+			outputs = append(outputs, neuron)
+		}
+		actuators = append(actuators, actuator)
+	}
+
+	cortex := &ng.Cortex{
+		NodeId: ng.NewCortexId(fmt.Sprintf("cortex-%s", ng.NewUuid())),
+	}
+	cortex.SetSensors(sensors)
+	cortex.SetNeurons(append(hidden, outputs...))
+	cortex.SetActuators(actuators)
+	return cortex
+
+}
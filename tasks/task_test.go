@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"math/rand"
+	"testing"
+)
+
+// train the weights of a cortex for the task with CMA-ES until it is
+// solved
+func solve(t *testing.T, task *Task, hiddenNeurons int, targetFitness float64) *ng.Cortex {
+
+	rng := rand.New(rand.NewSource(1))
+	cortex := task.Cortex(hiddenNeurons, rng)
+
+	trainer := ng.NewCMAESTrainer()
+	trainer.Fitness = task.Fitness()
+	trainer.Sigma = 2
+	trainer.TargetFitness = targetFitness
+	trainer.Rand = rng
+
+	trained, err := trainer.Fit(cortex, nil)
+	assert.True(t, err == nil)
+	return trained
+
+}
+
+func TestTaskCortex(t *testing.T) {
+
+	task := Iris()
+	cortex := task.Cortex(5, rand.New(rand.NewSource(1)))
+	assert.Equals(t, len(cortex.Sensors), 1)
+	assert.Equals(t, len(cortex.Neurons), 5+3)
+	assert.Equals(t, len(cortex.Actuators), 1)
+	assert.Equals(t, cortex.Actuators[0].VectorLength, 3)
+	assert.True(t, cortex.ValidateConnections() == nil)
+
+	_, err := task.Score(cortex)
+	assert.True(t, err == nil)
+
+	// without a hidden layer the outputs read the sensors directly
+	cortex = task.Cortex(0, rand.New(rand.NewSource(1)))
+	assert.Equals(t, len(cortex.Neurons), 3)
+	assert.Equals(t, len(cortex.Neurons[0].Inbound[0].Weights), 4)
+
+}
+
+func TestSolveParity(t *testing.T) {
+
+	task := Parity(2)
+	trained := solve(t, task, 2, 100)
+
+	solved, err := task.Solved(trained)
+	assert.True(t, err == nil)
+	assert.True(t, solved)
+
+	// without a hidden layer, xor can't be solved
+	untrained := task.Cortex(0, rand.New(rand.NewSource(1)))
+	solved, err = task.Solved(untrained)
+	assert.True(t, err == nil)
+	assert.False(t, solved)
+
+}
+
+func TestSolveSinglePole(t *testing.T) {
+
+	task := SinglePole(1000)
+	trained := solve(t, task, 0, task.SolvedThreshold)
+
+	solved, err := task.Solved(trained)
+	assert.True(t, err == nil)
+	assert.True(t, solved)
+
+}
@@ -0,0 +1,103 @@
+package tasks
+
+import (
+	"fmt"
+	ng "github.com/maxxk/neurgo"
+	"math"
+)
+
+// Sine is the task of predicting the next value of a sine wave with a
+// period of 20 steps, scaled into [0, 1], from the window previous values.
+// There are numSamples samples, each with the window values fed into a
+// single sensor.  Solved once the mean squared error is at most 0.001.
+func Sine(window, numSamples int) *Task {
+
+	series := make([]float64, window+numSamples)
+	for t := range series {
+		series[t] = 0.5 + 0.5*math.Sin(2*math.Pi*float64(t)/20)
+	}
+
+	samples := []*ng.TrainingSample{}
+	for t := window; t < len(series); t++ {
+		inputs := make([]float64, window)
+		copy(inputs, series[t-window:t])
+		samples = append(samples, &ng.TrainingSample{
+			SampleInputs:    [][]float64{inputs},
+			ExpectedOutputs: [][]float64{{series[t]}},
+		})
+	}
+
+	return &Task{
+		Name:            fmt.Sprintf("sine prediction (window %d)", window),
+		SensorLengths:   []int{window},
+		ActuatorLengths: []int{1},
+		Samples:         samples,
+		Objective:       ng.MSE{},
+		SolvedThreshold: 0.001,
+	}
+
+}
+
+// MackeyGlass is the standard benchmark of predicting the chaotic
+// Mackey-Glass time series (with tau = 17) 6 steps ahead: given x(t-18),
+// x(t-12), x(t-6) and x(t), fed into a single sensor, predict x(t+6).
+// The series is scaled into [0, 1], and there are numSamples samples
+// starting after the initial transient has died away.  Solved once the
+// mean squared error is at most 0.001.
+func MackeyGlass(numSamples int) *Task {
+
+	const (
+		tau       = 17
+		beta      = 0.2
+		gamma     = 0.1
+		exponent  = 10
+		substeps  = 10
+		transient = 1000
+	)
+
+	// integrate with Euler's method, from x(0) = 1.2 and x(t) = 0 for t < 0
+	length := transient + numSamples + 24
+	dt := 1.0 / substeps
+	fine := make([]float64, length*substeps+1)
+	fine[0] = 1.2
+	for i := 0; i+1 < len(fine); i++ {
+		delayed := float64(0)
+		if i >= tau*substeps {
+			delayed = fine[i-tau*substeps]
+		}
+		derivative := beta*delayed/(1+math.Pow(delayed, exponent)) - gamma*fine[i]
+		fine[i+1] = fine[i] + dt*derivative
+	}
+
+	series := make([]float64, length)
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for t := range series {
+		series[t] = fine[t*substeps]
+		if t >= transient {
+			lowest = math.Min(lowest, series[t])
+			highest = math.Max(highest, series[t])
+		}
+	}
+	for t := range series {
+		series[t] = (series[t] - lowest) / (highest - lowest)
+	}
+
+	samples := []*ng.TrainingSample{}
+	for n := 0; n < numSamples; n++ {
+		t := transient + 18 + n
+		samples = append(samples, &ng.TrainingSample{
+			SampleInputs:    [][]float64{{series[t-18], series[t-12], series[t-6], series[t]}},
+			ExpectedOutputs: [][]float64{{series[t+6]}},
+		})
+	}
+
+	return &Task{
+		Name:            "Mackey-Glass prediction",
+		SensorLengths:   []int{4},
+		ActuatorLengths: []int{1},
+		Samples:         samples,
+		Objective:       ng.MSE{},
+		SolvedThreshold: 0.001,
+	}
+
+}
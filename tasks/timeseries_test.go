@@ -0,0 +1,57 @@
+package tasks
+
+import (
+	"github.com/couchbaselabs/go.assert"
+	ng "github.com/maxxk/neurgo"
+	"testing"
+)
+
+func TestSine(t *testing.T) {
+
+	task := Sine(5, 100)
+	assert.Equals(t, len(task.Samples), 100)
+
+	// each sample's expected output is the last input of the next one
+	for n := 0; n+1 < len(task.Samples); n++ {
+		next := task.Samples[n+1].SampleInputs[0]
+		assert.Equals(t, len(next), 5)
+		assert.Equals(t, task.Samples[n].ExpectedOutputs[0][0], next[4])
+	}
+
+	// the period is 20 steps
+	assert.True(t, ng.EqualsWithMaxDelta(
+		task.Samples[0].ExpectedOutputs[0][0],
+		task.Samples[20].ExpectedOutputs[0][0],
+		1e-12))
+
+}
+
+func TestMackeyGlass(t *testing.T) {
+
+	task := MackeyGlass(500)
+	assert.Equals(t, len(task.Samples), 500)
+
+	lowest, highest := 1.0, 0.0
+	for _, sample := range task.Samples {
+		for _, value := range append(sample.SampleInputs[0], sample.ExpectedOutputs[0][0]) {
+			assert.True(t, value >= 0 && value <= 1)
+			if value < lowest {
+				lowest = value
+			}
+			if value > highest {
+				highest = value
+			}
+		}
+	}
+	assert.True(t, highest-lowest > 0.5)
+
+	// x(t+6) of one sample is x(t) of the sample 6 steps later
+	assert.Equals(t, task.Samples[0].ExpectedOutputs[0][0], task.Samples[6].SampleInputs[0][3])
+
+	// the series is deterministic
+	again := MackeyGlass(500)
+	for n, sample := range task.Samples {
+		assert.Equals(t, again.Samples[n].ExpectedOutputs[0][0], sample.ExpectedOutputs[0][0])
+	}
+
+}
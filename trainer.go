@@ -22,3 +22,30 @@ func (t *TrainingSample) String() string {
 type Trainer interface {
 	Train(cortex *Cortex, examples []*TrainingSample) *Cortex
 }
+
+// A FitnessFunc scores a cortex, higher being better.  Trainers which
+// evaluate several candidates at once call it concurrently, on
+// different cortexes.
+type FitnessFunc func(cortex *Cortex) (float64, error)
+
+// SampleFitness returns a FitnessFunc which scores a cortex with
+// cortex.FitnessMapped(samples, mapping)
+func SampleFitness(samples []*TrainingSample, mapping *SampleMapping) FitnessFunc {
+	return func(cortex *Cortex) (float64, error) {
+		return cortex.FitnessMapped(samples, mapping)
+	}
+}
+
+// ObjectiveFitness returns a FitnessFunc which scores a cortex with
+// cortex.EvaluateMapped(samples, mapping, objective).  If lower scores
+// are better the fitness is the inverse of the score, as with
+// cortex.Fitness, so that it can be maximized.
+func ObjectiveFitness(samples []*TrainingSample, mapping *SampleMapping, objective Objective) FitnessFunc {
+	return func(cortex *Cortex) (float64, error) {
+		score, err := cortex.EvaluateMapped(samples, mapping, objective)
+		if err != nil || objective.Maximize() {
+			return score, err
+		}
+		return SafeScalarInverse(score), nil
+	}
+}
@@ -0,0 +1,155 @@
+package neurgo
+
+import (
+	"context"
+	"time"
+)
+
+// An EpochTrainer is a Trainer which trains in epochs, each one a single
+// pass over the training samples, so that a TrainingRun can control it
+// from one epoch to the next.
+type EpochTrainer interface {
+	Trainer
+
+	// TrainEpoch trains cortex in place for a single epoch, and returns
+	// the training loss measured during the epoch
+	TrainEpoch(cortex *Cortex, examples []*TrainingSample) (float64, error)
+}
+
+// EpochMetrics describes a single epoch of a TrainingRun, or a single
+// generation evolved by a TrainingSession
+type EpochMetrics struct {
+
+	// the number of the epoch or generation, starting from 0
+	Epoch int
+
+	// the learning rate given by the Schedule, or 0 if there isn't one
+	LearningRate float64
+
+	// the training loss returned by TrainEpoch, or for a generation the
+	// inverse of Fitness
+	Loss float64
+
+	// the fitness of the best cortex of a generation, or for an epoch the
+	// inverse of Loss
+	Fitness float64
+
+	// the score on the EarlyStopping samples, or on the samples of the
+	// TrainingSession's Validation, or 0 if there aren't any
+	Validation float64
+
+	// time since the start of the run
+	Elapsed time.Duration
+
+	// number of weights and biases in the (best) cortex
+	Parameters int
+}
+
+// EarlyStopping stops a TrainingRun once the cortex stops improving on a
+// validation set, which should be separate from the training samples.
+type EarlyStopping struct {
+	Samples []*TrainingSample
+
+	// which sensors and actuators the samples correspond to, or nil to
+	// map them by index.  See SampleMapping.
+	Mapping *SampleMapping
+
+	// how the cortex is scored on the samples, or nil for SSE
+	Objective Objective
+
+	// stop after this many epochs in a row without improvement
+	Patience int
+
+	// only changes in the score bigger than this count as improvement
+	MinDelta float64
+
+	// return the cortex from the epoch with the best score, rather than
+	// the one from the last epoch
+	RestoreBest bool
+}
+
+// TrainingRun trains a cortex with an EpochTrainer epoch by epoch, until
+// it runs out of epochs or time, or stops improving.  Set at least one
+// of MaxEpochs, MaxTime or EarlyStopping, or the run never ends.
+type TrainingRun struct {
+
+	// maximum number of epochs, or 0 for no limit
+	MaxEpochs int
+
+	// maximum time to train for, or 0 for no limit.  The epoch which is
+	// running when the time is up is allowed to finish.
+	MaxTime time.Duration
+
+	// if not nil, the learning rate of the trainer (which must be a
+	// LearningRateSetter) is set from the schedule before each epoch
+	Schedule LearningRateSchedule
+
+	EarlyStopping *EarlyStopping
+
+	// if not nil, called after every epoch
+	OnEpoch func(metrics *EpochMetrics)
+
+	// the metrics of every epoch of the latest call to Train
+	History []*EpochMetrics
+
+	// the epoch with the best validation score, if there is EarlyStopping
+	BestEpoch int
+}
+
+// the state of the EarlyStopping of a TrainingRun
+type earlyStopper struct {
+	*EarlyStopping
+	objective        Objective
+	bestEpoch        int
+	bestValidation   float64
+	sinceImprovement int
+}
+
+// returns nil if stopping is nil
+func newEarlyStopper(stopping *EarlyStopping) *earlyStopper {
+	if stopping == nil {
+		return nil
+	}
+	stopper := &earlyStopper{
+		EarlyStopping: stopping,
+		objective:     stopping.Objective,
+		bestEpoch:     -1,
+	}
+	if stopper.objective == nil {
+		stopper.objective = SSE{}
+	}
+	return stopper
+}
+
+// observe the validation score of an epoch, and return whether it is the
+// best so far
+func (stopper *earlyStopper) observe(epoch int, validation float64) bool {
+	improvement := validation - stopper.bestValidation
+	if !stopper.objective.Maximize() {
+		improvement = -improvement
+	}
+	if stopper.bestEpoch == -1 || improvement > stopper.MinDelta {
+		stopper.bestEpoch = epoch
+		stopper.bestValidation = validation
+		stopper.sinceImprovement = 0
+		return true
+	}
+	stopper.sinceImprovement += 1
+	return false
+}
+
+func (stopper *earlyStopper) stopped() bool {
+	return stopper.bestEpoch != -1 && stopper.sinceImprovement >= stopper.Patience
+}
+
+func NewTrainingRun(maxEpochs int) *TrainingRun {
+	return &TrainingRun{
+		MaxEpochs: maxEpochs,
+	}
+}
+
+// Train returns a trained copy of cortex.  Use a TrainingSession to
+// follow the progress of the run, or to cancel it.
+func (run *TrainingRun) Train(trainer EpochTrainer, cortex *Cortex, examples []*TrainingSample) (*Cortex, error) {
+	return NewTrainingSession().Train(context.Background(), run, trainer, cortex, examples)
+}
@@ -0,0 +1,119 @@
+package neurgo
+
+import (
+	"errors"
+	"github.com/couchbaselabs/go.assert"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestTrainingRunSchedule(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	trainer := NewBackpropTrainer()
+	trainer.Optimizer = NewMomentum(trainer.LearningRate, 0.9)
+
+	run := NewTrainingRun(500)
+	run.Schedule = CosineAnnealing(0.5, 0.05, 500)
+	epochs := 0
+	run.OnEpoch = func(metrics *EpochMetrics) {
+		assert.Equals(t, metrics.Epoch, epochs)
+		epochs += 1
+	}
+
+	trained, err := run.Train(trainer, SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, err == nil)
+	assert.Equals(t, epochs, 500)
+	assert.Equals(t, len(run.History), 500)
+
+	// the schedule is applied to the optimizer as well as the trainer
+	last := run.History[len(run.History)-1]
+	assert.Equals(t, last.LearningRate, run.Schedule(499))
+	assert.Equals(t, trainer.LearningRate, last.LearningRate)
+	assert.Equals(t, trainer.Optimizer.(*Momentum).LearningRate, last.LearningRate)
+
+	assert.True(t, last.Loss < run.History[0].Loss)
+	fitness, err := trained.Fitness(examples)
+	assert.True(t, err == nil)
+	assert.True(t, fitness > 10)
+
+}
+
+func TestTrainingRunEarlyStopping(t *testing.T) {
+
+	examples := XnorTrainingSamples()
+	trainer := NewBackpropTrainer()
+
+	// training with a learning rate this high diverges after a while, so
+	// the validation score gets worse
+	run := NewTrainingRun(100)
+	run.Schedule = func(epoch int) float64 {
+		if epoch < 5 {
+			return 0.5
+		}
+		return 1000
+	}
+	run.EarlyStopping = &EarlyStopping{
+		Samples:     examples,
+		Patience:    3,
+		RestoreBest: true,
+	}
+
+	trained, err := run.Train(trainer, SeededXnorCortexUntrained(rand.New(rand.NewSource(2))), examples)
+	assert.True(t, err == nil)
+	assert.True(t, len(run.History) < 100)
+	assert.Equals(t, len(run.History), run.BestEpoch+run.EarlyStopping.Patience+1)
+
+	best := run.History[run.BestEpoch]
+	for _, metrics := range run.History {
+		assert.True(t, metrics.Validation >= best.Validation)
+	}
+
+	// the best cortex is returned rather than the last one
+	validation, err := trained.Evaluate(examples, SSE{})
+	assert.True(t, err == nil)
+	assert.Equals(t, validation, best.Validation)
+
+}
+
+func TestTrainingRunMaxTime(t *testing.T) {
+
+	run := &TrainingRun{MaxTime: 50 * time.Millisecond}
+	trained, err := run.Train(NewBackpropTrainer(), XnorCortexUntrained(), XnorTrainingSamples())
+	assert.True(t, err == nil)
+	assert.True(t, trained != nil)
+	assert.True(t, len(run.History) > 0)
+
+	last := run.History[len(run.History)-1]
+	previous := time.Duration(0)
+	if len(run.History) > 1 {
+		previous = run.History[len(run.History)-2].Elapsed
+	}
+	assert.True(t, previous < run.MaxTime)
+	assert.True(t, last.Elapsed >= previous)
+
+}
+
+func TestTrainingRunNoLearningRate(t *testing.T) {
+	run := NewTrainingRun(10)
+	run.Schedule = ExponentialDecay(0.1, 0.9)
+	trainer := &fixedRateTrainer{}
+	_, err := run.Train(trainer, XnorCortexUntrained(), XnorTrainingSamples())
+	assert.True(t, errors.Is(err, ErrNoLearningRate))
+	assert.Equals(t, trainer.epochs, 0)
+}
+
+// an EpochTrainer which doesn't have a learning rate
+type fixedRateTrainer struct {
+	epochs int
+}
+
+func (trainer *fixedRateTrainer) Train(cortex *Cortex, examples []*TrainingSample) *Cortex {
+	return cortex
+}
+
+func (trainer *fixedRateTrainer) TrainEpoch(cortex *Cortex, examples []*TrainingSample) (float64, error) {
+	trainer.epochs += 1
+	return 0, nil
+}
@@ -9,7 +9,7 @@ import (
 func NewUuid() string {
 	u4, err := uuid.NewV4()
 	if err != nil {
-		logg.LogPanic("Error generating uuid", err)
+		logg.LogPanic("Error generating uuid: %v", err)
 	}
 	return fmt.Sprintf("%s", u4)
 }
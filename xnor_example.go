@@ -2,6 +2,8 @@ package neurgo
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 )
 
 func XnorCortex() *Cortex {
@@ -146,6 +148,22 @@ func XnorCortexUntrained() *Cortex {
 
 }
 
+// SeededXnorCortexUntrained is XnorCortexUntrained with its weights and
+// biases drawn from rng, uniformly in [-pi, pi) like RandomWeight, so
+// that whatever is trained from it behaves the same way on every run
+func SeededXnorCortexUntrained(rng *rand.Rand) *Cortex {
+	cortex := XnorCortexUntrained()
+	for _, neuron := range cortex.Neurons {
+		neuron.Bias = rng.Float64()*2*math.Pi - math.Pi
+		for _, inbound := range neuron.Inbound {
+			for i := range inbound.Weights {
+				inbound.Weights[i] = rng.Float64()*2*math.Pi - math.Pi
+			}
+		}
+	}
+	return cortex
+}
+
 func XnorTrainingSamples() []*TrainingSample {
 
 	// inputs + expected outputs